@@ -0,0 +1,17 @@
+// Package api provides structure of the api server
+package api
+
+import (
+	"net/http"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/health"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/metrics"
+)
+
+// Register the unversioned operational endpoints (metrics, liveness, readiness and build info), which are shared by every version of the api
+func RegisterOpsHandles() {
+	http.HandleFunc("/metrics", metrics.Default.Handler())
+	http.HandleFunc("/healthz", health.LiveHandler())
+	http.HandleFunc("/readyz", health.Default.Handler())
+	http.HandleFunc("/buildinfo", health.BuildInfoHandler())
+}
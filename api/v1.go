@@ -2,10 +2,12 @@
 package api
 
 import (
-	"log"
+	"context"
 	"net/http"
 
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/customer_service"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/metrics"
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
 )
 
@@ -30,13 +32,16 @@ func GetApiV1(officeLongitude float64, officeLatitude float64) (*ApiV1, error) {
 	}
 	api := &ApiV1{}
 	pattern := "/" + api.getVersion() + "/customer"
-	api.registerHandle(pattern, util.ErrorHandler(customer_service.GetCustomers))
+	api.registerHandle(pattern, util.RequestIDHandler(metrics.Instrument(pattern, util.ErrorHandler(customer_service.GetCustomers))))
+	api.registerHandle(pattern+"/invitations", util.RequestIDHandler(metrics.Instrument(pattern+"/invitations", util.ErrorHandler(customer_service.GetInvitations))))
+	api.registerHandle(pattern+"/stats", util.RequestIDHandler(metrics.Instrument(pattern+"/stats", util.ErrorHandler(customer_service.GetStats))))
+	api.registerHandle(pattern+"/clusters", util.RequestIDHandler(metrics.Instrument(pattern+"/clusters", util.ErrorHandler(customer_service.GetClusters))))
 	return api, nil
 }
 
 // Start up the server and listen to the provided addr
 func (api *ApiV1) StartServer(addr string) error {
-	log.Println("Starting server...")
+	logger.Info(context.Background(), "Starting server", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		return err
 	}
@@ -0,0 +1,68 @@
+// Package api provides structure of the api server
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/customer_service"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/event"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/job"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/metrics"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/rsvp"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// This is the version 2 struct, which adds the asynchronous invite jobs, the scheduled runs, the RSVPs and the events
+type ApiV2 struct {
+	jobs   *job.Manager
+	runs   *schedule.Scheduler
+	rsvps  *rsvp.Store
+	events *event.Store
+}
+
+// Register the provided handle
+func (api *ApiV2) registerHandle(patterns string, f func(w http.ResponseWriter, r *http.Request)) {
+	http.HandleFunc(patterns, f)
+}
+
+// Return the existing version
+func (api *ApiV2) getVersion() string {
+	return "v2"
+}
+
+// Return an apiV2 struct with the job, run, RSVP and event handles registered. The job manager and the scheduler
+// must be started by the caller
+func GetApiV2(jobs *job.Manager, runs *schedule.Scheduler, rsvps *rsvp.Store, events *event.Store) (*ApiV2, error) {
+	api := &ApiV2{jobs: jobs, runs: runs, rsvps: rsvps, events: events}
+	pattern := "/" + api.getVersion() + "/invite-jobs"
+	api.registerHandle(pattern, util.RequestIDHandler(metrics.Instrument(pattern, util.ErrorHandler(jobs.SubmitHandler(customer_service.ReadInviteJobInput)))))
+	api.registerHandle(pattern+"/", util.RequestIDHandler(metrics.Instrument(pattern+"/{id}", util.ErrorHandler(jobs.StatusHandler(pattern+"/")))))
+
+	pattern = "/" + api.getVersion() + "/runs"
+	api.registerHandle(pattern, util.RequestIDHandler(metrics.Instrument(pattern, util.ErrorHandler(runs.Handler(pattern)))))
+	api.registerHandle(pattern+"/diff", util.RequestIDHandler(metrics.Instrument(pattern+"/diff", util.ErrorHandler(runs.Handler(pattern)))))
+	api.registerHandle(pattern+"/", util.RequestIDHandler(metrics.Instrument(pattern+"/{id}", util.ErrorHandler(runs.Handler(pattern)))))
+
+	pattern = "/" + api.getVersion() + "/rsvps"
+	api.registerHandle(pattern, util.RequestIDHandler(metrics.Instrument(pattern, util.ErrorHandler(rsvps.AdminHandler(pattern)))))
+	api.registerHandle(pattern+"/", util.RequestIDHandler(metrics.Instrument(pattern+"/{event}", util.ErrorHandler(rsvps.AdminHandler(pattern)))))
+	pattern = "/" + api.getVersion() + "/events"
+	api.registerHandle(pattern, util.RequestIDHandler(metrics.Instrument(pattern, util.ErrorHandler(events.Handler(pattern, customer_service.EventCandidates)))))
+	api.registerHandle(pattern+"/", util.RequestIDHandler(metrics.Instrument(pattern+"/{name}", util.ErrorHandler(events.Handler(pattern, customer_service.EventCandidates)))))
+
+	//the RSVP links are sent to the customers, so they are not versioned
+	api.registerHandle("/rsvp/", util.RequestIDHandler(metrics.Instrument("/rsvp/{token}", util.ErrorHandler(rsvps.RespondHandler("/rsvp/")))))
+	return api, nil
+}
+
+// Start up the server and listen to the provided addr
+func (api *ApiV2) StartServer(addr string) error {
+	logger.Info(context.Background(), "Starting server", "addr", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		return err
+	}
+	return nil
+}
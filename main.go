@@ -1,37 +1,307 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 
 	"git.codesubmit.io/sfox/party-invite-ruiegv/api"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/config"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/customer_service"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/event"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/geocode"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/health"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/invitation"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/job"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/road"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/rsvp"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/source"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/webhook"
 )
 
 func main() {
 	logPath := flag.String("logPath", "log.txt", "Path of log file")
+	logLevel := flag.String("logLevel", "info", "Minimum log level (debug, info, warn, error)")
+	logMaxSize := flag.Int64("logMaxSize", 100<<20, "Rotate the log file once it reaches this many bytes (0 to disable)")
+	logMaxAge := flag.Duration("logMaxAge", 24*time.Hour, "Rotate the log file once it is this old (0 to disable)")
+	logRedact := flag.Bool("logRedact", true, "Redact customer names and coordinates from the log")
 	port := flag.String("port", "8081", "Listening port")
 	officeLatitude := flag.Float64("latitude", 53.339428, "Latitude of office")
 	officeLongitude := flag.Float64("longitude", -6.257664, "Longitude of office")
+	office := flag.String("office", "", "Name of the place of the office in the -placesPath gazetteer (e.g. \"Dublin, IE\" or \"Newbridge, Kildare, IE\"), overrides -latitude and -longitude")
+	maxDistance := flag.Float64("maxDistance", customer_service.DefaultMaxDistance, "Customers within this distance of the office, in -unit, are invited. When not set, the default 100 km is converted into -unit")
+	unit := flag.String("unit", "km", "Unit of the distances (km, mi, nmi, m)")
+	earthRadius := flag.String("earthRadius", "mean", "Radius of the earth the distances are computed with (mean, equatorial, authalic)")
+	eligibility := flag.String("eligibility", "straight", "How the customers within -maxDistance are found: straight (great circle), road (shortest distance over -roadGraph) or time (shortest travel time over -roadGraph, within -maxTime)")
+	maxTime := flag.Duration("maxTime", 30*time.Minute, "Customers within this travel time of the office are invited, with -eligibility time")
+	roadGraph := flag.String("roadGraph", "", "Path of the tab separated road graph (node and edge lines) of the road and time eligibilities")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of goroutines in each stage (parse, distance) of the customer file pipeline")
+	formField := flag.String("formField", "customerFile", "Name of the multipart form field holding the customer files")
+	longitudePolicy := flag.String("longitudePolicy", "reject", "What is done with the customer longitudes outside -180..180: reject them, or wrap them around the antimeridian (190 becomes -170)")
+	duplicates := flag.String("duplicates", "reject", "Default policy for records sharing a user_id (reject, keep-first, keep-last, merge-if-identical, report-and-skip)")
+	jobDir := flag.String("jobDir", "jobs", "Directory where the asynchronous invite jobs are persisted")
+	jobWorkers := flag.Int("jobWorkers", 4, "Number of workers processing the asynchronous invite jobs")
+	jobRetention := flag.Int("jobRetention", 1000, "Number of finished asynchronous invite jobs kept with their results, the oldest are removed")
+	sourceDirs := flag.String("sourceDirs", "", "Comma separated directories file:// customer file sources may be read from")
+	sourceHosts := flag.String("sourceHosts", "", "Comma separated hosts http(s):// customer file sources may be fetched from")
+	sourceMaxSize := flag.Int64("sourceMaxSize", 100<<20, "Maximum size in bytes of a fetched customer file")
+	sourceTimeout := flag.Duration("sourceTimeout", 30*time.Second, "Maximum time taken to fetch a customer file")
+	configPath := flag.String("config", "", "Path of the JSON configuration file (schedules, webhooks, distance)")
+	runDir := flag.String("runDir", "runs", "Directory where the snapshots of the scheduled runs are persisted")
+	webhookDeadLetter := flag.String("webhookDeadLetter", "webhook-dead-letter.log", "Path of the log of the webhook deliveries which failed after all their attempts")
+	webhookWorkers := flag.Int("webhookWorkers", 2, "Number of workers delivering the webhooks")
+	templateDir := flag.String("templateDir", "", "Directory of the invitation templates (to.txt, subject.txt, body.txt, body.html), the missing ones are the defaults")
+	inviteFrom := flag.String("inviteFrom", invitation.DefaultConfig.From, "Sender address of the invitations")
+	officeName := flag.String("officeName", invitation.DefaultConfig.OfficeName, "Name of the office in the invitations")
+	smtpAddr := flag.String("smtpAddr", "", "Address (host:port) of the SMTP server the invitations are sent to, empty to disable sending")
+	smtpSink := flag.String("smtpSink", "", "Run a local SMTP sink on this address (e.g. 127.0.0.1:2525) to test the delivery of the invitations")
+	smtpSinkDir := flag.String("smtpSinkDir", "mail", "Directory where the SMTP sink writes the messages it receives")
+	rsvpPath := flag.String("rsvpPath", "rsvps.json", "Path of the file where the RSVP tokens and answers are persisted")
+	eventPath := flag.String("eventPath", "events.json", "Path of the file where the events are persisted")
+	placesPath := flag.String("placesPath", "", "Path of the tab separated places (name, county, country, latitude, longitude, population) the customers are reverse geocoded with, empty for the bundled towns of Ireland")
+	rsvpBaseURL := flag.String("rsvpBaseURL", "", "Base URL of the RSVP links put in the invitations (default http://localhost:<port>/rsvp)")
 
 	flag.Parse()
 	//init the logger with the specified path
-	logWriter, err := os.OpenFile(*logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	level, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	logWriter, err := logger.OpenRotatingFile(*logPath, *logMaxSize, *logMaxAge)
 	if err != nil {
 		log.Fatal("Fail to open log file: ", err.Error())
 		return
 	}
 	log.SetOutput(logWriter)
+	l := logger.New(logWriter, level)
+	l.SetRedaction(*logRedact)
+	logger.SetDefault(l)
+
+	if err := customer_service.SetWorkers(*workers); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	if err := customer_service.SetFormField(*formField); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	if err := customer_service.SetDuplicatePolicy(*duplicates); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	if err := customer_service.SetLongitudePolicy(*longitudePolicy); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+
+	//Set up the invitations, and the SMTP sink to test their delivery
+	templates := invitation.DefaultTemplates
+	if *templateDir != "" {
+		if templates, err = invitation.LoadTemplates(*templateDir); err != nil {
+			log.Fatal("Fail to load invitation templates: ", err.Error())
+			return
+		}
+	}
+	renderer, err := invitation.NewRenderer(invitation.Config{From: *inviteFrom, OfficeName: *officeName, Templates: templates})
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	if *smtpSink != "" {
+		sink, err := invitation.ListenSink(*smtpSink, *smtpSinkDir)
+		if err != nil {
+			log.Fatal("Fail to start SMTP sink: ", err.Error())
+			return
+		}
+		logger.Info(context.Background(), "Started SMTP sink", "addr", sink.Addr(), "dir", *smtpSinkDir)
+	}
+	customer_service.SetInvitations(renderer, *smtpAddr)
+
+	//Track the answers to the invitations
+	rsvps, err := rsvp.OpenStore(*rsvpPath)
+	if err != nil {
+		log.Fatal("Fail to load RSVPs: ", err.Error())
+		return
+	}
+	if *rsvpBaseURL == "" {
+		*rsvpBaseURL = "http://localhost:" + *port + "/rsvp"
+	}
+	customer_service.SetRSVP(rsvps, *rsvpBaseURL)
+
+	//Load the events
+	events, err := event.OpenStore(*eventPath)
+	if err != nil {
+		log.Fatal("Fail to load events: ", err.Error())
+		return
+	}
+
+	//Load the places of the reverse geocoding, and resolve the office by name in them
+	if *placesPath != "" {
+		places, err := geocode.LoadFile(*placesPath)
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+		customer_service.SetPlaces(places)
+	}
+	if *office != "" {
+		place, err := customer_service.Places.Lookup(*office)
+		if err != nil {
+			log.Fatal("Fail to resolve office ", *office, " : ", err.Error())
+			return
+		}
+		if err := place.Location().Validate(); err != nil {
+			log.Fatal("Invalid location of office ", place.Label(), " : ", err.Error())
+			return
+		}
+		*officeLatitude, *officeLongitude = place.Latitude, place.Longitude
+		logger.Info(context.Background(), "Resolved office", "office", *office, "place", place.Label(), "point", place.Location().String())
+	}
+
+	//Accept customer file references when some sources are allowed
+	var sources *source.Fetcher
+	if *sourceDirs != "" || *sourceHosts != "" {
+		sources, err = source.NewFetcher(source.Config{
+			AllowedDirs:  splitList(*sourceDirs),
+			AllowedHosts: splitList(*sourceHosts),
+			MaxSize:      *sourceMaxSize,
+			Timeout:      *sourceTimeout,
+		})
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+		customer_service.SetSources(sources)
+	}
+
+	//Load the configuration and the road graph, set the default range of the invites and push the results of the runs to its webhooks
+	conf := &config.Config{}
+	if *configPath != "" {
+		if conf, err = config.Load(*configPath); err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+	}
+	//the flags set on the command line take precedence over the configuration file
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var max *float64
+	if set["maxDistance"] {
+		max = maxDistance
+	} else if conf.Distance.MaxDistance != nil {
+		max = conf.Distance.MaxDistance
+	}
+	if conf.Distance.Unit != "" && !set["unit"] {
+		*unit = conf.Distance.Unit
+	}
+	if conf.Distance.EarthRadius != "" && !set["earthRadius"] {
+		*earthRadius = conf.Distance.EarthRadius
+	}
+	if *roadGraph != "" {
+		roads, err := road.LoadFile(*roadGraph)
+		if err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+		customer_service.SetRoads(roads)
+	}
+	distances, err := customer_service.NewRange(max, *unit, *earthRadius, *eligibility, *maxTime)
+	if err != nil {
+		log.Fatal(err.Error())
+		return
+	}
+	customer_service.SetDefaultRange(distances)
+
+	var webhooks *webhook.Notifier
+	if len(conf.Webhooks) > 0 {
+		deadLetter, err := os.OpenFile(*webhookDeadLetter, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatal("Fail to open webhook dead-letter log: ", err.Error())
+			return
+		}
+		if webhooks, err = webhook.NewNotifier(conf.Webhooks, *webhookWorkers, deadLetter); err != nil {
+			log.Fatal(err.Error())
+			return
+		}
+		customer_service.SetWebhooks(webhooks)
+	}
+
+	//Load the persisted invite jobs
+	jobs, err := job.NewManager(*jobDir, *jobWorkers, *jobRetention, customer_service.ProcessInviteJob)
+	if err != nil {
+		log.Fatal("Fail to load jobs: ", err.Error())
+		return
+	}
 
-	//Get the api instance
+	//Create the scheduled runs
+	for _, s := range conf.Schedules {
+		if _, err := customer_service.ParseDuplicatePolicy(s.Duplicates); err != nil {
+			log.Fatal("Schedule ", s.Name, " : ", err.Error())
+			return
+		}
+		if _, err := customer_service.ScheduleRange(s); err != nil {
+			log.Fatal("Schedule ", s.Name, " : ", err.Error())
+			return
+		}
+		if sources == nil {
+			log.Fatal("Schedule ", s.Name, " : no source is allowed, set -sourceDirs or -sourceHosts")
+			return
+		}
+	}
+	runs, err := schedule.NewScheduler(*runDir, conf.Schedules, customer_service.RunSchedule)
+	if err != nil {
+		log.Fatal("Fail to load runs: ", err.Error())
+		return
+	}
+
+	//Register the readiness checks and the operational endpoints
+	health.Default.Register("office_location", customer_service.CheckOfficeLocation)
+	health.Default.Register("log_file", logWriter.CheckWritable)
+	health.Default.Register("job_store", jobs.CheckStore)
+	health.Default.Register("run_store", runs.CheckStore)
+	health.Default.Register("rsvp_store", rsvps.CheckStore)
+	health.Default.Register("event_store", events.CheckStore)
+	if sources != nil {
+		health.Default.Register("source_dirs", sources.Check)
+	}
+	api.RegisterOpsHandles()
+
+	//Get the api instances, every version registers its handles on the same server
+	if _, err := api.GetApiV2(jobs, runs, rsvps, events); err != nil {
+		log.Fatal(err.Error())
+		return
+	}
 	api, err := api.GetApiV1(*officeLongitude, *officeLatitude)
 	if err != nil {
 		log.Fatal(err.Error())
 		return
 	}
-	//Start the api
+	//Start the workers once the office location is set, then the api
+	if webhooks != nil {
+		webhooks.Start(context.Background())
+	}
+	jobs.Start(context.Background())
+	runs.Start(context.Background())
 	if err := api.StartServer(":" + *port); err != nil {
 		log.Fatal("Fail to start server: ", err.Error())
 		return
 	}
 }
+
+// Split a comma separated flag value, dropping the empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,156 @@
+// Package cluster groups points on the sphere, with k-means or DBSCAN on great circle distances
+package cluster
+
+import (
+	"errors"
+	"math"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Label of the points DBSCAN leaves out of every cluster
+const Noise = -1
+
+// Maximum number of k-means iterations
+const maxIterations = 100
+
+// A point as a unit vector, so that means are taken on the sphere rather than on longitudes and latitudes
+type vector struct {
+	x, y, z float64
+}
+
+// Convert a point into its unit vector
+func toVector(p greatCircle.Point) vector {
+	return vector{math.Cos(p.Latitude) * math.Cos(p.Longitude), math.Cos(p.Latitude) * math.Sin(p.Longitude), math.Sin(p.Latitude)}
+}
+
+// Convert a vector back into a point, the vector does not need to be of unit length
+func (v vector) point() greatCircle.Point {
+	return greatCircle.MakePoint(math.Atan2(v.y, v.x), math.Atan2(v.z, math.Sqrt(v.x*v.x+v.y*v.y)))
+}
+
+// Return the spherical centroid of points: their mean vector projected back onto the sphere
+func Centroid(points []greatCircle.Point) greatCircle.Point {
+	var sum vector
+	for _, p := range points {
+		v := toVector(p)
+		sum.x, sum.y, sum.z = sum.x+v.x, sum.y+v.y, sum.z+v.z
+	}
+	return sum.point()
+}
+
+// Partition the points into k clusters minimising the great circle distance to their centroid. Return the cluster
+// of every point and the centroids. The first centroid is the first point and every next one the point furthest
+// from the centroids chosen so far, so the outcome only depends on the order of the points
+func KMeans(points []greatCircle.Point, k int) ([]int, []greatCircle.Point, error) {
+	if k < 1 {
+		return nil, nil, errors.New("Number of clusters must be > 0")
+	}
+	if k > len(points) {
+		k = len(points)
+	}
+	labels := make([]int, len(points))
+	if k == 0 {
+		return labels, nil, nil
+	}
+
+	centroids := []greatCircle.Point{points[0]}
+	for len(centroids) < k {
+		furthest, furthestDistance := 0, -1.0
+		for i, p := range points {
+			if _, d := nearest(p, centroids); d > furthestDistance {
+				furthest, furthestDistance = i, d
+			}
+		}
+		centroids = append(centroids, points[furthest])
+	}
+
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		changed := iteration == 0
+		for i, p := range points {
+			if c, _ := nearest(p, centroids); c != labels[i] {
+				labels[i], changed = c, true
+			}
+		}
+		if !changed {
+			break
+		}
+		members := make([][]greatCircle.Point, k)
+		for i, p := range points {
+			members[labels[i]] = append(members[labels[i]], p)
+		}
+		for c := range centroids {
+			//a cluster which lost all its points keeps its centroid
+			if len(members[c]) > 0 {
+				centroids[c] = Centroid(members[c])
+			}
+		}
+	}
+	return labels, centroids, nil
+}
+
+// Return the index of the centroid closest to p and its central angle to p
+func nearest(p greatCircle.Point, centroids []greatCircle.Point) (int, float64) {
+	best, bestAngle := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if angle := greatCircle.Distance(p, centroid, 1); angle < bestAngle {
+			best, bestAngle = c, angle
+		}
+	}
+	return best, bestAngle
+}
+
+// Group the points with DBSCAN: a point with at least minPoints points (itself included) within eps is a core point,
+// and the points within eps of a core point join its cluster. eps is in the unit of radius. Return the cluster of
+// every point, numbered from 0 in the order of the points, or Noise, and the number of clusters
+func DBSCAN(points []greatCircle.Point, eps float64, minPoints int, radius float64) ([]int, int, error) {
+	if eps <= 0 {
+		return nil, 0, errors.New("DBSCAN eps must be > 0")
+	}
+	if minPoints < 1 {
+		return nil, 0, errors.New("DBSCAN min points must be > 0")
+	}
+	const unvisited = -2
+	labels := make([]int, len(points))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+	neighbours := func(i int) []int {
+		var found []int
+		for j, p := range points {
+			if greatCircle.Distance(points[i], p, radius) <= eps {
+				found = append(found, j)
+			}
+		}
+		return found
+	}
+
+	clusters := 0
+	for i := range points {
+		if labels[i] != unvisited {
+			continue
+		}
+		seeds := neighbours(i)
+		if len(seeds) < minPoints {
+			labels[i] = Noise
+			continue
+		}
+		labels[i] = clusters
+		for queue := seeds; len(queue) > 0; queue = queue[1:] {
+			j := queue[0]
+			if labels[j] == Noise {
+				//a border point
+				labels[j] = clusters
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+			labels[j] = clusters
+			if found := neighbours(j); len(found) >= minPoints {
+				queue = append(queue, found...)
+			}
+		}
+		clusters++
+	}
+	return labels, clusters, nil
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Return a point from degrees
+func degreePoint(latitude float64, longitude float64) greatCircle.Point {
+	return greatCircle.MakePoint(greatCircle.DegreeToRadian(longitude), greatCircle.DegreeToRadian(latitude))
+}
+
+// Two groups of points, around Dublin and around Cork, and a point in Galway
+var testPoints = []greatCircle.Point{
+	degreePoint(53.34, -6.26),
+	degreePoint(51.90, -8.47),
+	degreePoint(53.35, -6.25),
+	degreePoint(51.89, -8.48),
+	degreePoint(53.33, -6.27),
+	degreePoint(53.27, -9.05),
+}
+
+func TestCentroid(t *testing.T) {
+	c := Centroid([]greatCircle.Point{degreePoint(0, 10), degreePoint(0, 20)})
+	if math.Abs(greatCircle.RadianToDegree(c.Longitude)-15) > 1e-9 || math.Abs(c.Latitude) > 1e-9 {
+		t.Errorf("Output %v, expected 0, 15", c)
+	}
+	//across the antimeridian the centroid is not the mean of the longitudes
+	c = Centroid([]greatCircle.Point{degreePoint(0, 170), degreePoint(0, -170)})
+	if math.Abs(math.Abs(greatCircle.RadianToDegree(c.Longitude))-180) > 1e-9 {
+		t.Errorf("Output %v, expected 0, 180", c)
+	}
+}
+
+type kMeansTest struct {
+	k      int
+	labels []int
+}
+
+var kMeansTests []kMeansTest = []kMeansTest{
+	kMeansTest{1, []int{0, 0, 0, 0, 0, 0}},
+	kMeansTest{2, []int{0, 1, 0, 1, 0, 1}},
+	kMeansTest{3, []int{0, 1, 0, 1, 0, 2}},
+	kMeansTest{10, []int{0, 3, 5, 1, 4, 2}},
+}
+
+func TestKMeans(t *testing.T) {
+	for _, test := range kMeansTests {
+		labels, centroids, err := KMeans(testPoints, test.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("Output %v for k=%v not equal to expected %v", labels, test.k, test.labels)
+		}
+		if expected := test.k; expected > len(testPoints) && len(centroids) != len(testPoints) || expected <= len(testPoints) && len(centroids) != expected {
+			t.Errorf("Output %v centroids for k=%v", len(centroids), test.k)
+		}
+	}
+	_, centroids, _ := KMeans(testPoints, 3)
+	if d := greatCircle.Distance(centroids[0], degreePoint(53.34, -6.26), greatCircle.Radius); d > 0.1 {
+		t.Errorf("Output centroid %v, %v km away from Dublin", centroids[0], d)
+	}
+	if _, _, err := KMeans(testPoints, 0); err == nil {
+		t.Errorf("Expected an error for k=0")
+	}
+	if labels, centroids, err := KMeans(nil, 3); err != nil || len(labels) != 0 || len(centroids) != 0 {
+		t.Errorf("Output %v %v %v, expected no cluster", labels, centroids, err)
+	}
+}
+
+type dbscanTest struct {
+	eps       float64
+	minPoints int
+	labels    []int
+	clusters  int
+}
+
+var dbscanTests []dbscanTest = []dbscanTest{
+	dbscanTest{5, 2, []int{0, 1, 0, 1, 0, Noise}, 2},
+	dbscanTest{5, 3, []int{0, Noise, 0, Noise, 0, Noise}, 1},
+	dbscanTest{200, 2, []int{0, 0, 0, 0, 0, 0}, 1},
+	dbscanTest{0.1, 1, []int{0, 1, 2, 3, 4, 5}, 6},
+}
+
+func TestDBSCAN(t *testing.T) {
+	for _, test := range dbscanTests {
+		labels, clusters, err := DBSCAN(testPoints, test.eps, test.minPoints, greatCircle.Radius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(labels, test.labels) || clusters != test.clusters {
+			t.Errorf("Output %v %v for eps=%v minPoints=%v not equal to expected %v %v", labels, clusters, test.eps, test.minPoints, test.labels, test.clusters)
+		}
+	}
+	if _, _, err := DBSCAN(testPoints, 0, 2, greatCircle.Radius); err == nil {
+		t.Errorf("Expected an error for eps=0")
+	}
+	if _, _, err := DBSCAN(testPoints, 5, 0, greatCircle.Radius); err == nil {
+		t.Errorf("Expected an error for minPoints=0")
+	}
+}
@@ -0,0 +1,47 @@
+// Package config loads the optional JSON configuration file of the service
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/webhook"
+)
+
+// Content of the configuration file
+type Config struct {
+	// Invite runs started on cron schedules
+	Schedules []schedule.Config `json:"schedules"`
+	// URLs the results of the invite runs are pushed to
+	Webhooks []webhook.Config `json:"webhooks"`
+	// Default range of the invites, for the flags not set on the command line
+	Distance Distance `json:"distance"`
+}
+
+// Default range of the invites, the empty settings and the ones set on the command line keep their command line value
+type Distance struct {
+	// Maximum distance from the office, in Unit
+	MaxDistance *float64 `json:"max_distance"`
+	// km, mi, nmi or m
+	Unit string `json:"unit"`
+	// mean, equatorial or authalic
+	EarthRadius string `json:"earth_radius"`
+}
+
+// Load the configuration file at path. Unknown keys are rejected so that typos do not go unnoticed
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	var c Config
+	if err := decoder.Decode(&c); err != nil {
+		return nil, errors.New("Invalid configuration file " + path + " : " + err.Error())
+	}
+	return &c, nil
+}
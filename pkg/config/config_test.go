@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type loadTest struct {
+	content   string
+	schedules int
+	valid     bool
+}
+
+var loadTests []loadTest = []loadTest{
+	loadTest{"{}", 0, true},
+	loadTest{"{\"schedules\": [{\"name\": \"weekly\", \"cron\": \"0 9 * * 1\", \"source\": \"file:///data/customers.txt\"}]}", 1, true},
+	loadTest{"{\"distance\": {\"max_distance\": 60, \"unit\": \"mi\", \"earth_radius\": \"authalic\"}}", 0, true},
+	loadTest{"{\"distance\": {\"units\": \"mi\"}}", 0, false},
+	loadTest{"{\"schedule\": []}", 0, false},
+	loadTest{"{", 0, false},
+}
+
+func TestLoad(t *testing.T) {
+	for _, test := range loadTests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := Load(path)
+		if (err == nil) != test.valid {
+			t.Errorf("Output error %v for %v, expected valid %v", err, test.content, test.valid)
+		}
+		if err == nil && len(c.Schedules) != test.schedules {
+			t.Errorf("Output %v schedules not equal to expected %v", len(c.Schedules), test.schedules)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,147 @@
+package customer_service
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/cluster"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Clustering methods of GetClusters
+const (
+	ClusterKMeans = "kmeans"
+	ClusterDBSCAN = "dbscan"
+)
+
+// A pickup cluster of invited customers
+type Cluster struct {
+	ID       int         `json:"id"`
+	Centroid Coordinates `json:"centroid"`
+	// Distance from the centroid to its furthest member, in the unit of the request
+	Radius  float64    `json:"radius"`
+	Members []Customer `json:"members"`
+}
+
+// The invited customers grouped into clusters
+type clusterResult struct {
+	Method   string    `json:"method"`
+	Unit     string    `json:"unit"`
+	Clusters []Cluster `json:"clusters"`
+	// Customers DBSCAN leaves out of every cluster
+	Noise []Customer `json:"noise"`
+}
+
+// Settings of a clustering read from the query parameters
+type clusterSettings struct {
+	method    string
+	k         int
+	eps       float64
+	minPoints int
+}
+
+// Read the clustering of a request: method=kmeans (the default) with k clusters, or method=dbscan with eps (in the
+// unit of the request) and min_points (default 2)
+func parseClusterSettings(query url.Values) (clusterSettings, error) {
+	s := clusterSettings{method: query.Get("method"), minPoints: 2}
+	if s.method == "" {
+		s.method = ClusterKMeans
+	}
+	var err error
+	switch s.method {
+	case ClusterKMeans:
+		if s.k, err = strconv.Atoi(query.Get("k")); err != nil || s.k < 1 {
+			return s, errors.New("Invalid k " + query.Get("k") + ", expected a number of clusters > 0")
+		}
+	case ClusterDBSCAN:
+		if s.eps, err = strconv.ParseFloat(query.Get("eps"), 64); err != nil || s.eps <= 0 {
+			return s, errors.New("Invalid eps " + query.Get("eps") + ", expected a distance > 0")
+		}
+		if minPoints := query.Get("min_points"); minPoints != "" {
+			if s.minPoints, err = strconv.Atoi(minPoints); err != nil || s.minPoints < 1 {
+				return s, errors.New("Invalid min_points " + minPoints + ", expected a number > 0")
+			}
+		}
+	default:
+		return s, errors.New("Invalid clustering method " + s.method + ", expected kmeans or dbscan")
+	}
+	return s, nil
+}
+
+// Group the customers into clusters, with the distances in the unit of distances
+func clusterCustomers(customers []Customer, settings clusterSettings, distances Range) (*clusterResult, error) {
+	points := make([]greatCircle.Point, len(customers))
+	for i, customer := range customers {
+		points[i] = customer.Location
+	}
+	var labels []int
+	var count int
+	var err error
+	if settings.method == ClusterKMeans {
+		var centroids []greatCircle.Point
+		labels, centroids, err = cluster.KMeans(points, settings.k)
+		count = len(centroids)
+	} else {
+		labels, count, err = cluster.DBSCAN(points, distances.Unit.ToKm(settings.eps), settings.minPoints, distances.EarthRadius)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	result := &clusterResult{Method: settings.method, Unit: string(distances.Unit), Clusters: []Cluster{}, Noise: []Customer{}}
+	members := make([][]Customer, count)
+	for i, label := range labels {
+		if label == cluster.Noise {
+			result.Noise = append(result.Noise, customers[i])
+			continue
+		}
+		members[label] = append(members[label], customers[i])
+	}
+	for _, m := range members {
+		if len(m) == 0 {
+			continue
+		}
+		memberPoints := make([]greatCircle.Point, len(m))
+		for i, customer := range m {
+			memberPoints[i] = customer.Location
+		}
+		centroid := cluster.Centroid(memberPoints)
+		radius := 0.0
+		for _, p := range memberPoints {
+			if d := distances.Distance(centroid, p); d > radius {
+				radius = d
+			}
+		}
+		result.Clusters = append(result.Clusters, Cluster{
+			ID:       len(result.Clusters),
+			Centroid: Coordinates{greatCircle.RadianToDegree(centroid.Latitude), greatCircle.RadianToDegree(centroid.Longitude)},
+			Radius:   radius,
+			Members:  m,
+		})
+	}
+	return result, nil
+}
+
+// Entry point of the pickup clusters. The customers are read as for GetCustomers and the invited ones are grouped
+// into clusters with their centroid and members, see parseClusterSettings for the query parameters
+func GetClusters(w http.ResponseWriter, r *http.Request) error {
+	settings, err := parseClusterSettings(r.URL.Query())
+	if nil != err {
+		return err
+	}
+	result, err := processInviteRequest(r)
+	if nil != err {
+		return err
+	}
+	clusters, err := clusterCustomers(result.Customers, settings, result.distances)
+	if nil != err {
+		return err
+	}
+	logger.Info(r.Context(), "Clustered customers", "method", settings.method, "customers", len(result.Customers), "clusters", len(clusters.Clusters), "noise", len(clusters.Noise))
+
+	return util.WriteJSON(w, http.StatusOK, clusters)
+}
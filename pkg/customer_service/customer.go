@@ -2,29 +2,63 @@ package customer_service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
 
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/jsonl"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/source"
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/webhook"
 )
 
 var OfficeLocation greatCircle.Point
 
+// Whether the office location was set and validated by SetOfficeLocation
+var officeLocationValidated bool
+
 // Set the office location
 func SetOfficeLocation(officeLongitude float64, officeLatitude float64) error {
 	OfficeLocation.Longitude = greatCircle.DegreeToRadian(officeLongitude)
 	OfficeLocation.Latitude = greatCircle.DegreeToRadian(officeLatitude)
-	log.Println("Set office location to ", OfficeLocation)
-	if !OfficeLocation.Valid() {
-		return errors.New("Invalid office longitude or latitude")
+	logger.Info(context.Background(), "Set office location", "office", OfficeLocation)
+	err := greatCircle.ValidateDegrees(officeLatitude, officeLongitude)
+	officeLocationValidated = err == nil
+	if !officeLocationValidated {
+		return errors.New("Invalid office location: " + err.Error())
+	}
+
+	return nil
+}
+
+// What is done with the customer longitudes outside -180..180
+var LongitudePolicy = greatCircle.LongitudeReject
+
+// Set what is done with the customer longitudes outside -180..180 from a policy name (reject, wrap)
+func SetLongitudePolicy(s string) error {
+	policy, err := greatCircle.ParseLongitudePolicy(s)
+	if err != nil {
+		return err
 	}
+	LongitudePolicy = policy
+	return nil
+}
 
+// Readiness check reporting whether the office location was validated by SetOfficeLocation
+func CheckOfficeLocation() error {
+	if !officeLocationValidated {
+		return errors.New("Office location has not been set")
+	}
 	return nil
 }
 
@@ -35,6 +69,8 @@ type Customer struct {
 	Name      string
 	Longitude string
 	Location  greatCircle.Point
+	// Optional, ranks the customer for the events ranked by priority (higher first)
+	Priority int
 }
 
 // Implement MarshalJSON for Customer to only print user id and name
@@ -47,18 +83,30 @@ func (c *Customer) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// helper function to check if the unmarshaled JSON has all required keys
+// helper function to check if the unmarshaled JSON has all required keys. The location is either the longitude and
+// latitude, a geohash or a Plus Code
 func hasRequiredKey(m map[string]interface{}) bool {
-	_, longitudeFound := m["longitude"]
-	_, latitudeFound := m["latitude"]
 	_, userIdFound := m["user_id"]
 	_, nameFound := m["name"]
+	_, geohashFound := m["geohash"]
+	_, plusCodeFound := m["plus_code"]
+
+	return (hasCoordinates(m) || geohashFound || plusCodeFound) && userIdFound && nameFound
+}
 
-	return longitudeFound && latitudeFound && userIdFound && nameFound
+// helper function to check if the unmarshaled JSON has both the longitude and the latitude
+func hasCoordinates(m map[string]interface{}) bool {
+	_, longitudeFound := m["longitude"]
+	_, latitudeFound := m["latitude"]
+
+	return longitudeFound && latitudeFound
 }
 
 // Implement the UnmarshalJSON function so as to perform proper checking on the JSON and
-// convert the longitude and latitude into radian
+// convert the longitude and latitude into radian. The coordinates can be in any notation accepted by
+// greatCircle.ParseLatitude, e.g. decimal degrees or degrees minutes seconds with a hemisphere. Without them, the
+// location is the center of the "geohash" or "plus_code" cell. The longitude goes through the LongitudePolicy and the
+// coordinates are checked in degree, an invalid one gives a *greatCircle.CoordinateError naming it
 func (c *Customer) UnmarshalJSON(b []byte) error {
 	var tmpCustomer map[string]interface{}
 	if err := json.Unmarshal(b, &tmpCustomer); err != nil {
@@ -90,96 +138,187 @@ func (c *Customer) UnmarshalJSON(b []byte) error {
 				return errors.New("Cannot convert name as value is not of type string")
 			}
 			c.Name = value.(string)
+		case "priority":
+			if value == nil || reflect.TypeOf(value).Kind() != reflect.Float64 {
+				return errors.New("Cannot convert priority as value is not of type float64")
+			}
+			c.Priority = int(value.(float64))
+		case "geohash", "plus_code":
+			if value == nil || reflect.TypeOf(value).Kind() != reflect.String {
+				return errors.New("Cannot convert " + key + " as value is not of type string")
+			}
+		}
+	}
 
+	if !hasCoordinates(tmpCustomer) {
+		var p greatCircle.Point
+		var err error
+		if geohash, found := tmpCustomer["geohash"]; found {
+			p, err = greatCircle.DecodeGeohash(geohash.(string))
+		} else {
+			p, err = greatCircle.DecodePlusCode(tmpCustomer["plus_code"].(string))
+		}
+		if nil != err {
+			return err
 		}
+		c.Longitude = strconv.FormatFloat(greatCircle.RadianToDegree(p.Longitude), 'f', -1, 64)
+		c.Latitude = strconv.FormatFloat(greatCircle.RadianToDegree(p.Latitude), 'f', -1, 64)
 	}
 
-	longtitude, err := strconv.ParseFloat(c.Longitude, 64)
+	longtitude, err := greatCircle.ParseLongitude(c.Longitude)
 	if nil != err {
 		return err
 	}
 
-	latitude, err := strconv.ParseFloat(c.Latitude, 64)
+	latitude, err := greatCircle.ParseLatitude(c.Latitude)
 	if nil != err {
 		return err
 	}
 
-	p := greatCircle.MakePoint(greatCircle.DegreeToRadian(longtitude), greatCircle.DegreeToRadian(latitude))
-	if !p.Valid() {
-		return errors.New(" Invalid longitude or latitude")
+	longtitude = LongitudePolicy.Apply(longtitude)
+	if err := greatCircle.ValidateDegrees(latitude, longtitude); nil != err {
+		return err
 	}
 
-	c.Location = p
+	c.Location = greatCircle.MakePoint(greatCircle.DegreeToRadian(longtitude), greatCircle.DegreeToRadian(latitude))
 
 	return nil
 }
 
-// Test if we should invite the customer
-func (customer Customer) shouldInviteCustomer(distance float64) (bool, error) {
+// Test if we should invite the customer, at distance from the office when max is the maximum distance
+func (customer Customer) shouldInviteCustomer(distance float64, max float64) (bool, error) {
 	if distance < 0.0 {
 		return false, errors.New("Distance must be > 0")
 	}
 
-	return util.SmallerOrEqual(distance, 100.0), nil
+	return util.SmallerOrEqual(distance, max), nil
 }
 
 // Convert byte array into a customer map
 func convertToCustomers(filebyte []byte) (map[int]Customer, error) {
+	result, err := convertAndSelectCustomers(context.Background(), jsonl.Split(filebyte), DuplicateReject, nil, nil)
+	if nil != err {
+		return nil, err
+	}
+	return result.customers, nil
+}
 
-	//Split by the newline character
-	customers := bytes.Split(filebyte, []byte("\n"))
-	customerMap := make(map[int]Customer, len(customers))
-	for _, customer := range customers {
-		if !json.Valid(customer) {
-			err := "Invalid JSON: " + string(customer)
-			return nil, errors.New(err)
-		}
+// Where a customer record was found
+type Provenance struct {
+	Source string `json:"source"`
+	Line   int    `json:"line"`
+}
 
-		var c Customer
-		err := json.Unmarshal([]byte(customer), &c)
-		if nil != err {
-			err := "Cannot unmarshal customer " + string(customer) + " : " + err.Error()
-			return nil, errors.New(err)
-		}
-		_, found := customerMap[c.User_id]
-		if found {
-			err := "Customer id overlap: " + strconv.Itoa(c.User_id)
-			return nil, errors.New(err)
-		}
-		customerMap[c.User_id] = c
+// The outcome of converting customer files
+type conversion struct {
+	customers  map[int]Customer
+	provenance map[int]Provenance
+	invited    []int
+	duplicates []Duplicate
+}
 
+// Split the uploaded files into lines, skipping blank and comment lines
+func splitFiles(files []util.UploadedFile) []jsonl.Line {
+	var lines []jsonl.Line
+	for _, file := range files {
+		lines = append(lines, jsonl.SplitFile(file.Name, file.Content)...)
 	}
-	return customerMap, nil
+	return lines
 }
 
-// Entry point of the customer service
-func GetCustomers(w http.ResponseWriter, r *http.Request) error {
-
-	if http.MethodPut != r.Method {
-		return errors.New("HTTP request is not a PUT request")
-	}
-	fileBytes, err := util.GetFileInBytes(r)
+// Convert lines into a customer map and select the user ids of the customers within r to invite. Lines go through the
+// parse and distance stages of the pipeline, a nil r only parses them and selects no one. progress (if not nil) is
+// called with the number of lines processed so far. Records sharing a user_id are resolved according to policy
+func convertAndSelectCustomers(ctx context.Context, lines []jsonl.Line, policy DuplicatePolicy, r *Range, progress func(processed int, total int)) (*conversion, error) {
+	results, err := runPipeline(ctx, lines, r, progress)
 	if nil != err {
-		return err
+		return nil, err
 	}
 
-	customers, err := convertToCustomers(fileBytes)
+	//Errors are reported in line order so the outcome does not depend on the scheduling of the workers
+	for _, result := range results {
+		if nil != result.err {
+			return nil, result.err
+		}
+	}
+	kept, duplicates, err := resolveDuplicates(results, policy)
 	if nil != err {
-		return err
+		return nil, err
 	}
-	log.Println(customers)
 
-	//invite the appropriate customers
-	var resultCustomerSlice []int
-	for _, customer := range customers {
-		result, err := customer.shouldInviteCustomer(greatCircle.Distance(OfficeLocation, customer.Location, greatCircle.Radius))
-		if err != nil {
-			return err
+	conv := &conversion{customers: make(map[int]Customer, len(kept)), provenance: make(map[int]Provenance, len(kept)), duplicates: duplicates}
+	for _, i := range kept {
+		c := results[i].customer
+		conv.customers[c.User_id] = c
+		conv.provenance[c.User_id] = Provenance{results[i].source, results[i].line}
+		if results[i].invite {
+			conv.invited = append(conv.invited, c.User_id)
 		}
-		if result {
-			resultCustomerSlice = append(resultCustomerSlice, customer.User_id)
+	}
+	return conv, nil
+}
+
+// The outcome of an invite run
+type inviteResult struct {
+	Customers  []Customer  `json:"customers"`
+	Duplicates []Duplicate `json:"duplicates"`
+	// Customers grouped by geohash prefix, when requested
+	Groups []GeohashGroup `json:"groups,omitempty"`
+	// Bearing, compass direction and midpoint of the customers from the office, when requested
+	Directions []Direction `json:"directions,omitempty"`
+	// Nearest town of the customers, when requested
+	Towns []Town `json:"towns,omitempty"`
+	// Customers grouped by nearest town, when requested
+	TownGroups []TownGroup `json:"town_groups,omitempty"`
+	// File and line of the record kept for each customer, by user id, when requested
+	Provenance map[int]Provenance `json:"provenance,omitempty"`
+	policy     DuplicatePolicy
+	distances  Range
+	// File and line of the record kept for every parsed customer
+	provenance map[int]Provenance
+}
+
+// Implement MarshalJSON so that the result stays the historical array of customers under the reject policy
+// (where a run has no duplicates), and becomes {"customers": [...], "duplicates": [...]} under the other policies or
+// when the customers are grouped by geohash or town or their directions, towns or provenance are requested, under
+// "groups", "town_groups", "directions", "towns" and "provenance"
+func (r *inviteResult) MarshalJSON() ([]byte, error) {
+	if r.policy == DuplicateReject && r.Groups == nil && r.Directions == nil && r.Towns == nil && r.TownGroups == nil && r.Provenance == nil {
+		return json.Marshal(&r.Customers)
+	}
+	type plain inviteResult
+	p := plain(*r)
+	if p.Customers == nil {
+		p.Customers = []Customer{}
+	}
+	if p.Duplicates == nil {
+		p.Duplicates = []Duplicate{}
+	}
+	return json.Marshal(&p)
+}
+
+// Parse the customer files and return the customers within r to invite sorted by user id, observing the request metrics
+func processCustomerFiles(ctx context.Context, files []util.UploadedFile, policy DuplicatePolicy, r Range, progress func(processed int, total int)) (*inviteResult, error) {
+	size := 0
+	for _, file := range files {
+		size += len(file.Content)
+	}
+	uploadBytes.Observe(float64(size))
+
+	lines := splitFiles(files)
+	conv, err := convertAndSelectCustomers(ctx, lines, policy, &r, progress)
+	if nil != err {
+		if ctx.Err() == nil {
+			requestsFailed.Inc()
 		}
+		return nil, err
 	}
+	customers, resultCustomerSlice := conv.customers, conv.invited
+	customersParsed.Observe(float64(len(customers)))
+	//every record is valid once the request succeeds, the records rejected are the duplicates which are not kept
+	customersRejected.Observe(float64(len(lines) - len(customers)))
+	customersInvited.Observe(float64(len(resultCustomerSlice)))
+	logger.Debug(ctx, "Parsed customers", "files", len(files), "count", len(customers), "duplicates", len(conv.duplicates))
 
 	//sort the result slice
 	sort.Ints(resultCustomerSlice)
@@ -187,17 +326,277 @@ func GetCustomers(w http.ResponseWriter, r *http.Request) error {
 	for _, key := range resultCustomerSlice {
 		sortedResultCustomerSlice = append(sortedResultCustomerSlice, customers[key])
 	}
+	logger.Info(ctx, "Invited customers", "parsed", len(customers), "invited", len(sortedResultCustomerSlice))
+	return &inviteResult{Customers: sortedResultCustomerSlice, Duplicates: conv.duplicates, policy: policy, distances: r, provenance: conv.provenance}, nil
+}
 
-	//return results in JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// Notifier pushing the results of the runs to the webhooks, nil when there is no webhook
+var Webhooks *webhook.Notifier
+
+// Push the results of the runs with n
+func SetWebhooks(n *webhook.Notifier) {
+	Webhooks = n
+}
 
-	resp, err := json.Marshal(&sortedResultCustomerSlice)
+// Push the JSON result of a run of the given kind to the webhooks. The ID of the run is the request ID of ctx
+func notifyWebhooks(ctx context.Context, kind string, result []byte) {
+	if Webhooks != nil {
+		Webhooks.Notify(ctx, webhook.Event{Kind: kind, ID: logger.RequestID(ctx), Result: result})
+	}
+}
+
+// The query parameters of an invite request kept with its job: the duplicate policy and the range
+var jobQueryParameters = []string{"duplicates", "max_distance", "unit", "earth_radius", "eligibility", "max_time"}
+
+// The input stored with an invite job: the customer files of the request, with their names, and the query parameters
+// selecting its duplicate policy and range
+type jobInput struct {
+	Files []util.UploadedFile `json:"files"`
+	Query string              `json:"query,omitempty"`
+}
+
+// Return the customer files and the query parameters of the input of an invite job. The jobs stored before the files
+// were kept by name have the content of a single file as input, and no query parameters
+func decodeJobInput(input []byte) ([]util.UploadedFile, url.Values, error) {
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.DisallowUnknownFields()
+	var decoded jobInput
+	if err := decoder.Decode(&decoded); err != nil || decoded.Files == nil {
+		return []util.UploadedFile{{Content: input}}, url.Values{}, nil
+	}
+	query, err := url.ParseQuery(decoded.Query)
 	if nil != err {
-		return err
+		return nil, nil, err
+	}
+	return decoded.Files, query, nil
+}
+
+// Process the customer files of an asynchronous invite job with the duplicate policy and range of its request, the
+// defaults for the ones it did not select, and return the result in JSON
+func ProcessInviteJob(ctx context.Context, input []byte, progress func(processed int, total int)) ([]byte, error) {
+	files, query, err := decodeJobInput(input)
+	if nil != err {
+		return nil, err
+	}
+	policy, err := ParseDuplicatePolicy(query.Get("duplicates"))
+	if nil != err {
+		return nil, err
+	}
+	distances, err := ParseRange(query)
+	if nil != err {
+		return nil, err
+	}
+	result, err := processCustomerFiles(ctx, files, policy, distances, progress)
+	if nil != err {
+		return nil, err
+	}
+	resp, err := json.Marshal(result)
+	if nil != err {
+		return nil, err
 	}
+	notifyWebhooks(ctx, webhook.KindJob, resp)
+	return resp, nil
+}
+
+// Run the invite calculation of a schedule on the customer file fetched from its source, the result is pushed to
+// the webhooks
+func RunSchedule(ctx context.Context, config schedule.Config) ([]schedule.Invitee, error) {
+	if Sources == nil {
+		return nil, errors.New("Cannot fetch " + config.Source + " : no source is allowed")
+	}
+	policy, err := ParseDuplicatePolicy(config.Duplicates)
+	if nil != err {
+		return nil, err
+	}
+	distances, err := ScheduleRange(config)
+	if nil != err {
+		return nil, err
+	}
+	file, err := Sources.Fetch(ctx, config.Source)
+	if nil != err {
+		return nil, err
+	}
+	result, err := processCustomerFiles(ctx, []util.UploadedFile{file}, policy, distances, nil)
+	if nil != err {
+		return nil, err
+	}
+	if Webhooks != nil {
+		resp, err := json.Marshal(result)
+		if nil != err {
+			return nil, err
+		}
+		notifyWebhooks(ctx, webhook.KindRun, resp)
+	}
+	invitees := make([]schedule.Invitee, len(result.Customers))
+	for i, customer := range result.Customers {
+		invitees[i] = schedule.Invitee{User_id: customer.User_id, Name: customer.Name}
+	}
+	return invitees, nil
+}
+
+// Name of the multipart form field holding the customer files
+var FormField = "customerFile"
+
+// Set the name of the multipart form field holding the customer files
+func SetFormField(name string) error {
+	if name == "" {
+		return errors.New("Form field name must not be empty")
+	}
+	FormField = name
+	return nil
+}
+
+// Fetcher of the customer files referenced by a {"source": ...} body, nil when references are not accepted
+var Sources *source.Fetcher
+
+// Accept {"source": ...} bodies, fetched with f
+func SetSources(f *source.Fetcher) {
+	Sources = f
+}
+
+// Description of the {"source": ...} body for the error messages listing the accepted inputs
+const sourceInput = "an application/json body {\"source\": \"file:///...\" or \"https://...\"}"
+
+// Maximum size of a {"source": ...} body
+const maxSourceBodySize = 64 << 10
+
+// Read the customer files of a request: uploaded (see util.GetUpload) or, if Sources is set, fetched from the
+// reference given in an application/json body
+func getCustomerFiles(r *http.Request) ([]util.UploadedFile, error) {
+	if Sources == nil {
+		return util.GetUpload(r, FormField)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return util.GetUpload(r, FormField, sourceInput)
+	}
+
+	var reference struct {
+		Source string `json:"source"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxSourceBodySize)).Decode(&reference); nil != err {
+		return nil, util.NewHTTPError(http.StatusBadRequest, "Invalid source reference: "+err.Error()+". "+util.AcceptedInputs(FormField, sourceInput))
+	}
+	if reference.Source == "" {
+		return nil, util.NewHTTPError(http.StatusBadRequest, "Missing source in the reference. "+util.AcceptedInputs(FormField, sourceInput))
+	}
+	file, err := Sources.Fetch(r.Context(), reference.Source)
+	if nil != err {
+		return nil, err
+	}
+	logger.Info(r.Context(), "Fetched customer file", "source", reference.Source, "bytes", len(file.Content))
+	return []util.UploadedFile{file}, nil
+}
+
+// Read the customer files of an invite job request as getCustomerFiles does, so that the asynchronous endpoint accepts
+// the same inputs as GetCustomers. The files are stored with the job by name, so that its errors name them as the
+// errors of GetCustomers do. The "duplicates" and range query parameters are checked and stored with the job, so that
+// it runs with the settings of its request
+func ReadInviteJobInput(r *http.Request) ([]byte, error) {
+	if _, err := ParseDuplicatePolicy(r.URL.Query().Get("duplicates")); nil != err {
+		return nil, err
+	}
+	if _, err := ParseRange(r.URL.Query()); nil != err {
+		return nil, err
+	}
+	query := url.Values{}
+	for _, name := range jobQueryParameters {
+		if value := r.URL.Query().Get(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	files, err := getCustomerFiles(r)
+	if nil != err {
+		return nil, err
+	}
+	return json.Marshal(jobInput{Files: files, Query: query.Encode()})
+}
+
+// Check the method of an invite request, read its customer files and process them with the duplicate policy
+// selected by the "duplicates" query parameter and the range selected by ParseRange. The invited customers are grouped
+// by the geohash prefix of the length given in the "group_by_geohash" query parameter, if any, and their directions
+// from the office are added with "directions=true". Their nearest towns are added with "towns=true" and they are
+// grouped by nearest town with "group_by_town=true". The file and line of their records are added with
+// "provenance=true"
+func processInviteRequest(r *http.Request) (*inviteResult, error) {
+	if http.MethodPut != r.Method && http.MethodPost != r.Method {
+		return nil, util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a PUT or POST request")
+	}
+	policy, err := ParseDuplicatePolicy(r.URL.Query().Get("duplicates"))
+	if nil != err {
+		return nil, err
+	}
+	distances, err := ParseRange(r.URL.Query())
+	if nil != err {
+		return nil, err
+	}
+	precision, err := ParseGeohashPrecision(r.URL.Query().Get("group_by_geohash"))
+	if nil != err {
+		return nil, err
+	}
+	directions, err := ParseDirections(r.URL.Query().Get("directions"))
+	if nil != err {
+		return nil, err
+	}
+	towns, err := ParseTowns(r.URL.Query().Get("towns"))
+	if nil != err {
+		return nil, err
+	}
+	byTown, err := ParseGroupByTown(r.URL.Query().Get("group_by_town"))
+	if nil != err {
+		return nil, err
+	}
+	provenance, err := parseBoolParameter("provenance", r.URL.Query().Get("provenance"))
+	if nil != err {
+		return nil, err
+	}
+	files, err := getCustomerFiles(r)
+	if nil != err {
+		return nil, err
+	}
+	result, err := processCustomerFiles(r.Context(), files, policy, distances, nil)
+	if nil != err {
+		return nil, err
+	}
+	if precision > 0 {
+		result.Groups = groupByGeohash(result.Customers, precision)
+	}
+	if directions {
+		result.Directions = customerDirections(result.Customers, distances)
+	}
+	if towns {
+		result.Towns = customerTowns(result.Customers, distances)
+	}
+	if byTown {
+		result.TownGroups = groupByTown(result.Customers)
+	}
+	if provenance {
+		result.Provenance = make(map[int]Provenance, len(result.Customers))
+		for _, c := range result.Customers {
+			result.Provenance[c.User_id] = result.provenance[c.User_id]
+		}
+	}
+	return result, nil
+}
+
+// Entry point of the customer service. The customers are either uploaded in a multipart form, where every FormField
+// part is read (gzip and zip are accepted) and the files are merged in order, sent as a raw JSON Lines body, or
+// fetched from the file:// or http(s):// URL given in a {"source": ...} body.
+// The duplicate policy can be selected with the "duplicates" query parameter, the range with the "max_distance",
+// "unit" and "earth_radius" ones
+func GetCustomers(w http.ResponseWriter, r *http.Request) error {
 
-	w.Write(resp)
+	result, err := processInviteRequest(r)
+	if nil != err {
+		return err
+	}
 
+	//return results in JSON
+	resp, err := json.Marshal(result)
+	if nil != err {
+		return err
+	}
+	notifyWebhooks(r.Context(), webhook.KindRequest, resp)
+	util.WriteRawJSON(w, http.StatusOK, resp)
 	return nil
 }
@@ -1,15 +1,36 @@
 package customer_service
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/event"
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/invitation"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/jsonl"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/road"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/rsvp"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/source"
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/webhook"
 )
 
 type shouldInviteCustomerTest struct {
@@ -33,7 +54,7 @@ var shouldInviteCustomerTests []shouldInviteCustomerTest = []shouldInviteCustome
 func TestShouldInviteCustomer(t *testing.T) {
 	var c Customer
 	for _, test := range shouldInviteCustomerTests {
-		if b, err := c.shouldInviteCustomer(test.distance); b != test.expected || (err != nil && err.Error() != test.err.Error()) {
+		if b, err := c.shouldInviteCustomer(test.distance, 100); b != test.expected || (err != nil && err.Error() != test.err.Error()) {
 			t.Errorf("Output %v not equal to expected %v", b, test.expected)
 		}
 	}
@@ -77,6 +98,10 @@ var hasRequiredKeyTests []hasRequiredKeyTest = []hasRequiredKeyTest{
 	{map[string]interface{}{"latitude": "", "longitude": ""}, false},
 	{map[string]interface{}{"latitude": "", "longitude": "", "user_id": ""}, false},
 	{map[string]interface{}{"latitude": "", "longitude": "", "user_id": "", "name": ""}, true},
+	{map[string]interface{}{"latitude": "", "user_id": "", "name": ""}, false},
+	{map[string]interface{}{"geohash": "", "user_id": "", "name": ""}, true},
+	{map[string]interface{}{"plus_code": "", "user_id": "", "name": ""}, true},
+	{map[string]interface{}{"plus_code": "", "user_id": ""}, false},
 }
 
 func TestHasRequiredKeys(t *testing.T) {
@@ -95,32 +120,44 @@ type unmarshalJSONTest struct {
 
 var unmarshalJSONTests []unmarshalJSONTest = []unmarshalJSONTest{
 	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		""},
 	unmarshalJSONTest{"{\"latitude\": \"u\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		strconv.ErrSyntax.Error()},
 	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"iii\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		strconv.ErrSyntax.Error()},
 	unmarshalJSONTest{"{\"latitude\": \"-91\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
-		"Invalid longitude or latitude"},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
+		"Invalid latitude -91, expected a value between -90 and 90"},
 	unmarshalJSONTest{"{\"latitude\": \"-90\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"181\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
-		"Invalid longitude or latitude"},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
+		"Invalid longitude 181, expected a value between -180 and 180"},
 	unmarshalJSONTest{"{\"latitude\": 52.986375, \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		"Cannot convert latitude"},
 	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": -6.043701}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		"Cannot convert longitude"},
 	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": \"12\", \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		"Cannot convert user_id"},
 	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": 34534, \"longitude\": \"-6.043701\"}",
-		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375))},
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
 		"Cannot convert name"},
+	unmarshalJSONTest{"{\"latitude\": \"52°59'10.95\\\"N\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"6°2'37.32\\\"W\"}",
+		Customer{"52°59'10.95\"N", 12, "Christina McArdle", "6°2'37.32\"W", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043700), greatCircle.DegreeToRadian(52.986375)), 0},
+		""},
+	unmarshalJSONTest{"{\"latitude\": \"52.986375E\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}",
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
+		"expected the hemisphere N or S"},
+	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\", \"priority\": \"high\"}",
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 0},
+		"Cannot convert priority"},
+	unmarshalJSONTest{"{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\", \"priority\": 3}",
+		Customer{"52.986375", 12, "Christina McArdle", "-6.043701", greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.043701), greatCircle.DegreeToRadian(52.986375)), 3},
+		""},
 }
 
 func TestUnmarshalJSON(t *testing.T) {
@@ -147,22 +184,34 @@ type convertToCustomersTest struct {
 }
 
 var convertToCustomersTests []convertToCustomersTest = []convertToCustomersTest{
-	convertToCustomersTest{"", map[int]Customer{}, "Invalid JSON"},
-	convertToCustomersTest{"jkhk", map[int]Customer{}, "Invalid JSON"},
+	convertToCustomersTest{"", map[int]Customer{}, ""},
+	convertToCustomersTest{"jkhk", map[int]Customer{}, "Line 1: Invalid JSON"},
+	//blank lines, trailing newline, CRLF, BOM and comments are tolerated
+	convertToCustomersTest{"\xef\xbb\xbf# customers\r\n{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}\r\n\r\n",
+		map[int]Customer{1: Customer{"51.92893", 1, "Alice Cahill", "-10.27699", greatCircle.MakePoint(greatCircle.DegreeToRadian(-10.27699), greatCircle.DegreeToRadian(51.92893)), 0}},
+		""},
+	//line numbers count the skipped lines
+	convertToCustomersTest{"// comment\n\n{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}\njkhk\n",
+		map[int]Customer{}, "Line 4: Invalid JSON: jkhk"},
+	convertToCustomersTest{"{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}\n\n{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}",
+		map[int]Customer{}, "Line 3: Customer id overlap: 1"},
 	convertToCustomersTest{"{ \"longitude\": 56 }", map[int]Customer{}, "Cannot unmarshal customer"},
 	convertToCustomersTest{"{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}\n{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}",
 		map[int]Customer{}, "Customer id overlap"},
 	convertToCustomersTest{"{\"latitude\": \"51.92893\", \"user_id\": 1, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}\n{\"latitude\": \"51.92893\", \"user_id\": 2, \"name\": \"Alice Cahill\", \"longitude\": \"-10.27699\"}",
-		map[int]Customer{1: Customer{"51.92893", 1, "Alice Cahill", "-10.27699", greatCircle.Point{greatCircle.DegreeToRadian(-10.27699), greatCircle.DegreeToRadian(51.92893)}},
-			2: Customer{"51.92893", 2, "Alice Cahill", "-10.27699", greatCircle.Point{greatCircle.DegreeToRadian(-10.27699), greatCircle.DegreeToRadian(51.92893)}}},
+		map[int]Customer{1: Customer{"51.92893", 1, "Alice Cahill", "-10.27699", greatCircle.MakePoint(greatCircle.DegreeToRadian(-10.27699), greatCircle.DegreeToRadian(51.92893)), 0},
+			2: Customer{"51.92893", 2, "Alice Cahill", "-10.27699", greatCircle.MakePoint(greatCircle.DegreeToRadian(-10.27699), greatCircle.DegreeToRadian(51.92893)), 0}},
 		""},
 }
 
 func TestConvertToCustomers(t *testing.T) {
 	for _, test := range convertToCustomersTests {
 		m, err := convertToCustomers([]byte(test.input))
+		if err == nil && test.errString != "" {
+			t.Errorf("Expected error %v but got none", test.errString)
+		}
 		if err != nil {
-			if !strings.Contains(err.Error(), test.errString) {
+			if test.errString == "" || !strings.Contains(err.Error(), test.errString) {
 				t.Errorf("Output error %v is not the same as expected error %v", err.Error(), test.errString)
 			}
 		} else {
@@ -184,11 +233,11 @@ type getCustomerTest struct {
 
 var getCustomerTests []getCustomerTest = []getCustomerTest{
 	//Test incorrect method
-	getCustomerTest{"POST", "HTTP request is not a PUT request", "", "", ""},
-	getCustomerTest{"GET", "HTTP request is not a PUT request", "", "", ""},
-	getCustomerTest{"DELETE", "HTTP request is not a PUT request", "", "", ""},
+	getCustomerTest{"GET", "HTTP request is not a PUT or POST request", "", "", ""},
+	getCustomerTest{"DELETE", "HTTP request is not a PUT or POST request", "", "", ""},
 	//Test incorrect field name for the uploaded file
 	getCustomerTest{"PUT", "no such file", "", "", ""},
+	getCustomerTest{"POST", "No customer file found in the form field \"customerFile\"", "", "other", ""},
 	//Test content validity
 	getCustomerTest{"PUT", "Invalid JSON", "cdsc", "customerFile", ""},
 	getCustomerTest{"PUT", "Cannot unmarshal customer", "{ \"longitude\": 56 }", "customerFile", ""},
@@ -199,10 +248,12 @@ var getCustomerTests []getCustomerTest = []getCustomerTest{
 		"[{\"User_id\":1,\"Name\":\"user1\"}]"},
 	getCustomerTest{"PUT", "", "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n{\"latitude\": \"0\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"0\"}", "customerFile",
 		"[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"}]"},
+	getCustomerTest{"POST", "", "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}", "customerFile",
+		"[{\"User_id\":1,\"Name\":\"user1\"}]"},
 }
 
 func TestGetCustomer(t *testing.T) {
-	filePath := "getCustomerTest.txt"
+	filePath := filepath.Join(t.TempDir(), "getCustomerTest.txt")
 
 	for _, test := range getCustomerTests {
 		body, contentType, err := util.GetByteBuffer(filePath, test.fieldName, test.content)
@@ -224,3 +275,1041 @@ func TestGetCustomer(t *testing.T) {
 
 	}
 }
+
+type checkOfficeLocationTest struct {
+	longitude, latitude float64
+	errString           string
+}
+
+var checkOfficeLocationTests []checkOfficeLocationTest = []checkOfficeLocationTest{
+	checkOfficeLocationTest{-6.257664, 53.339428, ""},
+	checkOfficeLocationTest{-6.257664, 95, "Office location has not been set"},
+	checkOfficeLocationTest{0, 0, ""},
+}
+
+func TestCheckOfficeLocation(t *testing.T) {
+	defer SetOfficeLocation(0, 0)
+	for _, test := range checkOfficeLocationTests {
+		SetOfficeLocation(test.longitude, test.latitude)
+		err := CheckOfficeLocation()
+		if (err == nil && test.errString != "") || (err != nil && err.Error() != test.errString) {
+			t.Errorf("Output error %v is not the same as expected %v", err, test.errString)
+		}
+	}
+}
+
+type processInviteJobTest struct {
+	input     string
+	result    string
+	processed int
+	errString string
+}
+
+var processInviteJobTests []processInviteJobTest = []processInviteJobTest{
+	processInviteJobTest{"{\"latitude\": \"0\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"0\"}\n{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}",
+		"[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"}]", 2, ""},
+	processInviteJobTest{"{\"latitude\": \"80\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"100\"}", "null", 1, ""},
+	processInviteJobTest{"jkhk", "", 1, "Invalid JSON"},
+}
+
+func TestProcessInviteJob(t *testing.T) {
+	for _, test := range processInviteJobTests {
+		processed := 0
+		b, err := ProcessInviteJob(context.Background(), []byte(test.input), func(p int, total int) { processed = p })
+		if err != nil && !strings.Contains(err.Error(), test.errString) {
+			t.Errorf("Output error %v is not the same as expected %v", err.Error(), test.errString)
+		}
+		if string(b) != test.result {
+			t.Errorf("Output result %v is not the same as expected %v", string(b), test.result)
+		}
+		if processed != test.processed {
+			t.Errorf("Output processed lines %v is not the same as expected %v", processed, test.processed)
+		}
+	}
+}
+
+// Generate a customer file of n lines spread around the office at 0,0
+func generateCustomerFile(n int) []byte {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("{\"latitude\": \"" + strconv.FormatFloat(float64(i%180)-89.5, 'f', 4, 64) + "\", \"user_id\": " + strconv.Itoa(n-i) +
+			", \"name\": \"user" + strconv.Itoa(i) + "\", \"longitude\": \"" + strconv.FormatFloat(float64(i%360)/1000, 'f', 4, 64) + "\"}")
+	}
+	return []byte(b.String())
+}
+
+func TestPipelineWorkers(t *testing.T) {
+	defer SetWorkers(Workers)
+	input := generateCustomerFile(5 * chunkSize)
+	//an error far in the file must be reported whatever the scheduling
+	invalid := append(append([]byte{}, input...), []byte("\n{\"user_id\": 1}\njkhk")...)
+
+	var expected []Customer
+	for _, workers := range []int{1, 2, 3, 8} {
+		if err := SetWorkers(workers); err != nil {
+			t.Fatal(err)
+		}
+		result, err := processCustomerFiles(context.Background(), []util.UploadedFile{util.UploadedFile{Name: "input.txt", Content: input}}, DuplicateReject, DefaultRange, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = result.Customers
+		} else if !reflect.DeepEqual(result.Customers, expected) {
+			t.Errorf("Output with %v workers is not the same as with 1 worker", workers)
+		}
+
+		if _, err := processCustomerFiles(context.Background(), []util.UploadedFile{util.UploadedFile{Name: "invalid.txt", Content: invalid}}, DuplicateReject, DefaultRange, nil); err == nil || !strings.Contains(err.Error(), "Cannot unmarshal customer {\"user_id\": 1}") {
+			t.Errorf("Output error %v with %v workers is not the first error of the file", err, workers)
+		}
+	}
+	if len(expected) == 0 {
+		t.Errorf("Expected some customers to be invited")
+	}
+	if err := SetWorkers(0); err == nil {
+		t.Errorf("Expected an error for 0 workers")
+	}
+}
+
+func TestPipelineParseOnly(t *testing.T) {
+	defer SetWorkers(Workers)
+	lines := jsonl.Split(generateCustomerFile(3 * chunkSize))
+	for _, workers := range []int{1, 3} {
+		SetWorkers(workers)
+		results, err := runPipeline(context.Background(), lines, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(lines) {
+			t.Fatalf("Output %v lines, expected %v", len(results), len(lines))
+		}
+		for _, result := range results {
+			if result.err != nil || result.invite || result.customer.Name == "" {
+				t.Fatalf("Output %v, expected a parsed customer which is not invited", result)
+			}
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := processCustomerFiles(ctx, []util.UploadedFile{util.UploadedFile{Name: "input.txt", Content: generateCustomerFile(3 * chunkSize)}}, DuplicateReject, DefaultRange, nil); err != context.Canceled {
+		t.Errorf("Output error %v is not the same as expected %v", err, context.Canceled)
+	}
+}
+
+// Run the whole parse and invite process on a large file with the given number of workers
+func benchmarkProcessCustomerFile(b *testing.B, workers int) {
+	defer SetWorkers(Workers)
+	defer logger.SetDefault(logger.Default())
+	SetWorkers(workers)
+	logger.SetDefault(logger.New(io.Discard, logger.LevelError))
+	input := generateCustomerFile(100000)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := processCustomerFiles(context.Background(), []util.UploadedFile{util.UploadedFile{Name: "input.txt", Content: input}}, DuplicateReject, DefaultRange, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessCustomerFile1(b *testing.B) { benchmarkProcessCustomerFile(b, 1) }
+func BenchmarkProcessCustomerFile2(b *testing.B) { benchmarkProcessCustomerFile(b, 2) }
+func BenchmarkProcessCustomerFile4(b *testing.B) { benchmarkProcessCustomerFile(b, 4) }
+func BenchmarkProcessCustomerFile8(b *testing.B) { benchmarkProcessCustomerFile(b, 8) }
+
+type parseDuplicatePolicyTest struct {
+	input     string
+	expected  DuplicatePolicy
+	errString string
+}
+
+var parseDuplicatePolicyTests []parseDuplicatePolicyTest = []parseDuplicatePolicyTest{
+	parseDuplicatePolicyTest{"", DuplicateReject, ""},
+	parseDuplicatePolicyTest{"reject", DuplicateReject, ""},
+	parseDuplicatePolicyTest{"keep-first", DuplicateKeepFirst, ""},
+	parseDuplicatePolicyTest{"keep-last", DuplicateKeepLast, ""},
+	parseDuplicatePolicyTest{"merge-if-identical", DuplicateMergeIfIdentical, ""},
+	parseDuplicatePolicyTest{"report-and-skip", DuplicateReportAndSkip, ""},
+	parseDuplicatePolicyTest{"keep-any", DuplicateReject, "Invalid duplicate policy keep-any"},
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, test := range parseDuplicatePolicyTests {
+		policy, err := ParseDuplicatePolicy(test.input)
+		if err != nil && !strings.Contains(err.Error(), test.errString) {
+			t.Errorf("Output error %v is not the same as expected %v", err.Error(), test.errString)
+		}
+		if policy != test.expected {
+			t.Errorf("Output %v not equal to expected %v", policy, test.expected)
+		}
+	}
+}
+
+const (
+	//compact JSON, as records are compacted in the response
+	duplicateLine1 = "{\"latitude\":\"0\",\"user_id\":1,\"name\":\"user1\",\"longitude\":\"0\"}"
+	duplicateLine2 = "{\"latitude\":\"0\",\"user_id\":1,\"name\":\"other\",\"longitude\":\"0\"}"
+	duplicateLine3 = "{\"latitude\":\"0\",\"user_id\":2,\"name\":\"user2\",\"longitude\":\"0\"}"
+	//duplicateLine1 with other notations of its location, or with a priority
+	duplicateLine1Notation = "{\"latitude\":\"0.000\",\"user_id\":1,\"name\":\"user1\",\"longitude\":\"0°0'0\\\"E\"}"
+	duplicateLine1Priority = "{\"latitude\":\"0\",\"user_id\":1,\"name\":\"user1\",\"longitude\":\"0\",\"priority\":2}"
+)
+
+type duplicatePolicyTest struct {
+	policy    string
+	content   string
+	errString string
+	result    string
+}
+
+var duplicatePolicyTests []duplicatePolicyTest = []duplicatePolicyTest{
+	duplicatePolicyTest{"", duplicateLine1 + "\n" + duplicateLine2, "getCustomerTest.txt: Line 2: Customer id overlap: 1 (getCustomerTest.txt: Line 1: " + duplicateLine1 + ", getCustomerTest.txt: Line 2: " + duplicateLine2 + ")", ""},
+	duplicatePolicyTest{"unknown", duplicateLine1, "Invalid duplicate policy", ""},
+	duplicatePolicyTest{"keep-first", duplicateLine1 + "\n" + duplicateLine3 + "\n" + duplicateLine2, "",
+		"{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"}],\"duplicates\":[{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":3,\"record\":" + duplicateLine2 + "},\"resolution\":\"kept-first\"}]}"},
+	duplicatePolicyTest{"keep-last", duplicateLine1 + "\n" + duplicateLine2, "",
+		"{\"customers\":[{\"User_id\":1,\"Name\":\"other\"}],\"duplicates\":[{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":2,\"record\":" + duplicateLine2 + "},\"resolution\":\"kept-last\"}]}"},
+	duplicatePolicyTest{"merge-if-identical", duplicateLine1 + "\n" + duplicateLine1, "",
+		"{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"}],\"duplicates\":[{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":2,\"record\":" + duplicateLine1 + "},\"resolution\":\"merged\"}]}"},
+	duplicatePolicyTest{"merge-if-identical", duplicateLine1 + "\n" + duplicateLine2, "Line 2: Customer id overlap with different records: 1", ""},
+	//the locations are compared once parsed
+	duplicatePolicyTest{"merge-if-identical", duplicateLine1 + "\n" + duplicateLine1Notation, "",
+		"{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"}],\"duplicates\":[{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":2,\"record\":" + duplicateLine1Notation + "},\"resolution\":\"merged\"}]}"},
+	duplicatePolicyTest{"merge-if-identical", duplicateLine1 + "\n" + duplicateLine1Priority, "Line 2: Customer id overlap with different records: 1", ""},
+	duplicatePolicyTest{"report-and-skip", duplicateLine1 + "\n" + duplicateLine2 + "\n" + duplicateLine1, "",
+		"{\"customers\":[],\"duplicates\":[{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":2,\"record\":" + duplicateLine2 + "},\"resolution\":\"skipped\"}," +
+			"{\"user_id\":1,\"first\":{\"source\":\"getCustomerTest.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"getCustomerTest.txt\",\"line\":3,\"record\":" + duplicateLine1 + "},\"resolution\":\"skipped\"}]}"},
+	//no duplicate with a policy other than reject
+	duplicatePolicyTest{"keep-first", duplicateLine3, "", "{\"customers\":[{\"User_id\":2,\"Name\":\"user2\"}],\"duplicates\":[]}"},
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "getCustomerTest.txt")
+
+	for _, test := range duplicatePolicyTests {
+		body, contentType, err := util.GetByteBuffer(filePath, "customerFile", test.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("PUT", "/v1/customer?duplicates="+test.policy, body)
+		req.Header.Add("Content-Type", contentType)
+		writer := httptest.NewRecorder()
+
+		err = GetCustomers(writer, req)
+
+		if (err == nil && test.errString != "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v is not the same as expected %v", err, test.errString)
+		}
+		if result := writer.Body.String(); result != test.result {
+			t.Errorf("Output result %v is not the same as expected %v", result, test.result)
+		}
+	}
+}
+
+func TestRejectedMetrics(t *testing.T) {
+	failed, rejected := requestsFailed.Value(), customersRejected.Count()
+	if _, err := processCustomerFiles(context.Background(), []util.UploadedFile{{Content: []byte(duplicateLine1 + "\n" + duplicateLine2)}}, DuplicateReject, DefaultRange, nil); err == nil {
+		t.Fatal("Expected an error for the conflicting records")
+	}
+	if requestsFailed.Value() != failed+1 || customersRejected.Count() != rejected {
+		t.Errorf("Output %v failed requests and %v rejected observations, expected %v and %v", requestsFailed.Value(), customersRejected.Count(), failed+1, rejected)
+	}
+	if _, err := processCustomerFiles(context.Background(), []util.UploadedFile{{Content: []byte(duplicateLine1 + "\n" + duplicateLine2)}}, DuplicateKeepFirst, DefaultRange, nil); err != nil {
+		t.Fatal(err)
+	}
+	if requestsFailed.Value() != failed+1 || customersRejected.Count() != rejected+1 {
+		t.Errorf("Output %v failed requests and %v rejected observations, expected %v and %v", requestsFailed.Value(), customersRejected.Count(), failed+1, rejected+1)
+	}
+}
+
+func TestGetCustomersMultipleFiles(t *testing.T) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for _, file := range []util.UploadedFile{{Name: "a.txt", Content: []byte(duplicateLine1)}, {Name: "b.txt", Content: []byte(duplicateLine3 + "\n" + duplicateLine2)}} {
+		w, err := mw.CreateFormFile("customerFile", file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(file.Content)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("PUT", "/v1/customer?duplicates=keep-last", body)
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+	writer := httptest.NewRecorder()
+	if err := GetCustomers(writer, req); err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\"customers\":[{\"User_id\":1,\"Name\":\"other\"},{\"User_id\":2,\"Name\":\"user2\"}],\"duplicates\":[{\"user_id\":1," +
+		"\"first\":{\"source\":\"a.txt\",\"line\":1,\"record\":" + duplicateLine1 + "},\"second\":{\"source\":\"b.txt\",\"line\":2,\"record\":" + duplicateLine2 + "},\"resolution\":\"kept-last\"}]}"
+	if result := writer.Body.String(); result != expected {
+		t.Errorf("Output result %v is not the same as expected %v", result, expected)
+	}
+
+	//the file and line of the kept records on request
+	body = new(bytes.Buffer)
+	mw = multipart.NewWriter(body)
+	for _, file := range []util.UploadedFile{{Name: "a.txt", Content: []byte(duplicateLine1)}, {Name: "b.txt", Content: []byte("\n" + duplicateLine3)}} {
+		w, err := mw.CreateFormFile("customerFile", file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(file.Content)
+	}
+	mw.Close()
+	req = httptest.NewRequest("PUT", "/v1/customer?provenance=true", body)
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+	writer = httptest.NewRecorder()
+	if err := GetCustomers(writer, req); err != nil {
+		t.Fatal(err)
+	}
+	expected = "{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"}],\"duplicates\":[],\"provenance\":{\"1\":{\"source\":\"a.txt\",\"line\":1},\"2\":{\"source\":\"b.txt\",\"line\":2}}}"
+	if result := writer.Body.String(); result != expected {
+		t.Errorf("Output result %v is not the same as expected %v", result, expected)
+	}
+
+	conv, err := convertAndSelectCustomers(context.Background(), splitFiles([]util.UploadedFile{{Name: "a.txt", Content: []byte(duplicateLine1)}, {Name: "b.txt", Content: []byte("\n" + duplicateLine3)}}), DuplicateReject, &DefaultRange, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedProvenance := map[int]Provenance{1: Provenance{"a.txt", 1}, 2: Provenance{"b.txt", 2}}
+	if !reflect.DeepEqual(conv.provenance, expectedProvenance) {
+		t.Errorf("Output provenance %v is not the same as expected %v", conv.provenance, expectedProvenance)
+	}
+}
+
+func TestReadInviteJobInput(t *testing.T) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for _, file := range []util.UploadedFile{{Name: "a.txt", Content: []byte(duplicateLine1)}, {Name: "b.txt", Content: []byte(duplicateLine3 + "\njkhk")}} {
+		w, err := mw.CreateFormFile("customerFile", file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(file.Content)
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/v2/invite-jobs", body)
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+	input, err := ReadInviteJobInput(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the errors of the job name the file, as the errors of GetCustomers do
+	if _, err := ProcessInviteJob(context.Background(), input, func(int, int) {}); err == nil || err.Error() != "b.txt: Line 2: Invalid JSON: jkhk" {
+		t.Errorf("Output error %v, expected the invalid line of b.txt", err)
+	}
+
+	req = httptest.NewRequest("POST", "/v2/invite-jobs", strings.NewReader(duplicateLine1))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	if input, err = ReadInviteJobInput(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessInviteJob(context.Background(), input, func(int, int) {}); err != nil {
+		t.Errorf("Output error %v, expected the raw body to be processed", err)
+	}
+
+	//the job runs with the duplicate policy and the range of its request
+	SetOfficeLocation(0, 0)
+	req = httptest.NewRequest("POST", "/v2/invite-jobs?duplicates=keep-last&max_distance=1&unit=mi", strings.NewReader(duplicateLine1+"\n"+duplicateLine2))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	if input, err = ReadInviteJobInput(req); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := ProcessInviteJob(context.Background(), input, func(int, int) {}); err != nil || !strings.HasPrefix(string(resp), "{\"customers\":[{\"User_id\":1,\"Name\":\"other\"}]") {
+		t.Errorf("Output %v %v, expected the last record of user 1", string(resp), err)
+	}
+	for _, query := range []string{"?duplicates=first", "?max_distance=-1", "?unit=furlong"} {
+		req = httptest.NewRequest("POST", "/v2/invite-jobs"+query, strings.NewReader(duplicateLine1))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		if _, err := ReadInviteJobInput(req); err == nil {
+			t.Errorf("Output nil for %v, expected an error", query)
+		}
+	}
+}
+
+type rawBodyTest struct {
+	contentType string
+	content     string
+	status      int
+	result      string
+}
+
+var rawBodyTests []rawBodyTest = []rawBodyTest{
+	rawBodyTest{"application/x-ndjson", "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n", http.StatusOK, "[{\"User_id\":1,\"Name\":\"user1\"}]"},
+	rawBodyTest{"text/plain; charset=utf-8", "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}", http.StatusOK, "[{\"User_id\":1,\"Name\":\"user1\"}]"},
+	rawBodyTest{"text/plain", "jkhk", http.StatusBadRequest, "body: Line 1: Invalid JSON: jkhk"},
+	rawBodyTest{"application/json", "{}", http.StatusUnsupportedMediaType, "Unsupported Content-Type application/json. Accepted inputs: a multipart/form-data form with the file(s) in the field \"customerFile\", or a raw body of type application/x-ndjson, application/jsonl, text/plain (optionally with Content-Encoding: gzip)"},
+	rawBodyTest{"", "{}", http.StatusUnsupportedMediaType, "Missing or invalid Content-Type"},
+}
+
+func TestGetCustomersRawBody(t *testing.T) {
+	for _, test := range rawBodyTests {
+		req := httptest.NewRequest("POST", "/v1/customer", strings.NewReader(test.content))
+		if test.contentType != "" {
+			req.Header.Add("Content-Type", test.contentType)
+		}
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetCustomers)(writer, req)
+
+		if writer.Code != test.status {
+			t.Errorf("Output status %v is not the same as expected %v", writer.Code, test.status)
+		}
+		if result := writer.Body.String(); !strings.HasPrefix(result, test.result) {
+			t.Errorf("Output result %v is not the same as expected %v", result, test.result)
+		}
+	}
+}
+
+func TestSetFormField(t *testing.T) {
+	defer SetFormField(FormField)
+	if err := SetFormField(""); err == nil {
+		t.Errorf("Expected an error for an empty form field name")
+	}
+	if err := SetFormField("upload"); err != nil {
+		t.Fatal(err)
+	}
+	body, contentType, err := util.GetByteBuffer(filepath.Join(t.TempDir(), "getCustomerTest.txt"), "upload", "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("PUT", "/v1/customer", body)
+	req.Header.Add("Content-Type", contentType)
+	writer := httptest.NewRecorder()
+	if err := GetCustomers(writer, req); err != nil {
+		t.Errorf("Output error %v, expected the file to be found in the configured field", err)
+	}
+}
+
+func TestGetCustomersSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "customers.txt"), []byte("{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := source.NewFetcher(source.Config{AllowedDirs: []string{dir}, MaxSize: 1 << 10, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSources(sources)
+	defer SetSources(nil)
+
+	var sourceTests []rawBodyTest = []rawBodyTest{
+		rawBodyTest{"application/json", "{\"source\": \"file://" + dir + "/customers.txt\"}", http.StatusOK, "[{\"User_id\":1,\"Name\":\"user1\"}]"},
+		rawBodyTest{"application/json", "{\"source\": \"file:///etc/passwd\"}", http.StatusForbidden, "Source is not allowed"},
+		rawBodyTest{"application/json", "{}", http.StatusBadRequest, "Missing source in the reference"},
+		rawBodyTest{"application/json", "jkhk", http.StatusBadRequest, "Invalid source reference"},
+		rawBodyTest{"application/xml", "{}", http.StatusUnsupportedMediaType, "Unsupported Content-Type application/xml. Accepted inputs: a multipart/form-data form with the file(s) in the field \"customerFile\", or a raw body of type application/x-ndjson, application/jsonl, text/plain (optionally with Content-Encoding: gzip), or an application/json body"},
+	}
+	for _, test := range sourceTests {
+		req := httptest.NewRequest("POST", "/v1/customer", strings.NewReader(test.content))
+		req.Header.Add("Content-Type", test.contentType)
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetCustomers)(writer, req)
+
+		if writer.Code != test.status {
+			t.Errorf("Output status %v is not the same as expected %v", writer.Code, test.status)
+		}
+		if result := writer.Body.String(); !strings.HasPrefix(result, test.result) {
+			t.Errorf("Output result %v is not the same as expected %v", result, test.result)
+		}
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	dir := t.TempDir()
+	content := "{\"latitude\": \"0\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"0\"}\n" +
+		"{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n" +
+		"{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n"
+	if err := os.WriteFile(filepath.Join(dir, "customers.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := schedule.Config{Name: "weekly", Cron: "@weekly", Source: "file://" + dir + "/customers.txt", Duplicates: "merge-if-identical"}
+	if _, err := RunSchedule(context.Background(), config); err == nil {
+		t.Errorf("Expected an error when no source is allowed")
+	}
+
+	sources, err := source.NewFetcher(source.Config{AllowedDirs: []string{dir}, MaxSize: 1 << 10, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetSources(sources)
+	defer SetSources(nil)
+	invitees, err := RunSchedule(context.Background(), config)
+	expected := []schedule.Invitee{schedule.Invitee{User_id: 1, Name: "user1"}, schedule.Invitee{User_id: 2, Name: "user2"}}
+	if err != nil || !reflect.DeepEqual(invitees, expected) {
+		t.Errorf("Output %v (%v) is not the same as expected %v", invitees, err, expected)
+	}
+	config.Duplicates = "reject"
+	if _, err := RunSchedule(context.Background(), config); err == nil {
+		t.Errorf("Expected an error for the duplicate user id")
+	}
+}
+
+func TestGetCustomersWebhook(t *testing.T) {
+	received := make(chan webhook.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhook.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer server.Close()
+	n, err := webhook.NewNotifier([]webhook.Config{{URL: server.URL, Secret: "s3cret"}}, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+	SetWebhooks(n)
+	defer SetWebhooks(nil)
+
+	req := httptest.NewRequest("POST", "/v1/customer", strings.NewReader("{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}"))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	req = req.WithContext(logger.WithRequestID(req.Context(), "request-1"))
+	if err := GetCustomers(httptest.NewRecorder(), req); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-received:
+		if e.Kind != webhook.KindRequest || e.ID != "request-1" || string(e.Result) != "[{\"User_id\":1,\"Name\":\"user1\"}]" {
+			t.Errorf("Output event %v is not the same as expected", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the result to be pushed to the webhook")
+	}
+}
+
+type invitationTest struct {
+	query       string
+	status      int
+	contentType string
+}
+
+var invitationTests []invitationTest = []invitationTest{
+	invitationTest{"", http.StatusOK, "application/zip"},
+	invitationTest{"?format=html", http.StatusOK, "application/zip"},
+	invitationTest{"?format=mbox", http.StatusOK, "application/mbox"},
+	invitationTest{"?format=smtp", http.StatusOK, "application/json"},
+	invitationTest{"?format=pdf", http.StatusBadRequest, ""},
+}
+
+func TestGetInvitations(t *testing.T) {
+	sink, err := invitation.ListenSink("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	SetInvitations(Invitations, sink.Addr())
+	defer SetInvitations(Invitations, "")
+
+	content := "{\"latitude\": \"53.339428\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"latitude\": \"0\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"0\"}\n"
+	for _, test := range invitationTests {
+		req := httptest.NewRequest("POST", "/v1/customer/invitations"+test.query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetInvitations)(writer, req)
+		if writer.Code != test.status || writer.Header().Get("Content-Type") != test.contentType {
+			t.Errorf("Output %v %v for %v is not the same as expected %v %v", writer.Code, writer.Header().Get("Content-Type"), test.query, test.status, test.contentType)
+		}
+	}
+	//only the customer close to the office (0, 0) gets an invitation
+	if messages := sink.Messages(); len(messages) != 1 || messages[0].To[0] != "customer-2@example.invalid" {
+		t.Errorf("Output %v messages, expected the invitation of customer 2", len(messages))
+	}
+}
+
+func TestGetInvitationsRSVP(t *testing.T) {
+	store, err := rsvp.OpenStore(filepath.Join(t.TempDir(), "rsvps.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "{\"latitude\": \"0\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"0\"}\n"
+	request := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/customer/invitations?format=html"+query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetInvitations)(writer, req)
+		return writer
+	}
+	if writer := request("&event=party"); writer.Code != http.StatusNotImplemented {
+		t.Errorf("Output %v without an RSVP store, expected %v", writer.Code, http.StatusNotImplemented)
+	}
+
+	SetRSVP(store, "http://localhost:8081/rsvp")
+	defer SetRSVP(nil, "")
+	if writer := request("&event=a+party"); writer.Code != http.StatusBadRequest {
+		t.Errorf("Output %v for an invalid event, expected %v", writer.Code, http.StatusBadRequest)
+	}
+	writer := request("&event=party")
+	if writer.Code != http.StatusOK {
+		t.Fatalf("Output %v, expected %v", writer.Code, http.StatusOK)
+	}
+	summary, err := store.Event("party")
+	if err != nil || len(summary.Invites) != 1 || summary.Invites[0].User_id != 2 {
+		t.Fatalf("Output %v %v, expected the invite of customer 2", summary, err)
+	}
+	link := "http://localhost:8081/rsvp/" + summary.Invites[0].Token + "?response=accept"
+	z, err := zip.NewReader(bytes.NewReader(writer.Body.Bytes()), int64(writer.Body.Len()))
+	if err != nil || len(z.File) != 1 {
+		t.Fatalf("Output %v, expected a zip of one invitation", err)
+	}
+	f, _ := z.File[0].Open()
+	html, _ := io.ReadAll(f)
+	if !bytes.Contains(html, []byte(link)) {
+		t.Errorf("Output invitation %s without the RSVP link %v", html, link)
+	}
+}
+
+func TestEventCandidates(t *testing.T) {
+	radius := 200.0
+	e := event.Event{Name: "party", Date: "2026-12-18", Office: event.Office{Latitude: 0, Longitude: 0}, Radius: &radius, Unit: greatCircle.Kilometre, Capacity: 1, RankBy: event.RankByPriority}
+	content := "{\"latitude\": \"1\", \"user_id\": 3, \"name\": \"user3\", \"longitude\": \"0\", \"priority\": 2}\n" +
+		"{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n" +
+		"{\"latitude\": \"53.339428\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"-6.257664\", \"priority\": 5}\n"
+	req := httptest.NewRequest("POST", "/v2/events/party/invites", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	candidates, err := EventCandidates(req, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//customer 2 is out of the range of the event, whatever its priority
+	if len(candidates) != 2 || candidates[0].User_id != 1 || candidates[1].User_id != 3 || candidates[1].Priority != 2 {
+		t.Fatalf("Output %v, expected customers 1 and 3", candidates)
+	}
+	invites := e.Select(candidates)
+	if len(invites.Invited) != 1 || invites.Invited[0].User_id != 3 || len(invites.Waitlist) != 1 || invites.Waitlist[0].User_id != 1 {
+		t.Errorf("Output %v, expected customer 3 invited and customer 1 waitlisted", invites)
+	}
+
+	req = httptest.NewRequest("GET", "/v2/events/party/invites", nil)
+	if _, err := EventCandidates(req, e); err == nil {
+		t.Errorf("Expected an error for a GET request")
+	}
+}
+
+type parseRangeTest struct {
+	query     string
+	expected  Range
+	errString string
+}
+
+var parseRangeTests []parseRangeTest = []parseRangeTest{
+	parseRangeTest{"", Range{Max: 100, Unit: greatCircle.Kilometre, EarthRadius: greatCircle.Radius, Eligibility: EligibilityStraight}, ""},
+	parseRangeTest{"max_distance=60&unit=mi", Range{Max: 60, Unit: greatCircle.Mile, EarthRadius: greatCircle.Radius, Eligibility: EligibilityStraight}, ""},
+	parseRangeTest{"max_distance=5000", Range{Max: 5000, Unit: greatCircle.Kilometre, EarthRadius: greatCircle.Radius, Eligibility: EligibilityStraight}, ""},
+	parseRangeTest{"unit=m&earth_radius=equatorial", Range{Max: 100000, Unit: greatCircle.Metre, EarthRadius: greatCircle.EquatorialRadius, Eligibility: EligibilityStraight}, ""},
+	parseRangeTest{"unit=miles", Range{}, "Invalid distance unit"},
+	parseRangeTest{"max_distance=-1", Range{}, "Invalid max_distance"},
+	parseRangeTest{"max_distance=far", Range{}, "Invalid max_distance"},
+	parseRangeTest{"earth_radius=polar", Range{}, "Invalid earth radius"},
+	parseRangeTest{"max_time=30", Range{Max: 100, Unit: greatCircle.Kilometre, EarthRadius: greatCircle.Radius, Eligibility: EligibilityStraight, MaxTime: 30 * time.Minute}, ""},
+	parseRangeTest{"max_time=-1", Range{}, "Invalid max_time"},
+	parseRangeTest{"eligibility=road", Range{}, "needs a road graph"},
+	parseRangeTest{"eligibility=fast", Range{}, "Invalid eligibility"},
+}
+
+func TestParseRange(t *testing.T) {
+	for _, test := range parseRangeTests {
+		query, _ := url.ParseQuery(test.query)
+		r, err := ParseRange(query)
+		if (err == nil) != (test.errString == "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v for %v, expected %v", err, test.query, test.errString)
+		}
+		if err == nil && (r.Unit != test.expected.Unit || r.EarthRadius != test.expected.EarthRadius || math.Abs(r.Max-test.expected.Max) > 1e-9 ||
+			r.Eligibility != test.expected.Eligibility || r.MaxTime != test.expected.MaxTime) {
+			t.Errorf("Output %v for %v, expected %v", r, test.query, test.expected)
+		}
+	}
+}
+
+func TestNewRange(t *testing.T) {
+	max := 50.0
+	negative := -1.0
+	for _, test := range []struct {
+		max       *float64
+		unit      string
+		expected  float64
+		errString string
+	}{
+		{nil, "km", 100, ""},
+		{nil, "mi", greatCircle.Convert(100, greatCircle.Kilometre, greatCircle.Mile), ""},
+		{&max, "mi", 50, ""},
+		{&negative, "km", 0, "Maximum distance"},
+		{nil, "furlong", 0, "unit"},
+	} {
+		r, err := NewRange(test.max, test.unit, "mean", "straight", 0)
+		if (err == nil) != (test.errString == "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v for %v, expected %v", err, test.unit, test.errString)
+		}
+		if err == nil && math.Abs(r.Max-test.expected) > 1e-9 {
+			t.Errorf("Output %v for %v, expected %v", r.Max, test.unit, test.expected)
+		}
+	}
+}
+
+func TestGetCustomersRange(t *testing.T) {
+	//user 1 is about 111 km (69 mi) away from the office (0, 0), user 2 about 157 km (98 mi)
+	SetOfficeLocation(0, 0)
+	defer SetOfficeLocation(-6.257664, 53.339428)
+	content := "{\"latitude\": \"1\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n" +
+		"{\"latitude\": \"1\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"1\"}\n"
+	for query, expected := range map[string]string{
+		"":                          "null",
+		"?unit=mi&max_distance=100": "[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"}]",
+		"?unit=mi&max_distance=70":  "[{\"User_id\":1,\"Name\":\"user1\"}]",
+		"?max_distance=111.2":       "[{\"User_id\":1,\"Name\":\"user1\"}]",
+		"?max_distance=111.2&earth_radius=equatorial": "null",
+	} {
+		req := httptest.NewRequest("POST", "/v1/customer"+query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetCustomers)(writer, req)
+		if writer.Code != http.StatusOK || writer.Body.String() != expected {
+			t.Errorf("Output %v %v for %v, expected %v", writer.Code, writer.Body.String(), query, expected)
+		}
+	}
+}
+
+type locationCodeTest struct {
+	input     string
+	geohash   string
+	errString string
+}
+
+var locationCodeTests []locationCodeTest = []locationCodeTest{
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"geohash\": \"gc7x3\"}", "gc7x3", ""},
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"plus_code\": \"9C5M8PQR+QW\"}", "gc7x3", ""},
+	//the coordinates take precedence over the codes
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"latitude\": \"0\", \"longitude\": \"0\", \"geohash\": \"gc7x3\"}", "s0000", ""},
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"geohash\": \"gc7xa\"}", "", "Invalid geohash"},
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"plus_code\": \"8PQR+QW\"}", "", "short codes are not accepted"},
+	locationCodeTest{"{\"user_id\": 1, \"name\": \"user1\", \"geohash\": 5}", "", "Cannot convert geohash"},
+}
+
+func TestUnmarshalJSONLocationCode(t *testing.T) {
+	for _, test := range locationCodeTests {
+		var c Customer
+		err := json.Unmarshal([]byte(test.input), &c)
+		if (err == nil) != (test.errString == "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v for %v, expected %v", err, test.input, test.errString)
+		}
+		if err == nil && c.Location.Geohash(5) != test.geohash {
+			t.Errorf("Output location %v for %v, expected the geohash %v", c.Location.Geohash(5), test.input, test.geohash)
+		}
+	}
+}
+
+func TestGetCustomersGroupByGeohash(t *testing.T) {
+	content := "{\"latitude\": \"53.339428\", \"user_id\": 3, \"name\": \"user3\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"user_id\": 1, \"name\": \"user1\", \"geohash\": \"gc7x3\"}\n" +
+		"{\"user_id\": 2, \"name\": \"user2\", \"geohash\": \"gc7r\"}\n"
+	for query, expected := range map[string]string{
+		"?group_by_geohash=3": "{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"},{\"User_id\":3,\"Name\":\"user3\"}],\"duplicates\":[]," +
+			"\"groups\":[{\"geohash\":\"gc7\",\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"},{\"User_id\":3,\"Name\":\"user3\"}]}]}",
+		"?group_by_geohash=4": "{\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":2,\"Name\":\"user2\"},{\"User_id\":3,\"Name\":\"user3\"}],\"duplicates\":[]," +
+			"\"groups\":[{\"geohash\":\"gc7r\",\"customers\":[{\"User_id\":2,\"Name\":\"user2\"}]},{\"geohash\":\"gc7x\",\"customers\":[{\"User_id\":1,\"Name\":\"user1\"},{\"User_id\":3,\"Name\":\"user3\"}]}]}",
+		"?group_by_geohash=13": "Invalid group_by_geohash 13, expected a precision between 1 and 12",
+	} {
+		req := httptest.NewRequest("POST", "/v1/customer"+query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetCustomers)(writer, req)
+		if strings.TrimSpace(writer.Body.String()) != expected {
+			t.Errorf("Output %v for %v, expected %v", writer.Body.String(), query, expected)
+		}
+	}
+}
+
+func TestGetCustomersDirections(t *testing.T) {
+	SetOfficeLocation(-6.257664, 53.339428)
+	content := "{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}\n"
+	req := httptest.NewRequest("POST", "/v1/customer?directions=true&unit=mi", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer := httptest.NewRecorder()
+	util.ErrorHandler(GetCustomers)(writer, req)
+	var result struct {
+		Customers  []map[string]interface{}
+		Directions []Direction
+	}
+	if err := json.Unmarshal(writer.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Customers) != 1 || len(result.Directions) != 1 {
+		t.Fatalf("Output %v, expected the direction of customer 12", writer.Body.String())
+	}
+	d := result.Directions[0]
+	if d.User_id != 12 || d.Unit != "mi" || math.Abs(d.Distance-25.953) > 1e-3 || math.Abs(d.Bearing-159.946) > 1e-3 || d.Compass != "SSE" ||
+		math.Abs(d.Midpoint.Latitude-53.162949) > 1e-6 || math.Abs(d.Midpoint.Longitude-(-6.150242)) > 1e-6 {
+		t.Errorf("Output %v, expected the direction of customer 12 from the office", d)
+	}
+
+	req = httptest.NewRequest("POST", "/v1/customer?directions=maybe", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer = httptest.NewRecorder()
+	util.ErrorHandler(GetCustomers)(writer, req)
+	if writer.Code != http.StatusBadRequest {
+		t.Errorf("Output %v, expected %v", writer.Code, http.StatusBadRequest)
+	}
+}
+
+type clustersTest struct {
+	query    string
+	status   int
+	clusters [][]int
+	noise    []int
+}
+
+var clustersTests []clustersTest = []clustersTest{
+	clustersTest{"?k=2", http.StatusOK, [][]int{{1, 2}, {3, 4, 5}}, []int{}},
+	clustersTest{"?method=dbscan&eps=3", http.StatusOK, [][]int{{1, 2}, {3, 4}}, []int{5}},
+	clustersTest{"?method=dbscan&eps=3&min_points=3", http.StatusOK, [][]int{}, []int{1, 2, 3, 4, 5}},
+	clustersTest{"?method=dbscan&eps=2&unit=mi", http.StatusOK, [][]int{{1, 2}, {3, 4}}, []int{5}},
+	clustersTest{"", http.StatusBadRequest, nil, nil},
+	clustersTest{"?k=0", http.StatusBadRequest, nil, nil},
+	clustersTest{"?method=dbscan", http.StatusBadRequest, nil, nil},
+	clustersTest{"?method=dbscan&eps=3&min_points=none", http.StatusBadRequest, nil, nil},
+	clustersTest{"?method=optics", http.StatusBadRequest, nil, nil},
+}
+
+func TestGetClusters(t *testing.T) {
+	SetOfficeLocation(-6.257664, 53.339428)
+	//two pairs of customers 2 km apart, north and south of the office, and one customer alone close to the south pair
+	content := "{\"latitude\": \"53.6\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"-6.25\"}\n" +
+		"{\"latitude\": \"53.618\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"-6.25\"}\n" +
+		"{\"latitude\": \"53.0\", \"user_id\": 3, \"name\": \"user3\", \"longitude\": \"-6.25\"}\n" +
+		"{\"latitude\": \"53.018\", \"user_id\": 4, \"name\": \"user4\", \"longitude\": \"-6.25\"}\n" +
+		"{\"latitude\": \"53.1\", \"user_id\": 5, \"name\": \"user5\", \"longitude\": \"-6.25\"}\n" +
+		"{\"latitude\": \"0\", \"user_id\": 6, \"name\": \"user6\", \"longitude\": \"0\"}\n"
+	for _, test := range clustersTests {
+		req := httptest.NewRequest("POST", "/v1/customer/clusters"+test.query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetClusters)(writer, req)
+		if writer.Code != test.status {
+			t.Errorf("Output %v for %v, expected %v", writer.Code, test.query, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var result struct {
+			Clusters []struct {
+				Radius  float64
+				Members []struct{ User_id int }
+			}
+			Noise []struct{ User_id int }
+		}
+		if err := json.Unmarshal(writer.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		clusters, noise := [][]int{}, []int{}
+		for _, c := range result.Clusters {
+			ids := []int{}
+			for _, m := range c.Members {
+				ids = append(ids, m.User_id)
+			}
+			clusters = append(clusters, ids)
+		}
+		for _, m := range result.Noise {
+			noise = append(noise, m.User_id)
+		}
+		if !reflect.DeepEqual(clusters, test.clusters) || !reflect.DeepEqual(noise, test.noise) {
+			t.Errorf("Output %v %v for %v, expected %v %v", clusters, noise, test.query, test.clusters, test.noise)
+		}
+	}
+}
+
+func TestGetCustomersTowns(t *testing.T) {
+	SetOfficeLocation(-6.257664, 53.339428)
+	content := "{\"latitude\": \"52.986375\", \"user_id\": 12, \"name\": \"Christina McArdle\", \"longitude\": \"-6.043701\"}\n" +
+		"{\"latitude\": \"53.2451022\", \"user_id\": 4, \"name\": \"Ian Kehoe\", \"longitude\": \"-6.238335\"}\n" +
+		"{\"latitude\": \"53.1302756\", \"user_id\": 5, \"name\": \"Nora Dempsey\", \"longitude\": \"-6.2397222\"}\n"
+	req := httptest.NewRequest("POST", "/v1/customer?towns=true&group_by_town=true", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer := httptest.NewRecorder()
+	util.ErrorHandler(GetCustomers)(writer, req)
+	var result struct {
+		Customers  []map[string]interface{}
+		Towns      []Town
+		TownGroups []struct {
+			Town      string
+			County    string
+			Customers []map[string]interface{}
+		} `json:"town_groups"`
+	}
+	if err := json.Unmarshal(writer.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Towns) != 3 {
+		t.Fatalf("Output %v, expected the towns of 3 customers", writer.Body.String())
+	}
+	towns := []string{}
+	for _, town := range result.Towns {
+		towns = append(towns, strconv.Itoa(town.User_id)+" "+town.Town+" "+town.County+" "+town.Country)
+	}
+	if expected := []string{"4 Bray Wicklow IE", "5 Greystones Wicklow IE", "12 Wicklow Wicklow IE"}; !reflect.DeepEqual(towns, expected) {
+		t.Errorf("Output %v, expected %v", towns, expected)
+	}
+	if d := result.Towns[2]; d.Unit != "km" || math.Abs(d.Distance-0.623) > 1e-3 {
+		t.Errorf("Output %v, expected 0.623 km", d)
+	}
+	groups := []string{}
+	for _, group := range result.TownGroups {
+		groups = append(groups, group.Town+" "+strconv.Itoa(len(group.Customers)))
+	}
+	if expected := []string{"Bray 1", "Greystones 1", "Wicklow 1"}; !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Output %v, expected %v", groups, expected)
+	}
+
+	req = httptest.NewRequest("POST", "/v1/customer?group_by_town=maybe", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer = httptest.NewRecorder()
+	util.ErrorHandler(GetCustomers)(writer, req)
+	if writer.Code != http.StatusBadRequest {
+		t.Errorf("Output %v, expected %v", writer.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetCustomersRoad(t *testing.T) {
+	SetOfficeLocation(-6.257664, 53.339428)
+	roads, err := road.LoadFile("../../Data/roads.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetRoads(roads)
+	defer SetRoads(nil)
+	//customers in Howth, Dun Laoghaire and Swords, all within 14 km of the office in a straight line but 15.9, 11.3 and
+	//15.9 km or 19.2, 14.2 and 13.2 minutes away by road
+	content := "{\"latitude\": \"53.3870\", \"user_id\": 1, \"name\": \"howth\", \"longitude\": \"-6.0650\"}\n" +
+		"{\"latitude\": \"53.2940\", \"user_id\": 2, \"name\": \"dun laoghaire\", \"longitude\": \"-6.1339\"}\n" +
+		"{\"latitude\": \"53.4597\", \"user_id\": 3, \"name\": \"swords\", \"longitude\": \"-6.2181\"}\n"
+	for query, expected := range map[string]string{
+		"max_distance=14":                  `[{"User_id":1,"Name":"howth"},{"User_id":2,"Name":"dun laoghaire"},{"User_id":3,"Name":"swords"}]`,
+		"max_distance=14&eligibility=road": `[{"User_id":2,"Name":"dun laoghaire"}]`,
+		"eligibility=time&max_time=15":     `[{"User_id":2,"Name":"dun laoghaire"},{"User_id":3,"Name":"swords"}]`,
+		"eligibility=time&max_time=0":      `null`,
+	} {
+		req := httptest.NewRequest("POST", "/v1/customer?"+query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetCustomers)(writer, req)
+		if writer.Body.String() != expected {
+			t.Errorf("Output %v for %v, expected %v", writer.Body.String(), query, expected)
+		}
+	}
+}
+
+func TestUnmarshalJSONLongitudePolicy(t *testing.T) {
+	input := "{\"latitude\": \"-16.5\", \"user_id\": 1, \"name\": \"Fiji\", \"longitude\": \"190\"}"
+	var c Customer
+	err := json.Unmarshal([]byte(input), &c)
+	if !errors.Is(err, greatCircle.ErrLongitude) {
+		t.Errorf("Output %v, expected an invalid longitude", err)
+	}
+	//the error of the line keeps the coordinate error
+	_, err = convertAndSelectCustomers(context.Background(), jsonl.Split([]byte(input)), DuplicateReject, &DefaultRange, nil)
+	var coordinateErr *greatCircle.CoordinateError
+	if !errors.As(err, &coordinateErr) || coordinateErr.Value != 190 {
+		t.Errorf("Output %v, expected the invalid longitude 190", err)
+	}
+
+	if err := SetLongitudePolicy("wrap"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetLongitudePolicy("reject")
+	if err := json.Unmarshal([]byte(input), &c); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(greatCircle.RadianToDegree(c.Location.Longitude)-(-170)) > 1e-9 || c.Longitude != "190" {
+		t.Errorf("Output %v, %v, expected the longitude 190 wrapped to -170", c.Longitude, c.Location)
+	}
+	if err := SetLongitudePolicy("clamp"); err == nil {
+		t.Errorf("Expected an error for clamp")
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	SetOfficeLocation(-6.257664, 53.339428)
+	//customers 11.1, 55.6 and 111.2 km north of the office, a duplicate of user 2 and an invalid record
+	content := "{\"latitude\": \"53.439428\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"latitude\": \"53.839428\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"latitude\": \"-91\", \"user_id\": 4, \"name\": \"user4\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"latitude\": \"54.339428\", \"user_id\": 3, \"name\": \"user3\", \"longitude\": \"-6.257664\"}\n" +
+		"{\"latitude\": \"53.9\", \"user_id\": 2, \"name\": \"user2\", \"longitude\": \"-6.257664\"}\n"
+	req := httptest.NewRequest("POST", "/v1/customer/stats?buckets=100,20&radii=50,120", strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer := httptest.NewRecorder()
+	util.ErrorHandler(GetStats)(writer, req)
+	if writer.Code != http.StatusOK {
+		t.Fatalf("Output %v %v, expected %v", writer.Code, writer.Body.String(), http.StatusOK)
+	}
+	var stats Stats
+	if err := json.Unmarshal(writer.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 5 || stats.Invalid != 1 || len(stats.Errors) != 1 || !strings.Contains(stats.Errors[0], "Invalid latitude -91") ||
+		stats.Customers != 3 || stats.Duplicates != 1 || stats.Invited != 2 || stats.Unit != "km" {
+		t.Errorf("Output %v, expected 5 records, 1 invalid, 3 customers, 1 duplicate and 2 invited", writer.Body.String())
+	}
+	if stats.UserIDs == nil || *stats.UserIDs != (IDRange{1, 3}) {
+		t.Errorf("Output %v, expected user ids 1 to 3", stats.UserIDs)
+	}
+	if box := stats.BoundingBox; box == nil || math.Abs(box.South-53.439428) > 1e-9 || math.Abs(box.West-(-6.257664)) > 1e-9 ||
+		math.Abs(box.North-54.339428) > 1e-9 || math.Abs(box.East-(-6.257664)) > 1e-9 {
+		t.Errorf("Output %v, expected the box of users 1 and 3", stats.BoundingBox)
+	}
+	histogram := []string{}
+	for _, b := range stats.Histogram {
+		to := "inf"
+		if b.To != nil {
+			to = strconv.FormatFloat(*b.To, 'f', -1, 64)
+		}
+		histogram = append(histogram, strconv.FormatFloat(b.From, 'f', -1, 64)+"-"+to+":"+strconv.Itoa(b.Count))
+	}
+	if expected := []string{"0-20:1", "20-100:1", "100-inf:1"}; !reflect.DeepEqual(histogram, expected) {
+		t.Errorf("Output %v, expected %v", histogram, expected)
+	}
+	if expected := []RadiusCount{{50, 1}, {120, 3}}; !reflect.DeepEqual(stats.Within, expected) {
+		t.Errorf("Output %v, expected %v", stats.Within, expected)
+	}
+
+	//a customer at a bucket upper bound, which is also a radius, is counted in that bucket and within that radius
+	bound := strconv.FormatFloat(DefaultRange.Distance(OfficeLocation, greatCircle.MakePoint(greatCircle.DegreeToRadian(-6.257664), greatCircle.DegreeToRadian(53.439428))), 'f', -1, 64)
+	req = httptest.NewRequest("POST", "/v1/customer/stats?buckets="+bound+"&radii="+bound, strings.NewReader(content))
+	req.Header.Add("Content-Type", "application/x-ndjson")
+	writer = httptest.NewRecorder()
+	util.ErrorHandler(GetStats)(writer, req)
+	if err := json.Unmarshal(writer.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Histogram[0].Count != 1 || stats.Within[0].Count != 1 {
+		t.Errorf("Output %v %v, expected user 1 in the first bucket and within the radius", stats.Histogram, stats.Within)
+	}
+
+	for query, status := range map[string]int{
+		"?duplicates=reject": http.StatusBadRequest,
+		"?buckets=10,far":    http.StatusBadRequest,
+		"?radii=0":           http.StatusBadRequest,
+		"?unit=parsec":       http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", "/v1/customer/stats"+query, strings.NewReader(content))
+		req.Header.Add("Content-Type", "application/x-ndjson")
+		writer := httptest.NewRecorder()
+		util.ErrorHandler(GetStats)(writer, req)
+		if writer.Code != status {
+			t.Errorf("Output %v for %v, expected %v", writer.Code, query, status)
+		}
+	}
+	writer = httptest.NewRecorder()
+	util.ErrorHandler(GetStats)(writer, httptest.NewRequest("GET", "/v1/customer/stats", nil))
+	if writer.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Output %v, expected %v", writer.Code, http.StatusMethodNotAllowed)
+	}
+}
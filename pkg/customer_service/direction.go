@@ -0,0 +1,64 @@
+package customer_service
+
+import (
+	"errors"
+	"strconv"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// A location in degrees
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Where an invited customer is from the office
+type Direction struct {
+	User_id int `json:"User_id"`
+	// Great circle distance, in Unit
+	Distance float64 `json:"distance"`
+	Unit     string  `json:"unit"`
+	// Initial bearing from the office in degrees, clockwise from north
+	Bearing float64 `json:"bearing"`
+	// 16-wind compass direction of the bearing, e.g. "SSE"
+	Compass string `json:"compass"`
+	// Point halfway between the office and the customer
+	Midpoint Coordinates `json:"midpoint"`
+}
+
+// Convert the "directions" query parameter into a boolean, "" gives false
+func ParseDirections(s string) (bool, error) {
+	return parseBoolParameter("directions", s)
+}
+
+// Convert the boolean query parameter name, "" gives false
+func parseBoolParameter(name string, s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.New("Invalid " + name + " " + s + ", expected true or false")
+	}
+	return b, nil
+}
+
+// Return the direction of every customer from the office, in the order of the customers, with the distances in the
+// unit of distances
+func customerDirections(customers []Customer, distances Range) []Direction {
+	directions := make([]Direction, 0, len(customers))
+	for _, customer := range customers {
+		bearing := greatCircle.InitialBearing(OfficeLocation, customer.Location)
+		midpoint := greatCircle.Midpoint(OfficeLocation, customer.Location)
+		directions = append(directions, Direction{
+			User_id:  customer.User_id,
+			Distance: distances.Distance(OfficeLocation, customer.Location),
+			Unit:     string(distances.Unit),
+			Bearing:  bearing,
+			Compass:  greatCircle.CompassDirection(bearing, 16),
+			Midpoint: Coordinates{greatCircle.RadianToDegree(midpoint.Latitude), greatCircle.RadianToDegree(midpoint.Longitude)},
+		})
+	}
+	return directions
+}
@@ -0,0 +1,184 @@
+package customer_service
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/road"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/schedule"
+)
+
+// How the customers are found within the range of the office
+type Eligibility string
+
+const (
+	// Great circle distance, within Range.Max
+	EligibilityStraight Eligibility = "straight"
+	// Shortest distance over the Roads, within Range.Max
+	EligibilityRoad Eligibility = "road"
+	// Shortest travel time over the Roads, within Range.MaxTime
+	EligibilityTime Eligibility = "time"
+)
+
+// Road graph of the road and time eligibilities, nil when none is loaded
+var Roads *road.Graph
+
+// Set the road graph of the road and time eligibilities
+func SetRoads(g *road.Graph) {
+	Roads = g
+}
+
+// Convert a string into an eligibility, "" gives straight
+func ParseEligibility(s string) (Eligibility, error) {
+	switch e := Eligibility(s); e {
+	case "":
+		return EligibilityStraight, nil
+	case EligibilityStraight, EligibilityRoad, EligibilityTime:
+		if e != EligibilityStraight && Roads == nil {
+			return "", errors.New("Eligibility " + s + " needs a road graph, set -roadGraph")
+		}
+		return e, nil
+	}
+	return "", errors.New("Invalid eligibility " + s + ", expected straight, road or time")
+}
+
+// The distance from the office within which the customers are invited
+type Range struct {
+	// Maximum distance, in Unit
+	Max  float64
+	Unit greatCircle.Unit
+	// Radius of the earth in km the distances are computed with
+	EarthRadius float64
+	// Straight, the default, or over the Roads
+	Eligibility Eligibility
+	// Maximum travel time of the time eligibility
+	MaxTime time.Duration
+	// Shortest paths from the office over the Roads, see withRoutes
+	routes *road.Tree
+}
+
+// Maximum distance in km of a range built without one
+const DefaultMaxDistance = 100.0
+
+// The range used when a request does not select one
+var DefaultRange = Range{Max: DefaultMaxDistance, Unit: greatCircle.Kilometre, EarthRadius: greatCircle.Radius, Eligibility: EligibilityStraight}
+
+// Build a range from a maximum distance, a unit name, an earth radius name (see greatCircle.ParseRadius), an
+// eligibility name (see ParseEligibility) and a maximum travel time. A nil max is DefaultMaxDistance converted into
+// the unit, as ParseRange converts the default maximum distance
+func NewRange(max *float64, unit string, earthRadius string, eligibility string, maxTime time.Duration) (Range, error) {
+	u, err := greatCircle.ParseUnit(unit)
+	if err != nil {
+		return Range{}, err
+	}
+	distance := greatCircle.Convert(DefaultMaxDistance, greatCircle.Kilometre, u)
+	if max != nil {
+		distance = *max
+	}
+	if distance < 0 {
+		return Range{}, errors.New("Maximum distance must be >= 0")
+	}
+	radius, err := greatCircle.ParseRadius(earthRadius)
+	if err != nil {
+		return Range{}, err
+	}
+	e, err := ParseEligibility(eligibility)
+	if err != nil {
+		return Range{}, err
+	}
+	if maxTime < 0 {
+		return Range{}, errors.New("Maximum travel time must be >= 0")
+	}
+	return Range{Max: distance, Unit: u, EarthRadius: radius, Eligibility: e, MaxTime: maxTime}, nil
+}
+
+// Set the range used when a request does not select one
+func SetDefaultRange(r Range) {
+	DefaultRange = r
+}
+
+// Read the range of a request from the "max_distance" (in "unit"), "unit" (km, mi, nmi or m), "earth_radius"
+// (mean, equatorial or authalic), "eligibility" (straight, road or time) and "max_time" (in minutes) query
+// parameters. The missing ones come from DefaultRange, a unit without a maximum distance keeps the default maximum
+// distance converted into the unit
+func ParseRange(query url.Values) (Range, error) {
+	r := DefaultRange
+	var err error
+	if unit := query.Get("unit"); unit != "" {
+		if r.Unit, err = greatCircle.ParseUnit(unit); err != nil {
+			return Range{}, err
+		}
+		r.Max = greatCircle.Convert(DefaultRange.Max, DefaultRange.Unit, r.Unit)
+	}
+	if max := query.Get("max_distance"); max != "" {
+		if r.Max, err = strconv.ParseFloat(max, 64); err != nil || r.Max < 0 {
+			return Range{}, errors.New("Invalid max_distance " + max + ", expected a number >= 0")
+		}
+	}
+	if radius := query.Get("earth_radius"); radius != "" {
+		if r.EarthRadius, err = greatCircle.ParseRadius(radius); err != nil {
+			return Range{}, err
+		}
+	}
+	if eligibility := query.Get("eligibility"); eligibility != "" {
+		if r.Eligibility, err = ParseEligibility(eligibility); err != nil {
+			return Range{}, err
+		}
+	}
+	if maxTime := query.Get("max_time"); maxTime != "" {
+		minutes, err := strconv.ParseFloat(maxTime, 64)
+		if err != nil || minutes < 0 {
+			return Range{}, errors.New("Invalid max_time " + maxTime + ", expected minutes >= 0")
+		}
+		r.MaxTime = time.Duration(minutes * float64(time.Minute))
+	}
+	return r, nil
+}
+
+// Return the range of a schedule, the settings it does not give come from DefaultRange as for ParseRange
+func ScheduleRange(config schedule.Config) (Range, error) {
+	query := url.Values{}
+	if config.MaxDistance != nil {
+		query.Set("max_distance", strconv.FormatFloat(*config.MaxDistance, 'f', -1, 64))
+	}
+	query.Set("unit", config.Unit)
+	query.Set("earth_radius", config.EarthRadius)
+	return ParseRange(query)
+}
+
+// Return the distance between two points in the unit of the range
+func (r Range) Distance(p1 greatCircle.Point, p2 greatCircle.Point) float64 {
+	return r.Unit.FromKm(greatCircle.Distance(p1, p2, r.EarthRadius))
+}
+
+// Return the range with the shortest paths from the office over the Roads computed, when its eligibility needs them
+func (r Range) withRoutes() Range {
+	switch r.Eligibility {
+	case EligibilityRoad:
+		r.routes = Roads.ShortestPaths(OfficeLocation, road.MetricDistance)
+	case EligibilityTime:
+		r.routes = Roads.ShortestPaths(OfficeLocation, road.MetricTime)
+	}
+	return r
+}
+
+// Return how far a location is from the office and how far it may be to be invited: the distance in Unit for the
+// straight and road eligibilities, the travel time in minutes for the time one. A location the roads do not reach is
+// infinitely far
+func (r Range) measure(p greatCircle.Point) (float64, float64) {
+	if r.routes == nil {
+		return r.Distance(OfficeLocation, p), r.Max
+	}
+	route, found := r.routes.Route(p)
+	if !found {
+		return math.Inf(1), r.Max
+	}
+	if r.Eligibility == EligibilityTime {
+		return route.Time.Minutes(), r.MaxTime.Minutes()
+	}
+	return r.Unit.FromKm(route.Distance), r.Max
+}
@@ -0,0 +1,139 @@
+package customer_service
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// How records sharing a user_id are resolved
+type DuplicatePolicy string
+
+const (
+	// Fail the whole upload (the historical behaviour)
+	DuplicateReject DuplicatePolicy = "reject"
+	// Keep the first record of the user_id
+	DuplicateKeepFirst DuplicatePolicy = "keep-first"
+	// Keep the last record of the user_id
+	DuplicateKeepLast DuplicatePolicy = "keep-last"
+	// Keep one record if all the records of the user_id are identical, fail the upload otherwise
+	DuplicateMergeIfIdentical DuplicatePolicy = "merge-if-identical"
+	// Drop every record of the user_id
+	DuplicateReportAndSkip DuplicatePolicy = "report-and-skip"
+)
+
+var duplicatePolicies = []DuplicatePolicy{DuplicateReject, DuplicateKeepFirst, DuplicateKeepLast, DuplicateMergeIfIdentical, DuplicateReportAndSkip}
+
+// The policy used when a request does not select one
+var DefaultDuplicatePolicy = DuplicateReject
+
+// Convert a policy name into a DuplicatePolicy, "" gives the default policy
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	if s == "" {
+		return DefaultDuplicatePolicy, nil
+	}
+	var names []string
+	for _, policy := range duplicatePolicies {
+		if string(policy) == s {
+			return policy, nil
+		}
+		names = append(names, string(policy))
+	}
+	return DuplicateReject, errors.New("Invalid duplicate policy " + s + ", expected one of " + strings.Join(names, ", "))
+}
+
+// Set the policy used when a request does not select one
+func SetDuplicatePolicy(s string) error {
+	if s == "" {
+		return errors.New("Duplicate policy must not be empty")
+	}
+	policy, err := ParseDuplicatePolicy(s)
+	if err != nil {
+		return err
+	}
+	DefaultDuplicatePolicy = policy
+	return nil
+}
+
+// One of the records involved in a duplicate, as found in the file
+type DuplicateRecord struct {
+	Source string          `json:"source,omitempty"`
+	Line   int             `json:"line"`
+	Record json.RawMessage `json:"record"`
+}
+
+// Two records sharing a user_id and how they were resolved (kept-first, kept-last, merged or skipped)
+type Duplicate struct {
+	User_id    int             `json:"user_id"`
+	First      DuplicateRecord `json:"first"`
+	Second     DuplicateRecord `json:"second"`
+	Resolution string          `json:"resolution"`
+}
+
+// Return the record of a parsed line for reporting
+func duplicateRecord(p parsedLine) DuplicateRecord {
+	return DuplicateRecord{p.source, p.line, json.RawMessage(p.data)}
+}
+
+// Resolve the user_id conflicts of the parsed lines according to policy. Returns the indexes of the kept lines
+// in line order and the duplicates found
+func resolveDuplicates(results []parsedLine, policy DuplicatePolicy) ([]int, []Duplicate, error) {
+	kept := make(map[int]int, len(results))
+	skipped := make(map[int]int)
+	var duplicates []Duplicate
+	for i, result := range results {
+		id := result.customer.User_id
+		if first, found := skipped[id]; found {
+			duplicates = append(duplicates, Duplicate{id, duplicateRecord(results[first]), duplicateRecord(result), "skipped"})
+			continue
+		}
+		j, found := kept[id]
+		if !found {
+			kept[id] = i
+			continue
+		}
+
+		dup := Duplicate{id, duplicateRecord(results[j]), duplicateRecord(result), ""}
+		switch policy {
+		case DuplicateKeepFirst:
+			dup.Resolution = "kept-first"
+		case DuplicateKeepLast:
+			dup.Resolution = "kept-last"
+			kept[id] = i
+		case DuplicateMergeIfIdentical:
+			if !sameCustomer(results[j].customer, result.customer) {
+				return nil, nil, overlapError(results[j], result, "Customer id overlap with different records: ")
+			}
+			dup.Resolution = "merged"
+		case DuplicateReportAndSkip:
+			dup.Resolution = "skipped"
+			delete(kept, id)
+			skipped[id] = j
+		default:
+			return nil, nil, overlapError(results[j], result, "Customer id overlap: ")
+		}
+		duplicates = append(duplicates, dup)
+	}
+
+	var indexes []int
+	for i, result := range results {
+		if j, found := kept[result.customer.User_id]; found && j == i {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, duplicates, nil
+}
+
+// Check if two records describe the same customer. The parsed location is compared rather than the coordinate strings,
+// so "53.5" and "53°30'N" or a geohash and the coordinates of its center are the same
+func sameCustomer(a Customer, b Customer) bool {
+	return a.User_id == b.User_id && a.Name == b.Name && a.Location == b.Location && a.Priority == b.Priority
+}
+
+// Build the error reporting two conflicting records
+func overlapError(first parsedLine, second parsedLine, msg string) error {
+	err := second.prefix() + msg + strconv.Itoa(second.customer.User_id) +
+		" (" + first.prefix() + string(first.data) + ", " + second.prefix() + string(second.data) + ")"
+	return errors.New(err)
+}
@@ -0,0 +1,46 @@
+package customer_service
+
+import (
+	"net/http"
+	"sort"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/event"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Return the customers within the range of the office of e, in user id order, with their distance in the unit of e.
+// The customer files are read as for GetCustomers and the duplicates resolved with the policy selected by the
+// "duplicates" query parameter
+func EventCandidates(r *http.Request, e event.Event) ([]event.Candidate, error) {
+	if http.MethodPut != r.Method && http.MethodPost != r.Method {
+		return nil, util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a PUT or POST request")
+	}
+	policy, err := ParseDuplicatePolicy(r.URL.Query().Get("duplicates"))
+	if nil != err {
+		return nil, err
+	}
+	files, err := getCustomerFiles(r)
+	if nil != err {
+		return nil, err
+	}
+	//the customers are measured from the office of the event below, so the pipeline only parses them
+	conv, err := convertAndSelectCustomers(r.Context(), splitFiles(files), policy, nil, nil)
+	if nil != err {
+		return nil, err
+	}
+
+	office := greatCircle.MakePoint(greatCircle.DegreeToRadian(e.Office.Longitude), greatCircle.DegreeToRadian(e.Office.Latitude))
+	distances := Range{Max: *e.Radius, Unit: e.Unit, EarthRadius: DefaultRange.EarthRadius}
+	candidates := []event.Candidate{}
+	for _, customer := range conv.customers {
+		distance := distances.Distance(office, customer.Location)
+		if util.SmallerOrEqual(distance, *e.Radius) {
+			candidates = append(candidates, event.Candidate{User_id: customer.User_id, Name: customer.Name, Distance: distance, Priority: customer.Priority})
+		}
+	}
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].User_id < candidates[b].User_id })
+	logger.Info(r.Context(), "Eligible customers", "event", e.Name, "parsed", len(conv.customers), "eligible", len(candidates), "capacity", e.Capacity)
+	return candidates, nil
+}
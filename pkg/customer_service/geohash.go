@@ -0,0 +1,46 @@
+package customer_service
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Invited customers sharing a geohash prefix
+type GeohashGroup struct {
+	Geohash   string     `json:"geohash"`
+	Customers []Customer `json:"customers"`
+}
+
+// Convert the "group_by_geohash" query parameter into a geohash precision, "" gives 0 (no grouping)
+func ParseGeohashPrecision(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	precision, err := strconv.Atoi(s)
+	if err != nil || precision < 1 || precision > greatCircle.MaxGeohashPrecision {
+		return 0, errors.New("Invalid group_by_geohash " + s + ", expected a precision between 1 and " + strconv.Itoa(greatCircle.MaxGeohashPrecision))
+	}
+	return precision, nil
+}
+
+// Group the customers by the geohash of their location with precision characters. The groups are sorted by geohash
+// and keep the order of the customers
+func groupByGeohash(customers []Customer, precision int) []GeohashGroup {
+	groups := []GeohashGroup{}
+	index := make(map[string]int)
+	for _, customer := range customers {
+		geohash := customer.Location.Geohash(precision)
+		i, found := index[geohash]
+		if !found {
+			i = len(groups)
+			index[geohash] = i
+			groups = append(groups, GeohashGroup{Geohash: geohash})
+		}
+		groups[i].Customers = append(groups[i].Customers, customer)
+	}
+	sort.Slice(groups, func(a, b int) bool { return groups[a].Geohash < groups[b].Geohash })
+	return groups
+}
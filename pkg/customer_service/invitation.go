@@ -0,0 +1,150 @@
+package customer_service
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/invitation"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/rsvp"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Renderer of the invitations, with the default templates until SetInvitations is called
+var Invitations, _ = invitation.NewRenderer(invitation.DefaultConfig)
+
+// Address of the SMTP server the invitations are sent to with format=smtp, empty to disable sending
+var SMTPAddr string
+
+// Render the invitations with r and send them to the SMTP server at smtpAddr
+func SetInvitations(r *invitation.Renderer, smtpAddr string) {
+	Invitations = r
+	SMTPAddr = smtpAddr
+}
+
+// Store of the RSVP tokens, nil until SetRSVP is called
+var RSVPs *rsvp.Store
+
+// Base URL of the RSVP links put in the invitations, e.g. http://localhost:8081/rsvp/
+var RSVPBaseURL string
+
+// Track the answers to the invitations in store, the customers answering through the links under baseURL
+func SetRSVP(store *rsvp.Store, baseURL string) {
+	RSVPs = store
+	RSVPBaseURL = strings.TrimSuffix(baseURL, "/") + "/"
+}
+
+// Issue the RSVP tokens of the customers invited to event, saving the store once, and return the links to answer with
+// them by user id
+func issueRSVPs(event string, customers []Customer) (map[int]invitation.RSVP, error) {
+	invitees := make([]rsvp.Invitee, 0, len(customers))
+	for _, customer := range customers {
+		invitees = append(invitees, rsvp.Invitee{User_id: customer.User_id, Name: customer.Name})
+	}
+	invites, err := RSVPs.IssueAll(event, invitees)
+	if nil != err {
+		return nil, err
+	}
+	links := make(map[int]invitation.RSVP, len(invites))
+	for _, invite := range invites {
+		link := RSVPBaseURL + url.PathEscape(invite.Token) + "?response="
+		links[invite.User_id] = invitation.RSVP{Token: invite.Token, Accept: link + "accept", Decline: link + "decline"}
+	}
+	return links, nil
+}
+
+// Render the invitation of every invited customer, in user id order, with their distance in the unit of distances.
+// When event is not empty, every customer gets an RSVP token for it, the tokens being issued together
+func renderInvitations(customers []Customer, distances Range, event string) ([]invitation.Message, error) {
+	office := invitation.Office{
+		Latitude:  greatCircle.RadianToDegree(OfficeLocation.Latitude),
+		Longitude: greatCircle.RadianToDegree(OfficeLocation.Longitude),
+	}
+	var links map[int]invitation.RSVP
+	if event != "" {
+		var err error
+		if links, err = issueRSVPs(event, customers); nil != err {
+			return nil, errors.New("Cannot issue the RSVP tokens : " + err.Error())
+		}
+	}
+	messages := make([]invitation.Message, 0, len(customers))
+	for _, customer := range customers {
+		d := invitation.Data{
+			User_id:  customer.User_id,
+			Name:     customer.Name,
+			Distance: distances.Distance(OfficeLocation, customer.Location),
+			Unit:     string(distances.Unit),
+			Office:   office,
+			RSVP:     links[customer.User_id],
+		}
+		m, err := Invitations.Render(d)
+		if nil != err {
+			return nil, errors.New("Cannot render the invitation of customer " + strconv.Itoa(customer.User_id) + " : " + err.Error())
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// Entry point of the invitations. The customers are read as for GetCustomers and the invitations of the invited ones
+// are returned as a zip of .eml files (format=eml, the default), a zip of .html files (format=html), an mbox
+// (format=mbox), or sent to SMTPAddr (format=smtp). With the "event" query parameter, every invitation holds the
+// links to answer it
+func GetInvitations(w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = invitation.FormatEML
+	}
+	if format != invitation.FormatEML && format != invitation.FormatHTML && format != invitation.FormatMbox && format != "smtp" {
+		return errors.New("Invalid invitation format " + format + ", expected eml, html, mbox or smtp")
+	}
+	if format == "smtp" && SMTPAddr == "" {
+		return util.NewHTTPError(http.StatusNotImplemented, "Sending the invitations is disabled, no SMTP server is configured")
+	}
+	event := r.URL.Query().Get("event")
+	if event != "" && RSVPs == nil {
+		return util.NewHTTPError(http.StatusNotImplemented, "RSVP tracking is disabled, no RSVP store is configured")
+	}
+	if event != "" && !rsvp.ValidEvent(event) {
+		return rsvp.ErrInvalidEvent
+	}
+
+	result, err := processInviteRequest(r)
+	if nil != err {
+		return err
+	}
+	messages, err := renderInvitations(result.Customers, result.distances, event)
+	if nil != err {
+		return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+	}
+	now := time.Now()
+
+	if format == "smtp" {
+		sent, err := invitation.Send(SMTPAddr, messages, now)
+		logger.Info(r.Context(), "Sent invitations", "sent", sent, "total", len(messages), "error", err)
+		if nil != err {
+			return &util.HTTPError{Status: http.StatusBadGateway, Err: err}
+		}
+		return util.WriteJSON(w, http.StatusOK, map[string]int{"sent": sent})
+	}
+
+	var b bytes.Buffer
+	if err := invitation.WriteArchive(&b, format, messages, now); nil != err {
+		return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+	}
+	contentType, name := "application/zip", "invitations-"+format+".zip"
+	if format == invitation.FormatMbox {
+		contentType, name = "application/mbox", "invitations.mbox"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+	return nil
+}
@@ -0,0 +1,20 @@
+package customer_service
+
+import (
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/metrics"
+)
+
+var (
+	uploadBytes = metrics.Default.NewHistogramVec("customer_upload_bytes",
+		"Size of the uploaded customer files in bytes.", metrics.DefaultSizeBuckets)
+	customersParsed = metrics.Default.NewHistogramVec("customer_parsed_per_request",
+		"Number of customers successfully parsed per request.", metrics.DefaultCountBuckets)
+	customersRejected = metrics.Default.NewHistogramVec("customer_rejected_per_request",
+		"Number of customer records of a successful request which are not kept, as duplicates.", metrics.DefaultCountBuckets)
+	requestsFailed = metrics.Default.NewCounterVec("customer_failed_requests_total",
+		"Number of requests whose customer files could not be processed.")
+	customersInvited = metrics.Default.NewHistogramVec("customer_invited_per_request",
+		"Number of customers invited per request.", metrics.DefaultCountBuckets)
+	distanceDuration = metrics.Default.NewHistogramVec("customer_distance_duration_seconds",
+		"Time taken to compute the distances and select the invited customers of a request.", metrics.DefaultLatencyBuckets)
+)
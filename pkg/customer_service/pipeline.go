@@ -0,0 +1,189 @@
+package customer_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/jsonl"
+)
+
+// Number of lines handed to a worker at once
+const chunkSize = 1024
+
+// Number of goroutines in each stage of the pipeline
+var Workers = runtime.NumCPU()
+
+// Set the number of goroutines in each stage of the pipeline
+func SetWorkers(workers int) error {
+	if workers < 1 {
+		return errors.New("Number of workers must be > 0")
+	}
+	Workers = workers
+	return nil
+}
+
+// One line of the customer file after going through the pipeline
+type parsedLine struct {
+	source   string
+	line     int
+	data     []byte
+	customer Customer
+	invite   bool
+	err      error
+}
+
+// A run of consecutive lines travelling through the pipeline. parsed is the part of the result slice
+// owned by the chunk, so workers never write to the same element
+type chunk struct {
+	lines  []jsonl.Line
+	parsed []parsedLine
+}
+
+// Parse every line of the chunk into a customer
+func parseChunk(c chunk) {
+	for i, line := range c.lines {
+		c.parsed[i].source = line.Source
+		c.parsed[i].line = line.Number
+		c.parsed[i].data = line.Data
+		if !json.Valid(line.Data) {
+			c.parsed[i].err = errors.New(line.Prefix() + "Invalid JSON: " + string(line.Data))
+			continue
+		}
+		if err := json.Unmarshal(line.Data, &c.parsed[i].customer); nil != err {
+			//wrapped so that errors.Is and errors.As still find the *greatCircle.CoordinateError
+			c.parsed[i].err = fmt.Errorf("%sCannot unmarshal customer %s : %w", line.Prefix(), line.Data, err)
+		}
+	}
+}
+
+// Prefix for error messages about this line
+func (p parsedLine) prefix() string {
+	return jsonl.Line{Source: p.source, Number: p.line}.Prefix()
+}
+
+// Decide whether each successfully parsed customer of the chunk is within r and should be invited
+func distanceChunk(c chunk, r Range) {
+	for i := range c.parsed {
+		p := &c.parsed[i]
+		if p.err != nil {
+			continue
+		}
+		distance, max := r.measure(p.customer.Location)
+		p.invite, p.err = p.customer.shouldInviteCustomer(distance, max)
+	}
+}
+
+// Run the lines through the parse stage then the distance stage, each on its own pool of Workers goroutines. A nil r
+// skips the distance stage, no customer is then invited. The results are in line order whatever the scheduling.
+// Returns ctx.Err() if ctx is cancelled before the end
+func runPipeline(ctx context.Context, lines []jsonl.Line, r *Range, progress func(processed int, total int)) ([]parsedLine, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	//the shortest paths over the roads are computed once, then shared by the distance stage
+	if r != nil {
+		routes := r.withRoutes()
+		r = &routes
+	}
+
+	workers := Workers
+	results := make([]parsedLine, len(lines))
+	parseQueue := make(chan chunk, workers)
+	distanceQueue := make(chan chunk, workers)
+	done := make(chan chunk, workers)
+	//without a range the parsed chunks are done
+	parsed := distanceQueue
+	if r == nil {
+		parsed = done
+	}
+
+	//feed the chunks to the parse stage
+	go func() {
+		defer close(parseQueue)
+		for start := 0; start < len(lines); start += chunkSize {
+			end := start + chunkSize
+			if end > len(lines) {
+				end = len(lines)
+			}
+			select {
+			case parseQueue <- chunk{lines[start:end], results[start:end]}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	//parse stage
+	var parseGroup sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		parseGroup.Add(1)
+		go func() {
+			defer parseGroup.Done()
+			for c := range parseQueue {
+				if ctx.Err() != nil {
+					return
+				}
+				parseChunk(c)
+				select {
+				case parsed <- c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		parseGroup.Wait()
+		close(parsed)
+	}()
+
+	//distance stage
+	var distanceNanos int64
+	if r != nil {
+		var distanceGroup sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			distanceGroup.Add(1)
+			go func() {
+				defer distanceGroup.Done()
+				for c := range distanceQueue {
+					if ctx.Err() != nil {
+						return
+					}
+					start := time.Now()
+					distanceChunk(c, *r)
+					atomic.AddInt64(&distanceNanos, int64(time.Since(start)))
+					select {
+					case done <- c:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			distanceGroup.Wait()
+			close(done)
+		}()
+	}
+
+	processed := 0
+	for c := range done {
+		processed += len(c.lines)
+		if progress != nil {
+			progress(processed, len(lines))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if r != nil {
+		distanceDuration.Observe(time.Duration(atomic.LoadInt64(&distanceNanos)).Seconds())
+	}
+	return results, nil
+}
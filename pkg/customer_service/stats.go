@@ -0,0 +1,215 @@
+package customer_service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/jsonl"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Upper bounds of the distance histogram buckets and radii of the counts, in the unit of the request, when not given
+var (
+	DefaultStatsBuckets = []float64{10, 25, 50, 100, 250, 500}
+	DefaultStatsRadii   = []float64{25, 50, 100}
+)
+
+// Number of invalid records whose error is reported
+const maxStatsErrors = 10
+
+// Lowest and highest user ids
+type IDRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Smallest box holding every customer, in degree. It does not cross the antimeridian
+type BoundingBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// Customers whose distance to the office is in From..To, From excluded (but for the first bucket, from 0) and To
+// included, as the radii of RadiusCount are. Both bounds are compared with util.SmallerOrEqual. The last bucket has no To
+type Bucket struct {
+	From  float64  `json:"from"`
+	To    *float64 `json:"to"`
+	Count int      `json:"count"`
+}
+
+// Customers within Radius of the office
+type RadiusCount struct {
+	Radius float64 `json:"radius"`
+	Count  int     `json:"count"`
+}
+
+// Statistics of customer files
+type Stats struct {
+	// Records read, valid or not
+	Records int `json:"records"`
+	// Records which could not be parsed, and the errors of the first ones
+	Invalid int      `json:"invalid"`
+	Errors  []string `json:"errors"`
+	// Customers kept once the duplicates are resolved
+	Customers  int `json:"customers"`
+	Duplicates int `json:"duplicates"`
+	// Customers within the range of the request
+	Invited     int          `json:"invited"`
+	UserIDs     *IDRange     `json:"user_ids"`
+	BoundingBox *BoundingBox `json:"bounding_box"`
+	// Unit of the distances of Histogram and Within
+	Unit      string        `json:"unit"`
+	Histogram []Bucket      `json:"histogram"`
+	Within    []RadiusCount `json:"within"`
+}
+
+// Convert a comma separated list of distances > 0, "" gives defaults. The distances are sorted and deduplicated
+func parseDistances(name string, s string, defaults []float64) ([]float64, error) {
+	if s == "" {
+		return defaults, nil
+	}
+	var distances []float64
+	for _, item := range strings.Split(s, ",") {
+		d, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || d <= 0 || math.IsInf(d, 0) {
+			return nil, errors.New("Invalid " + name + " " + s + ", expected comma separated distances > 0")
+		}
+		distances = append(distances, d)
+	}
+	sort.Float64s(distances)
+	unique := distances[:1]
+	for _, d := range distances[1:] {
+		if d != unique[len(unique)-1] {
+			unique = append(unique, d)
+		}
+	}
+	return unique, nil
+}
+
+// Compute the statistics of the lines. They go through the pipeline like the invite requests, except that the invalid
+// records are counted instead of failing the request. The distances are great circle distances from the office in the
+// unit of r, buckets and radii are in that unit
+func customerStats(ctx context.Context, lines []jsonl.Line, policy DuplicatePolicy, r Range, buckets []float64, radii []float64) (*Stats, error) {
+	results, err := runPipeline(ctx, lines, &r, nil)
+	if nil != err {
+		return nil, err
+	}
+	stats := &Stats{Records: len(lines), Errors: []string{}, Unit: string(r.Unit), Histogram: make([]Bucket, len(buckets)+1), Within: make([]RadiusCount, len(radii))}
+	var valid []parsedLine
+	for _, result := range results {
+		if nil != result.err {
+			if stats.Invalid < maxStatsErrors {
+				stats.Errors = append(stats.Errors, result.err.Error())
+			}
+			stats.Invalid++
+			continue
+		}
+		valid = append(valid, result)
+	}
+	kept, duplicates, err := resolveDuplicates(valid, policy)
+	if nil != err {
+		return nil, err
+	}
+	stats.Customers, stats.Duplicates = len(kept), len(duplicates)
+
+	from := 0.0
+	for i, to := range buckets {
+		to := to
+		stats.Histogram[i] = Bucket{From: from, To: &to}
+		from = to
+	}
+	stats.Histogram[len(buckets)] = Bucket{From: from}
+	for i, radius := range radii {
+		stats.Within[i].Radius = radius
+	}
+
+	for _, i := range kept {
+		c := valid[i].customer
+		if valid[i].invite {
+			stats.Invited++
+		}
+		latitude, longitude := greatCircle.RadianToDegree(c.Location.Latitude), greatCircle.RadianToDegree(c.Location.Longitude)
+		if stats.UserIDs == nil {
+			stats.UserIDs = &IDRange{c.User_id, c.User_id}
+			stats.BoundingBox = &BoundingBox{latitude, longitude, latitude, longitude}
+		}
+		if c.User_id < stats.UserIDs.Min {
+			stats.UserIDs.Min = c.User_id
+		}
+		if c.User_id > stats.UserIDs.Max {
+			stats.UserIDs.Max = c.User_id
+		}
+		box := stats.BoundingBox
+		box.South, box.North = math.Min(box.South, latitude), math.Max(box.North, latitude)
+		box.West, box.East = math.Min(box.West, longitude), math.Max(box.East, longitude)
+
+		distance := r.Distance(OfficeLocation, c.Location)
+		stats.Histogram[sort.Search(len(buckets), func(b int) bool { return util.SmallerOrEqual(distance, buckets[b]) })].Count++
+		for j, radius := range radii {
+			if util.SmallerOrEqual(distance, radius) {
+				stats.Within[j].Count++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Read the buckets and radii of a stats request from the "buckets" and "radii" query parameters
+func parseStatsSettings(query url.Values) ([]float64, []float64, error) {
+	buckets, err := parseDistances("buckets", query.Get("buckets"), DefaultStatsBuckets)
+	if nil != err {
+		return nil, nil, err
+	}
+	radii, err := parseDistances("radii", query.Get("radii"), DefaultStatsRadii)
+	if nil != err {
+		return nil, nil, err
+	}
+	return buckets, radii, nil
+}
+
+// Entry point of the dataset statistics. The customer files are read as for GetCustomers and described: counts of
+// records, invalid records, customers, duplicates and invited customers, user id range, bounding box, histogram of
+// the distances to the office and counts within radii. The duplicates are kept-first unless the "duplicates" query
+// parameter selects another policy, the range is read by ParseRange, the histogram upper bounds by "buckets" and the
+// radii by "radii" (comma separated distances in the unit of the request)
+func GetStats(w http.ResponseWriter, r *http.Request) error {
+	if http.MethodPut != r.Method && http.MethodPost != r.Method {
+		return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a PUT or POST request")
+	}
+	policy := DuplicateKeepFirst
+	if duplicates := r.URL.Query().Get("duplicates"); duplicates != "" {
+		var err error
+		if policy, err = ParseDuplicatePolicy(duplicates); nil != err {
+			return err
+		}
+	}
+	distances, err := ParseRange(r.URL.Query())
+	if nil != err {
+		return err
+	}
+	buckets, radii, err := parseStatsSettings(r.URL.Query())
+	if nil != err {
+		return err
+	}
+	files, err := getCustomerFiles(r)
+	if nil != err {
+		return err
+	}
+	stats, err := customerStats(r.Context(), splitFiles(files), policy, distances, buckets, radii)
+	if nil != err {
+		return err
+	}
+	logger.Info(r.Context(), "Computed customer stats", "records", stats.Records, "invalid", stats.Invalid, "customers", stats.Customers, "duplicates", stats.Duplicates)
+
+	return util.WriteJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,89 @@
+package customer_service
+
+import (
+	"sort"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/geocode"
+)
+
+// Places the customers are reverse geocoded with
+var Places = geocode.Default
+
+// Set the places the customers are reverse geocoded with
+func SetPlaces(g *geocode.Gazetteer) {
+	Places = g
+}
+
+// The town nearest to an invited customer
+type Town struct {
+	User_id int    `json:"User_id"`
+	Town    string `json:"town"`
+	County  string `json:"county"`
+	Country string `json:"country"`
+	// Great circle distance between the customer and the town, in Unit
+	Distance float64 `json:"distance"`
+	Unit     string  `json:"unit"`
+}
+
+// Invited customers sharing the same nearest town
+type TownGroup struct {
+	Town      string     `json:"town"`
+	County    string     `json:"county"`
+	Country   string     `json:"country"`
+	Customers []Customer `json:"customers"`
+}
+
+// Convert the "towns" query parameter into a boolean, "" gives false
+func ParseTowns(s string) (bool, error) {
+	return parseBoolParameter("towns", s)
+}
+
+// Convert the "group_by_town" query parameter into a boolean, "" gives false
+func ParseGroupByTown(s string) (bool, error) {
+	return parseBoolParameter("group_by_town", s)
+}
+
+// Return the nearest town of every customer, in the order of the customers, with the distances in the unit of
+// distances
+func customerTowns(customers []Customer, distances Range) []Town {
+	towns := make([]Town, 0, len(customers))
+	for _, customer := range customers {
+		place, _ := Places.Nearest(customer.Location)
+		towns = append(towns, Town{
+			User_id:  customer.User_id,
+			Town:     place.Name,
+			County:   place.County,
+			Country:  place.Country,
+			Distance: distances.Distance(customer.Location, place.Location()),
+			Unit:     string(distances.Unit),
+		})
+	}
+	return towns
+}
+
+// Group the customers by their nearest town. The groups are sorted by country, county then town and keep the order
+// of the customers
+func groupByTown(customers []Customer) []TownGroup {
+	groups := []TownGroup{}
+	index := make(map[geocode.Place]int)
+	for _, customer := range customers {
+		place, _ := Places.Nearest(customer.Location)
+		i, found := index[place]
+		if !found {
+			i = len(groups)
+			index[place] = i
+			groups = append(groups, TownGroup{Town: place.Name, County: place.County, Country: place.Country})
+		}
+		groups[i].Customers = append(groups[i].Customers, customer)
+	}
+	sort.SliceStable(groups, func(a, b int) bool {
+		if groups[a].Country != groups[b].Country {
+			return groups[a].Country < groups[b].Country
+		}
+		if groups[a].County != groups[b].County {
+			return groups[a].County < groups[b].County
+		}
+		return groups[a].Town < groups[b].Town
+	})
+	return groups
+}
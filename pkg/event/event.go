@@ -0,0 +1,137 @@
+// Package event describes the events customers are invited to, with their venue, range and capacity, and fills them
+// from the eligible customers, the others going on an ordered waitlist
+package event
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// How the eligible customers are ranked for the places of an event
+type Ranking string
+
+const (
+	// Closest customers first
+	RankByDistance Ranking = "distance"
+	// Highest priority first, then closest
+	RankByPriority Ranking = "priority"
+)
+
+// Range of an event created without one, in its unit
+const DefaultRadius = 100.0
+
+// Layout of the date of an event
+const DateLayout = "2006-01-02"
+
+var (
+	ErrNotFound    = errors.New("Event not found")
+	ErrExists      = errors.New("Event already exists")
+	ErrInvalidName = errors.New("Invalid event name, expected letters, digits, '_', '.' or '-'")
+)
+
+// Event names are used in URLs and as the event of the RSVPs
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Venue of an event, in degrees
+type Office struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// An event customers are invited to
+type Event struct {
+	Name   string `json:"name"`
+	Date   string `json:"date"`
+	Office Office `json:"office"`
+	// Customers further than Radius from the office are not eligible, DefaultRadius when not given
+	Radius *float64 `json:"radius"`
+	// Unit of Radius and of the distances of the candidates
+	Unit greatCircle.Unit `json:"unit"`
+	// Number of customers invited, the other eligible ones are waitlisted
+	Capacity int     `json:"capacity"`
+	RankBy   Ranking `json:"rank_by"`
+}
+
+// Fill in the defaults of e and check it
+func (e *Event) Validate() error {
+	if !ValidName(e.Name) {
+		return ErrInvalidName
+	}
+	if _, err := time.Parse(DateLayout, e.Date); err != nil {
+		return errors.New("Invalid event date " + strconv.Quote(e.Date) + ", expected YYYY-MM-DD")
+	}
+	if err := greatCircle.ValidateDegrees(e.Office.Latitude, e.Office.Longitude); err != nil {
+		return fmt.Errorf("Invalid event office: %w", err)
+	}
+	if e.Radius == nil {
+		radius := DefaultRadius
+		e.Radius = &radius
+	}
+	if !(*e.Radius > 0) || math.IsInf(*e.Radius, 1) {
+		return errors.New("Event radius must be > 0")
+	}
+	unit, err := greatCircle.ParseUnit(string(e.Unit))
+	if err != nil {
+		return err
+	}
+	e.Unit = unit
+	if e.Capacity < 1 {
+		return errors.New("Event capacity must be > 0")
+	}
+	if e.RankBy == "" {
+		e.RankBy = RankByDistance
+	}
+	if e.RankBy != RankByDistance && e.RankBy != RankByPriority {
+		return errors.New("Invalid event ranking " + string(e.RankBy) + ", expected distance or priority")
+	}
+	return nil
+}
+
+// A customer within the range of an event
+type Candidate struct {
+	User_id int    `json:"user_id"`
+	Name    string `json:"name"`
+	// Distance from the office of the event in the unit of the event
+	Distance float64 `json:"distance"`
+	Priority int     `json:"priority"`
+}
+
+// The customers invited to an event, in rank order, and the ones waiting for a place, in the order they get one
+type Invites struct {
+	Event    Event       `json:"event"`
+	Invited  []Candidate `json:"invited"`
+	Waitlist []Candidate `json:"waitlist"`
+}
+
+// Rank the candidates, invite the first Capacity ones and waitlist the others. Ties are broken by user id so that
+// the outcome does not depend on the order of the customer file
+func (e Event) Select(candidates []Candidate) Invites {
+	ranked := append([]Candidate(nil), candidates...)
+	sort.Slice(ranked, func(a, b int) bool {
+		x, y := ranked[a], ranked[b]
+		if e.RankBy == RankByPriority && x.Priority != y.Priority {
+			return x.Priority > y.Priority
+		}
+		if x.Distance != y.Distance {
+			return x.Distance < y.Distance
+		}
+		return x.User_id < y.User_id
+	})
+	split := e.Capacity
+	if split > len(ranked) {
+		split = len(ranked)
+	}
+	return Invites{Event: e, Invited: append([]Candidate{}, ranked[:split]...), Waitlist: append([]Candidate{}, ranked[split:]...)}
+}
+
+// Check that name is a valid event name
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
@@ -0,0 +1,196 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Return a pointer to the radius r
+func radius(r float64) *float64 {
+	return &r
+}
+
+type validateTest struct {
+	event     Event
+	errString string
+}
+
+var validateTests []validateTest = []validateTest{
+	validateTest{Event{Name: "party", Date: "2026-12-18", Capacity: 10}, ""},
+	validateTest{Event{Name: "a party", Date: "2026-12-18", Capacity: 10}, "Invalid event name"},
+	validateTest{Event{Name: "party", Date: "18/12/2026", Capacity: 10}, "Invalid event date"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Office: Office{Latitude: 91}, Capacity: 10}, "Invalid event office"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Office: Office{Longitude: -181}, Capacity: 10}, "Invalid event office: Invalid longitude -181"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Office: Office{Latitude: math.NaN()}, Capacity: 10}, "Invalid event office"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Radius: radius(-1), Capacity: 10}, "Event radius must be > 0"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Radius: radius(0), Capacity: 10}, "Event radius must be > 0"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Radius: radius(math.NaN()), Capacity: 10}, "Event radius must be > 0"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Radius: radius(25), Capacity: 10}, ""},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Unit: "miles", Capacity: 10}, "Invalid distance unit"},
+	validateTest{Event{Name: "party", Date: "2026-12-18"}, "Event capacity must be > 0"},
+	validateTest{Event{Name: "party", Date: "2026-12-18", Capacity: 10, RankBy: "age"}, "Invalid event ranking"},
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range validateTests {
+		err := test.event.Validate()
+		if (err == nil) != (test.errString == "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v for %v, expected %v", err, test.event, test.errString)
+		}
+	}
+	e := Event{Name: "party", Date: "2026-12-18", Capacity: 10}
+	if e.Validate(); e.Radius == nil || *e.Radius != DefaultRadius || e.Unit != greatCircle.Kilometre || e.RankBy != RankByDistance {
+		t.Errorf("Output %v, expected the default radius, unit and ranking", e)
+	}
+}
+
+var testCandidates = []Candidate{
+	Candidate{User_id: 4, Distance: 10, Priority: 1},
+	Candidate{User_id: 1, Distance: 30, Priority: 0},
+	Candidate{User_id: 3, Distance: 10, Priority: 0},
+	Candidate{User_id: 2, Distance: 20, Priority: 1},
+}
+
+type selectTest struct {
+	rankBy   Ranking
+	capacity int
+	invited  []int
+	waitlist []int
+}
+
+var selectTests []selectTest = []selectTest{
+	selectTest{RankByDistance, 2, []int{3, 4}, []int{2, 1}},
+	selectTest{RankByPriority, 2, []int{4, 2}, []int{3, 1}},
+	selectTest{RankByDistance, 10, []int{3, 4, 2, 1}, []int{}},
+}
+
+// Return the user ids of the candidates
+func userIDs(candidates []Candidate) []int {
+	ids := []int{}
+	for _, c := range candidates {
+		ids = append(ids, c.User_id)
+	}
+	return ids
+}
+
+func TestSelect(t *testing.T) {
+	for _, test := range selectTests {
+		invites := Event{Capacity: test.capacity, RankBy: test.rankBy}.Select(testCandidates)
+		invited, waitlist := userIDs(invites.Invited), userIDs(invites.Waitlist)
+		if !reflect.DeepEqual(invited, test.invited) || !reflect.DeepEqual(waitlist, test.waitlist) {
+			t.Errorf("Output %v %v for %v, expected %v %v", invited, waitlist, test.rankBy, test.invited, test.waitlist)
+		}
+	}
+	//the candidates are not reordered in place
+	if testCandidates[0].User_id != 4 {
+		t.Errorf("Output %v, expected the candidates unchanged", testCandidates)
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(Event{Name: "party", Date: "2026-12-18"}); err == nil {
+		t.Errorf("Expected an error for an event without capacity")
+	}
+	s.Put(Event{Name: "party", Date: "2026-12-18", Capacity: 10})
+	s.Put(Event{Name: "dinner", Date: "2026-12-18", Capacity: 10})
+	s.Put(Event{Name: "lunch", Date: "2026-11-01", Capacity: 10})
+	if _, err := s.Put(Event{Name: "party", Date: "2026-12-18", Capacity: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(Event{Name: "party", Date: "2026-12-18", Capacity: 30}); !errors.Is(err, ErrExists) {
+		t.Errorf("Output error %v, expected %v", err, ErrExists)
+	}
+	if err := s.Delete("lunch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("lunch"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Output error %v, expected %v", err, ErrNotFound)
+	}
+
+	//the events survive a restart
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := reopened.List()
+	if len(events) != 2 || events[0].Name != "dinner" || events[1].Name != "party" || events[1].Capacity != 20 {
+		t.Errorf("Output %v, expected dinner and party with a capacity of 20", events)
+	}
+}
+
+// Candidates read from a JSON array in the body
+func bodyCandidates(r *http.Request, e Event) ([]Candidate, error) {
+	var candidates []Candidate
+	if err := json.NewDecoder(r.Body).Decode(&candidates); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+type handlerTest struct {
+	method string
+	target string
+	body   string
+	status int
+}
+
+var handlerTests []handlerTest = []handlerTest{
+	handlerTest{"POST", "/v2/events", `{"name": "party", "date": "2026-12-18", "capacity": 1}`, http.StatusCreated},
+	handlerTest{"POST", "/v2/events", `{"name": "party", "date": "2026-12-18", "capacity": 2}`, http.StatusConflict},
+	handlerTest{"PUT", "/v2/events/party", `{"date": "2026-12-18", "capacity": 2}`, http.StatusOK},
+	handlerTest{"POST", "/v2/events", `{"name": "party", "date": "2026-12-18", "seats": 2}`, http.StatusBadRequest},
+	handlerTest{"POST", "/v2/events", `{"name": "party", "date": "tomorrow", "capacity": 2}`, http.StatusBadRequest},
+	handlerTest{"PUT", "/v2/events/dinner", `{"date": "2026-12-19", "capacity": 5}`, http.StatusCreated},
+	handlerTest{"PUT", "/v2/events/dinner", `{"name": "party", "date": "2026-12-19", "capacity": 5}`, http.StatusBadRequest},
+	handlerTest{"GET", "/v2/events", "", http.StatusOK},
+	handlerTest{"GET", "/v2/events/party", "", http.StatusOK},
+	handlerTest{"GET", "/v2/events/lunch", "", http.StatusNotFound},
+	handlerTest{"GET", "/v2/events/party/guests", "", http.StatusNotFound},
+	handlerTest{"POST", "/v2/events/party/invites", `[{"user_id": 1, "distance": 5}, {"user_id": 2, "distance": 1}, {"user_id": 3, "distance": 3}]`, http.StatusOK},
+	handlerTest{"GET", "/v2/events/party/invites", "", http.StatusMethodNotAllowed},
+	handlerTest{"POST", "/v2/events/lunch/invites", "[]", http.StatusNotFound},
+	handlerTest{"DELETE", "/v2/events/dinner", "", http.StatusNoContent},
+	handlerTest{"DELETE", "/v2/events/dinner", "", http.StatusNotFound},
+	handlerTest{"DELETE", "/v2/events", "", http.StatusMethodNotAllowed},
+}
+
+func TestHandler(t *testing.T) {
+	s, err := OpenStore(filepath.Join(t.TempDir(), "events.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := util.ErrorHandler(s.Handler("/v2/events", bodyCandidates))
+	var last *httptest.ResponseRecorder
+	for _, test := range handlerTests {
+		writer := httptest.NewRecorder()
+		handler(writer, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+		if writer.Code != test.status {
+			t.Errorf("Output %v for %v %v, expected %v", writer.Code, test.method, test.target, test.status)
+		}
+		if strings.HasSuffix(test.target, "/invites") && writer.Code == http.StatusOK {
+			last = writer
+		}
+	}
+	var invites Invites
+	if err := json.Unmarshal(last.Body.Bytes(), &invites); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(userIDs(invites.Invited), []int{2, 3}) || !reflect.DeepEqual(userIDs(invites.Waitlist), []int{1}) {
+		t.Errorf("Output %v, expected customers 2 and 3 invited and customer 1 waitlisted", invites)
+	}
+}
@@ -0,0 +1,115 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Maximum size of an event body
+const maxBodySize = 64 << 10
+
+// Return the candidates of an event from the customer files of a request
+type CandidatesFunc func(r *http.Request, e Event) ([]Candidate, error)
+
+// Return a handler for prefix (GET lists the events, POST creates one from a JSON body and answers 409 if the name is
+// taken), prefix/{name} (GET returns the event, PUT replaces it, DELETE deletes it) and prefix/{name}/invites (PUT or
+// POST with the customer files, answers the invited customers and the waitlist). The customers are read with candidates
+func (s *Store) Handler(prefix string, candidates CandidatesFunc) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if path == "" {
+			switch r.Method {
+			case http.MethodGet:
+				return util.WriteJSON(w, http.StatusOK, s.List())
+			case http.MethodPost:
+				return s.put(w, r, prefix, "")
+			}
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET or POST request")
+		}
+		parts := strings.Split(path, "/")
+		if len(parts) > 2 || !ValidName(parts[0]) || (len(parts) == 2 && parts[1] != "invites") {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: ErrNotFound}
+		}
+		name := parts[0]
+
+		if len(parts) == 2 {
+			if http.MethodPut != r.Method && http.MethodPost != r.Method {
+				return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a PUT or POST request")
+			}
+			e, err := s.Get(name)
+			if err != nil {
+				return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+			}
+			c, err := candidates(r, e)
+			if err != nil {
+				return err
+			}
+			return util.WriteJSON(w, http.StatusOK, e.Select(c))
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			e, err := s.Get(name)
+			if err != nil {
+				return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+			}
+			return util.WriteJSON(w, http.StatusOK, e)
+		case http.MethodPut:
+			return s.put(w, r, prefix, name)
+		case http.MethodDelete:
+			if err := s.Delete(name); errors.Is(err, ErrNotFound) {
+				return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+			} else if err != nil {
+				return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET, PUT or DELETE request")
+	}
+}
+
+// Create or replace the event given in the JSON body, answering 201 when it is created. With a name from the URL,
+// the body may omit it but must not name another event. Without, the event is only created
+func (s *Store) put(w http.ResponseWriter, r *http.Request, prefix string, name string) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	var e Event
+	if err := decoder.Decode(&e); err != nil {
+		return util.NewHTTPError(http.StatusBadRequest, "Invalid event: "+err.Error())
+	}
+	if name != "" {
+		if e.Name != "" && e.Name != name {
+			return util.NewHTTPError(http.StatusBadRequest, "Event name "+e.Name+" does not match the URL")
+		}
+		e.Name = name
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if name == "" {
+		created, err := s.Create(e)
+		if errors.Is(err, ErrExists) {
+			return &util.HTTPError{Status: http.StatusConflict, Err: err}
+		} else if err != nil {
+			return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+		}
+		w.Header().Set("Location", prefix+"/"+created.Name)
+		return util.WriteJSON(w, http.StatusCreated, created)
+	}
+	_, err := s.Get(e.Name)
+	created := errors.Is(err, ErrNotFound)
+	if e, err = s.Put(e); err != nil {
+		return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+	}
+	if created {
+		w.Header().Set("Location", prefix+"/"+e.Name)
+		return util.WriteJSON(w, http.StatusCreated, e)
+	}
+	return util.WriteJSON(w, http.StatusOK, e)
+}
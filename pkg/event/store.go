@@ -0,0 +1,135 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Keeps the events in memory and persists all of them in a JSON file after every change
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	events map[string]Event
+}
+
+// Open the store persisted at path, which is created on the first change
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, events: make(map[string]Event)}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	if err := json.Unmarshal(b, &events); err != nil {
+		return nil, errors.New("Cannot load events " + path + " : " + err.Error())
+	}
+	for _, e := range events {
+		s.events[e.Name] = e
+	}
+	return s, nil
+}
+
+// Validate an event and create it, or replace the event of the same name
+func (s *Store) Put(e Event) (Event, error) {
+	return s.store(e, true)
+}
+
+// Validate an event and create it, ErrExists is returned if an event has the same name
+func (s *Store) Create(e Event) (Event, error) {
+	return s.store(e, false)
+}
+
+// Validate an event and save it, replacing the event of the same name only if replace is set
+func (s *Store) store(e Event, replace bool) (Event, error) {
+	if err := e.Validate(); err != nil {
+		return Event{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, found := s.events[e.Name]
+	if found && !replace {
+		return Event{}, ErrExists
+	}
+	s.events[e.Name] = e
+	if err := s.save(); err != nil {
+		if found {
+			s.events[e.Name] = previous
+		} else {
+			delete(s.events, e.Name)
+		}
+		return Event{}, err
+	}
+	return e, nil
+}
+
+// Return an event
+func (s *Store) Get(name string) (Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.events[name]
+	if !found {
+		return Event{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// Delete an event
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.events[name]
+	if !found {
+		return ErrNotFound
+	}
+	delete(s.events, name)
+	if err := s.save(); err != nil {
+		s.events[name] = e
+		return err
+	}
+	return nil
+}
+
+// Return every event, sorted by date then name
+func (s *Store) List() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]Event, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, e)
+	}
+	sort.Slice(events, func(a, b int) bool {
+		if events[a].Date != events[b].Date {
+			return events[a].Date < events[b].Date
+		}
+		return events[a].Name < events[b].Name
+	})
+	return events
+}
+
+// Readiness check reporting whether the store file can be written
+func (s *Store) CheckStore() error {
+	return util.CheckWritable(filepath.Dir(s.path))
+}
+
+// Persist every event through a temporary file, so a crash never leaves a partial file behind. Called with s.mu held
+func (s *Store) save() error {
+	events := make([]Event, 0, len(s.events))
+	for _, e := range s.events {
+		events = append(events, e)
+	}
+	sort.Slice(events, func(a, b int) bool { return events[a].Name < events[b].Name })
+	b, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(s.path, b)
+}
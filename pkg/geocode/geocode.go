@@ -0,0 +1,200 @@
+// Package geocode finds the nearest named place of a location, and the place of a name, in an offline list of places
+package geocode
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Bundled extract of the GeoNames cities, in the format read by Load
+//
+//go:embed places.tsv
+var bundledPlaces string
+
+// A town or city
+type Place struct {
+	Name       string  `json:"name"`
+	County     string  `json:"county"`
+	Country    string  `json:"country"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Population int     `json:"population"`
+	// Latitude and Longitude in radian
+	location greatCircle.Point
+}
+
+// Location of the place, in radian
+func (p Place) Location() greatCircle.Point {
+	return p.location
+}
+
+// An immutable list of places
+type Gazetteer struct {
+	places []Place
+}
+
+// Gazetteer of the bundled places, the towns and cities of Ireland and Northern Ireland
+var Default = mustLoad(bundledPlaces)
+
+func mustLoad(s string) *Gazetteer {
+	g, err := Load(strings.NewReader(s))
+	if err != nil {
+		panic("Invalid bundled places: " + err.Error())
+	}
+	return g
+}
+
+// Read the places of a tab separated file, one place per line with the columns
+// name, county, country, latitude, longitude and population (the latitude and longitude in degree).
+// Empty lines and lines starting with '#' are skipped
+func Load(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		p, err := parsePlace(text)
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + " : " + err.Error())
+		}
+		g.places = append(g.places, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.places) == 0 {
+		return nil, errors.New("No place found")
+	}
+	return g, nil
+}
+
+// Read the places of the file at path, see Load
+func LoadFile(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := Load(f)
+	if err != nil {
+		return nil, errors.New("Cannot load places " + path + " : " + err.Error())
+	}
+	return g, nil
+}
+
+func parsePlace(line string) (Place, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 6 {
+		return Place{}, errors.New("expected 6 tab separated columns (name, county, country, latitude, longitude, population), got " + strconv.Itoa(len(fields)))
+	}
+	p := Place{Name: strings.TrimSpace(fields[0]), County: strings.TrimSpace(fields[1]), Country: strings.TrimSpace(fields[2])}
+	if p.Name == "" {
+		return Place{}, errors.New("empty name")
+	}
+	var err error
+	if p.Latitude, err = strconv.ParseFloat(strings.TrimSpace(fields[3]), 64); err != nil {
+		return Place{}, errors.New("invalid latitude " + fields[3])
+	}
+	if p.Longitude, err = strconv.ParseFloat(strings.TrimSpace(fields[4]), 64); err != nil {
+		return Place{}, errors.New("invalid longitude " + fields[4])
+	}
+	if population := strings.TrimSpace(fields[5]); population != "" {
+		if p.Population, err = strconv.Atoi(population); err != nil {
+			return Place{}, errors.New("invalid population " + fields[5])
+		}
+	}
+	p.location = greatCircle.MakePoint(greatCircle.DegreeToRadian(p.Longitude), greatCircle.DegreeToRadian(p.Latitude))
+	if err := p.location.Validate(); err != nil {
+		return Place{}, err
+	}
+	return p, nil
+}
+
+// Number of places
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// Return the place nearest to location (in radian) and its distance in km on the mean earth radius. Places at the
+// same distance are broken by the order of the file
+func (g *Gazetteer) Nearest(location greatCircle.Point) (Place, float64) {
+	nearest, distance := 0, -1.0
+	for i := range g.places {
+		d := greatCircle.Distance(location, g.places[i].location, greatCircle.Radius)
+		if distance < 0 || d < distance {
+			nearest, distance = i, d
+		}
+	}
+	return g.places[nearest], distance
+}
+
+// No place matches the looked up label
+var ErrPlaceNotFound = errors.New("Place not found")
+
+// Several places match the looked up label
+type AmbiguousError struct {
+	Label string
+	// The matching places
+	Places []Place
+}
+
+func (e *AmbiguousError) Error() string {
+	labels := make([]string, 0, len(e.Places))
+	for _, p := range e.Places {
+		labels = append(labels, p.Label())
+	}
+	return "Ambiguous place " + e.Label + ", it matches " + strings.Join(labels, " ; ")
+}
+
+// Label of the place, "name, county, country"
+func (p Place) Label() string {
+	return strings.Join([]string{p.Name, p.County, p.Country}, ", ")
+}
+
+// Return the place of a label "name", "name, country", "name, county" or "name, county, country", e.g. "Dublin, IE".
+// The comparison ignores the case. ErrPlaceNotFound is returned when no place matches and an *AmbiguousError when
+// several do
+func (g *Gazetteer) Lookup(label string) (Place, error) {
+	parts := strings.Split(label, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if len(parts) > 3 || parts[0] == "" {
+		return Place{}, errors.New("Invalid place " + label + ", expected name, county, country")
+	}
+	var matches []Place
+	for _, p := range g.places {
+		if !strings.EqualFold(p.Name, parts[0]) {
+			continue
+		}
+		switch len(parts) {
+		case 2:
+			if !strings.EqualFold(p.Country, parts[1]) && !strings.EqualFold(p.County, parts[1]) {
+				continue
+			}
+		case 3:
+			if !strings.EqualFold(p.County, parts[1]) || !strings.EqualFold(p.Country, parts[2]) {
+				continue
+			}
+		}
+		matches = append(matches, p)
+	}
+	switch len(matches) {
+	case 0:
+		return Place{}, ErrPlaceNotFound
+	case 1:
+		return matches[0], nil
+	}
+	return Place{}, &AmbiguousError{Label: label, Places: matches}
+}
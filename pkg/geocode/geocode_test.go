@@ -0,0 +1,127 @@
+package geocode
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Return a point from degrees
+func degreePoint(latitude float64, longitude float64) greatCircle.Point {
+	return greatCircle.MakePoint(greatCircle.DegreeToRadian(longitude), greatCircle.DegreeToRadian(latitude))
+}
+
+type nearestTest struct {
+	latitude  float64
+	longitude float64
+	name      string
+	county    string
+	country   string
+}
+
+var nearestTests []nearestTest = []nearestTest{
+	nearestTest{53.339428, -6.257664, "Dublin", "Dublin", "IE"},
+	nearestTest{52.986375, -6.043701, "Wicklow", "Wicklow", "IE"},
+	nearestTest{51.92893, -10.27699, "Dingle", "Kerry", "IE"},
+	nearestTest{54.0894797, -6.18671, "Newry", "Down", "GB"},
+	nearestTest{54.6, -5.9, "Belfast", "Antrim", "GB"},
+}
+
+func TestNearest(t *testing.T) {
+	for _, test := range nearestTests {
+		p, distance := Default.Nearest(degreePoint(test.latitude, test.longitude))
+		if p.Name != test.name || p.County != test.county || p.Country != test.country {
+			t.Errorf("Output %v for %v, %v, expected %v, %v, %v", p, test.latitude, test.longitude, test.name, test.county, test.country)
+		}
+		if expected := greatCircle.Distance(degreePoint(test.latitude, test.longitude), p.Location(), greatCircle.Radius); math.Abs(distance-expected) > 1e-9 {
+			t.Errorf("Output %v, expected %v", distance, expected)
+		}
+	}
+}
+
+type loadTest struct {
+	content string
+	places  int
+	err     string
+}
+
+var loadTests []loadTest = []loadTest{
+	loadTest{"# comment\nA\tX\tIE\t53\t-6\t100\n\nB\tY\tIE\t54\t-7\t\n", 2, ""},
+	loadTest{"A\tX\tIE\t53\t-6\t100\r\n", 1, ""},
+	loadTest{"", 0, "No place found"},
+	loadTest{"A\tX\tIE\t53\t-6\n", 0, "line 1 : expected 6 tab separated columns"},
+	loadTest{"A\tX\tIE\t53\t-6\t1\nB\tY\tIE\tnorth\t-6\t1\n", 0, "line 2 : invalid latitude north"},
+	loadTest{"A\tX\tIE\t53\t-190\t1\n", 0, "line 1 : Invalid longitude -190, expected a value between -180 and 180"},
+	loadTest{"A\tX\tIE\t53\t-6\tmany\n", 0, "line 1 : invalid population many"},
+	loadTest{"\tX\tIE\t53\t-6\t1\n", 0, "line 1 : empty name"},
+}
+
+func TestLoad(t *testing.T) {
+	for _, test := range loadTests {
+		g, err := Load(strings.NewReader(test.content))
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("Output %v for %q, expected %v", err, test.content, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Output %v for %q, expected no error", err, test.content)
+			continue
+		}
+		if g.Len() != test.places {
+			t.Errorf("Output %v places for %q, expected %v", g.Len(), test.content, test.places)
+		}
+	}
+}
+
+// Two Newbridge, in Kildare and in Galway
+var ambiguousPlaces = "Newbridge\tKildare\tIE\t53.1819\t-6.7967\t22742\n" +
+	"Newbridge\tGalway\tIE\t53.4667\t-8.4167\t200\n" +
+	"Dublin\tDublin\tIE\t53.3498\t-6.2603\t544107\n"
+
+type lookupTest struct {
+	label  string
+	county string
+	err    string
+}
+
+var lookupTests []lookupTest = []lookupTest{
+	lookupTest{"Dublin", "Dublin", ""},
+	lookupTest{" dublin , ie ", "Dublin", ""},
+	lookupTest{"Newbridge, Galway", "Galway", ""},
+	lookupTest{"Newbridge, Kildare, IE", "Kildare", ""},
+	lookupTest{"Newbridge", "", "Ambiguous place Newbridge, it matches Newbridge, Kildare, IE ; Newbridge, Galway, IE"},
+	lookupTest{"Newbridge, IE", "", "Ambiguous place Newbridge, IE"},
+	lookupTest{"Newbridge, Galway, GB", "", "Place not found"},
+	lookupTest{"Cork", "", "Place not found"},
+	lookupTest{"", "", "Invalid place"},
+	lookupTest{"a, b, c, d", "", "Invalid place"},
+}
+
+func TestLookup(t *testing.T) {
+	g, err := Load(strings.NewReader(ambiguousPlaces))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range lookupTests {
+		p, err := g.Lookup(test.label)
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("Output %v for %q, expected %v", err, test.label, test.err)
+			}
+			continue
+		}
+		if err != nil || p.County != test.county {
+			t.Errorf("Output %v, %v for %q, expected the place in %v", p, err, test.label, test.county)
+		}
+	}
+	if _, err := g.Lookup("Newbridge"); len(err.(*AmbiguousError).Places) != 2 {
+		t.Errorf("Output %v, expected 2 places", err)
+	}
+	if p, err := Default.Lookup("Dublin, IE"); err != nil || !p.Location().Valid() {
+		t.Errorf("Output %v, %v, expected the bundled Dublin", p, err)
+	}
+}
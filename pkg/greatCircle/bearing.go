@@ -0,0 +1,51 @@
+package greatCircle
+
+import "math"
+
+// Return the initial bearing in degrees (0 to 360, clockwise from north) of the great circle from p1 to p2.
+// Assume longitude and latitude are in radian already
+func InitialBearing(p1 Point, p2 Point) float64 {
+	deltaLongitude := p2.Longitude - p1.Longitude
+	y := math.Sin(deltaLongitude) * math.Cos(p2.Latitude)
+	x := math.Cos(p1.Latitude)*math.Sin(p2.Latitude) - math.Sin(p1.Latitude)*math.Cos(p2.Latitude)*math.Cos(deltaLongitude)
+	return math.Mod(RadianToDegree(math.Atan2(y, x))+360, 360)
+}
+
+// Points of the 16-wind compass rose, clockwise from north
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// Return the compass direction of a bearing in degrees with points (4, 8 or 16) directions, e.g. "NE" for 40 with 8
+func CompassDirection(bearing float64, points int) string {
+	if points != 4 && points != 8 {
+		points = 16
+	}
+	step := len(compassPoints) / points
+	sector := 360 / float64(points)
+	i := int(math.Floor(math.Mod(math.Mod(bearing, 360)+360+sector/2, 360) / sector))
+	return compassPoints[i*step]
+}
+
+// Return the point halfway along the great circle between p1 and p2
+func Midpoint(p1 Point, p2 Point) Point {
+	deltaLongitude := p2.Longitude - p1.Longitude
+	bx := math.Cos(p2.Latitude) * math.Cos(deltaLongitude)
+	by := math.Cos(p2.Latitude) * math.Sin(deltaLongitude)
+	latitude := math.Atan2(math.Sin(p1.Latitude)+math.Sin(p2.Latitude), math.Sqrt((math.Cos(p1.Latitude)+bx)*(math.Cos(p1.Latitude)+bx)+by*by))
+	longitude := p1.Longitude + math.Atan2(by, math.Cos(p1.Latitude)+bx)
+	return Point{normalizeLongitude(longitude), latitude}
+}
+
+// Return the point reached from p by travelling distance along the great circle of initial bearing (degrees) on a
+// sphere of radius, in the same unit as distance
+func Destination(p Point, bearing float64, distance float64, radius float64) Point {
+	angularDistance := distance / radius
+	theta := DegreeToRadian(bearing)
+	latitude := math.Asin(math.Sin(p.Latitude)*math.Cos(angularDistance) + math.Cos(p.Latitude)*math.Sin(angularDistance)*math.Cos(theta))
+	longitude := p.Longitude + math.Atan2(math.Sin(theta)*math.Sin(angularDistance)*math.Cos(p.Latitude), math.Cos(angularDistance)-math.Sin(p.Latitude)*math.Sin(latitude))
+	return Point{normalizeLongitude(longitude), latitude}
+}
+
+// Bring a longitude in radian back to -Pi..Pi
+func normalizeLongitude(longitude float64) float64 {
+	return math.Mod(longitude+3*math.Pi, 2*math.Pi) - math.Pi
+}
@@ -0,0 +1,142 @@
+package greatCircle
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Parse a latitude in degrees. Besides decimal degrees ("53.339428", "-53.339428"), it accepts degrees minutes
+// seconds ("53°20'22\"N", "53 20 22.5 N", "53d20m22s"), degrees decimal minutes ("53°20.367'N") and a hemisphere
+// letter N or S, in either case, before or after the value ("N53.339428", "53.339428 s"). A trailing s right after
+// the third number marks the seconds, not the hemisphere. A hemisphere and a sign cannot be combined. NaN, infinities
+// and exponents are rejected
+func ParseLatitude(s string) (float64, error) {
+	return parseCoordinate(s, "latitude", 'N', 'S')
+}
+
+// Parse a longitude in degrees, in the notations of ParseLatitude with the hemisphere letters E and W
+func ParseLongitude(s string) (float64, error) {
+	return parseCoordinate(s, "longitude", 'E', 'W')
+}
+
+// Letters of the hemispheres, of either axis
+const hemispheres = "NSEWnsew"
+
+// Markers following the degrees, minutes and seconds of a coordinate
+var dmsMarkers = [3]string{"°º˚d", "'′’m", "\"″”s"}
+
+var (
+	// Decimal degrees, without the exponents, NaN and infinities also accepted by strconv.ParseFloat
+	decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	// The numbers of a coordinate
+	numberPattern = regexp.MustCompile(`[0-9.]+`)
+)
+
+// Check if the last rune of a coordinate is the marker of its seconds: an s right after its third number
+func secondsMarker(runes []rune) bool {
+	n := len(runes)
+	if n < 2 || unicode.ToLower(runes[n-1]) != 's' || !(unicode.IsDigit(runes[n-2]) || runes[n-2] == '.') {
+		return false
+	}
+	return len(numberPattern.FindAllString(string(runes[:n-1]), -1)) == 3
+}
+
+// Parse a coordinate named name, positive and negative are its hemisphere letters
+func parseCoordinate(s string, name string, positive rune, negative rune) (float64, error) {
+	//decimal degrees are read as they always were
+	if decimalPattern.MatchString(s) {
+		if degrees, err := strconv.ParseFloat(s, 64); err == nil {
+			return degrees, nil
+		}
+	}
+	invalid := func(reason string) (float64, error) {
+		return 0, errors.New("Cannot parse " + name + " " + strconv.Quote(s) + " : " + reason)
+	}
+
+	value := strings.TrimSpace(strings.ReplaceAll(s, "''", "\""))
+	sign := 1.0
+	hemisphere := false
+	if value != "" {
+		runes := []rune(value)
+		first, last := runes[0], runes[len(runes)-1]
+		letter := rune(0)
+		if strings.ContainsRune(hemispheres, first) {
+			letter, value = first, strings.TrimSpace(string(runes[1:]))
+		} else if strings.ContainsRune(hemispheres, last) && !secondsMarker(runes) {
+			letter, value = last, strings.TrimSpace(string(runes[:len(runes)-1]))
+		}
+		switch unicode.ToUpper(letter) {
+		case 0:
+		case positive:
+			hemisphere = true
+		case negative:
+			hemisphere, sign = true, -1
+		default:
+			return invalid("expected the hemisphere " + string(positive) + " or " + string(negative))
+		}
+	}
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		if hemisphere {
+			return invalid("a sign cannot be combined with a hemisphere")
+		}
+		if value[0] == '-' {
+			sign = -1
+		}
+		value = strings.TrimSpace(value[1:])
+	}
+
+	//up to 3 numbers, each optionally followed by the marker of its position
+	var parts []float64
+	var decimals []bool
+	rest := value
+	for rest != "" {
+		if len(parts) == 3 {
+			return invalid(strconv.ErrSyntax.Error())
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+		if end == -1 {
+			end = len(rest)
+		}
+		number, err := strconv.ParseFloat(rest[:end], 64)
+		if err != nil || strings.HasPrefix(rest, ".") {
+			return invalid(strconv.ErrSyntax.Error())
+		}
+		parts, decimals = append(parts, number), append(decimals, strings.Contains(rest[:end], "."))
+		rest = strings.TrimSpace(rest[end:])
+		for i, markers := range dmsMarkers {
+			if r := []rune(rest); len(r) > 0 && strings.ContainsRune(markers, unicode.ToLower(r[0])) {
+				if i != len(parts)-1 {
+					return invalid("unexpected " + string(r[0]))
+				}
+				rest = strings.TrimSpace(string(r[1:]))
+				break
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return invalid(strconv.ErrSyntax.Error())
+	}
+	//only the last number can have decimals
+	for i := 0; i < len(parts)-1; i++ {
+		if decimals[i] {
+			return invalid("only the last number can have decimals")
+		}
+	}
+	degrees := parts[0]
+	if len(parts) > 1 {
+		if parts[1] >= 60 {
+			return invalid("minutes must be < 60")
+		}
+		degrees += parts[1] / 60
+	}
+	if len(parts) > 2 {
+		if parts[2] >= 60 {
+			return invalid("seconds must be < 60")
+		}
+		degrees += parts[2] / 3600
+	}
+	return sign * degrees, nil
+}
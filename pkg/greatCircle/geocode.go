@@ -0,0 +1,204 @@
+package greatCircle
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Alphabet of the geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Longest geohash, about 3.7 cm x 1.9 cm
+const MaxGeohashPrecision = 12
+
+// Return the geohash of the point with precision characters, between 1 and MaxGeohashPrecision
+func (p Point) Geohash(precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+	latitude, longitude := RadianToDegree(p.Latitude), RadianToDegree(p.Longitude)
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var b strings.Builder
+	bit, index, even := 0, 0, true
+	for b.Len() < precision {
+		//the bits alternate between longitude and latitude, starting with the longitude
+		value, r := latitude, &latRange
+		if even {
+			value, r = longitude, &lonRange
+		}
+		middle := (r[0] + r[1]) / 2
+		index <<= 1
+		if value >= middle {
+			index |= 1
+			r[0] = middle
+		} else {
+			r[1] = middle
+		}
+		even = !even
+		if bit++; bit == 5 {
+			b.WriteByte(geohashAlphabet[index])
+			bit, index = 0, 0
+		}
+	}
+	return b.String()
+}
+
+// Return the center of the cell of a geohash
+func DecodeGeohash(s string) (Point, error) {
+	if s == "" || len(s) > MaxGeohashPrecision {
+		return Point{}, errors.New("Invalid geohash " + strconv.Quote(s) + ", expected 1 to " + strconv.Itoa(MaxGeohashPrecision) + " characters")
+	}
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(s) {
+		index := strings.IndexRune(geohashAlphabet, c)
+		if index == -1 {
+			return Point{}, errors.New("Invalid geohash " + strconv.Quote(s) + ", unexpected " + strconv.QuoteRune(c))
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			middle := (r[0] + r[1]) / 2
+			if index&mask != 0 {
+				r[0] = middle
+			} else {
+				r[1] = middle
+			}
+			even = !even
+		}
+	}
+	return MakePoint(DegreeToRadian((lonRange[0]+lonRange[1])/2), DegreeToRadian((latRange[0]+latRange[1])/2)), nil
+}
+
+// Alphabet of the Open Location Codes
+const plusCodeAlphabet = "23456789CFGHJMPQRVWX"
+
+const (
+	// Length of a Plus Code of about 14 m x 14 m, the usual length
+	PlusCodeLength = 10
+	// Longest Plus Code
+	MaxPlusCodeLength = 15
+	// Position of the "+" separator
+	plusCodeSeparator = 8
+	// Length of the part encoding latitude and longitude in pairs of digits
+	plusCodePairLength = 10
+	// Rows and columns of the grid refining a Plus Code after the pairs
+	plusCodeGridRows    = 5
+	plusCodeGridColumns = 4
+)
+
+// Return the Open Location Code (Plus Code) of the point with length digits: 2, 4, 6, 8, or 10 to 15
+func (p Point) PlusCode(length int) string {
+	if length < 2 {
+		length = 2
+	}
+	if length < plusCodePairLength && length%2 == 1 {
+		length++
+	}
+	if length > MaxPlusCodeLength {
+		length = MaxPlusCodeLength
+	}
+	latitude := math.Min(math.Max(RadianToDegree(p.Latitude), -90), 90)
+	longitude := math.Mod(RadianToDegree(p.Longitude)+180, 360)
+	if longitude < 0 {
+		longitude += 360
+	}
+	//the north pole belongs to the cell below it
+	if latitude == 90 {
+		latitude -= plusCodeCellHeight(length) / 2
+	}
+	latitude += 90
+
+	//work in integers of the smallest cell, so that the digits are exact
+	latUnits := int64(math.Floor(latitude * 8000 * math.Pow(plusCodeGridRows, MaxPlusCodeLength-plusCodePairLength)))
+	lonUnits := int64(math.Floor(longitude * 8000 * math.Pow(plusCodeGridColumns, MaxPlusCodeLength-plusCodePairLength)))
+	digits := make([]byte, MaxPlusCodeLength)
+	for i := MaxPlusCodeLength - 1; i >= plusCodePairLength; i-- {
+		digits[i] = plusCodeAlphabet[(latUnits%plusCodeGridRows)*plusCodeGridColumns+lonUnits%plusCodeGridColumns]
+		latUnits /= plusCodeGridRows
+		lonUnits /= plusCodeGridColumns
+	}
+	for i := plusCodePairLength - 2; i >= 0; i -= 2 {
+		digits[i] = plusCodeAlphabet[latUnits%20]
+		digits[i+1] = plusCodeAlphabet[lonUnits%20]
+		latUnits /= 20
+		lonUnits /= 20
+	}
+
+	code := string(digits[:plusCodeSeparator])
+	if length < plusCodeSeparator {
+		code = string(digits[:length]) + strings.Repeat("0", plusCodeSeparator-length)
+	}
+	code += "+"
+	if length > plusCodeSeparator {
+		code += string(digits[plusCodeSeparator:length])
+	}
+	return code
+}
+
+// Height in degrees of the cell of a Plus Code of length digits
+func plusCodeCellHeight(length int) float64 {
+	if length <= plusCodePairLength {
+		return math.Pow(20, float64(2-length/2))
+	}
+	return math.Pow(20, -3) / math.Pow(plusCodeGridRows, float64(length-plusCodePairLength))
+}
+
+// Return the center of the cell of a full Plus Code, e.g. "9C5M8PQ5+R3". Short codes, which need a reference
+// location, are not accepted
+func DecodePlusCode(s string) (Point, error) {
+	invalid := func(reason string) (Point, error) {
+		return Point{}, errors.New("Invalid Plus Code " + strconv.Quote(s) + ", " + reason)
+	}
+	code := strings.ToUpper(s)
+	separator := strings.Index(code, "+")
+	if separator != plusCodeSeparator || strings.Count(code, "+") != 1 {
+		if separator >= 0 && separator < plusCodeSeparator && strings.Count(code, "+") == 1 {
+			return invalid("short codes are not accepted")
+		}
+		return invalid("expected 8 digits before the \"+\"")
+	}
+	digits := code[:separator] + code[separator+1:]
+	if padding := strings.Index(digits, "0"); padding != -1 {
+		if padding == 0 || padding%2 == 1 || strings.Trim(digits[padding:], "0") != "" || len(code) > separator+1 {
+			return invalid("unexpected padding")
+		}
+		digits = digits[:padding]
+	}
+	if len(digits) > MaxPlusCodeLength {
+		digits = digits[:MaxPlusCodeLength]
+	}
+	if len(digits) == plusCodeSeparator+1 {
+		return invalid("expected at least 2 digits after the \"+\"")
+	}
+	for _, c := range digits {
+		if !strings.ContainsRune(plusCodeAlphabet, c) {
+			return invalid("unexpected " + strconv.QuoteRune(c))
+		}
+	}
+	if strings.IndexByte(plusCodeAlphabet, digits[0]) > 8 || (len(digits) > 1 && strings.IndexByte(plusCodeAlphabet, digits[1]) > 17) {
+		return invalid("out of range")
+	}
+
+	latitude, longitude := -90.0, -180.0
+	height, width := 400.0, 400.0
+	for i := 0; i < len(digits) && i < plusCodePairLength; i += 2 {
+		height, width = height/20, width/20
+		latitude += float64(strings.IndexByte(plusCodeAlphabet, digits[i])) * height
+		longitude += float64(strings.IndexByte(plusCodeAlphabet, digits[i+1])) * width
+	}
+	for i := plusCodePairLength; i < len(digits); i++ {
+		height, width = height/plusCodeGridRows, width/plusCodeGridColumns
+		index := strings.IndexByte(plusCodeAlphabet, digits[i])
+		latitude += float64(index/plusCodeGridColumns) * height
+		longitude += float64(index%plusCodeGridColumns) * width
+	}
+	return MakePoint(DegreeToRadian(longitude+width/2), DegreeToRadian(math.Min(latitude+height/2, 90))), nil
+}
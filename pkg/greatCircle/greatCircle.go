@@ -46,8 +46,15 @@ func DegreeToRadian(degree float64) float64 {
 	return degree * math.Pi / 180.0
 }
 
+// Helper to convert radian to degree
+func RadianToDegree(radian float64) float64 {
+	return radian * 180.0 / math.Pi
+}
+
 // Return the distance between 2 points. Assume longtitude and latitdue are in radian already
 func Distance(p1 Point, p2 Point, radius float64) float64 {
-	centralAngle := math.Acos(math.Sin(p1.Latitude)*math.Sin(p2.Latitude) + math.Cos(p1.Latitude)*math.Cos(p2.Latitude)*math.Cos(math.Abs(p1.Longitude-p2.Longitude)))
+	cosine := math.Sin(p1.Latitude)*math.Sin(p2.Latitude) + math.Cos(p1.Latitude)*math.Cos(p2.Latitude)*math.Cos(math.Abs(p1.Longitude-p2.Longitude))
+	//rounding can take the cosine of (almost) identical points above 1, where Acos is NaN
+	centralAngle := math.Acos(math.Max(-1, math.Min(1, cosine)))
 	return radius * centralAngle
 }
@@ -1,7 +1,9 @@
 package greatCircle
 
 import (
+	"errors"
 	"math"
+	"strings"
 	"testing"
 
 	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
@@ -63,6 +65,14 @@ func TestDegreeToRadian(t *testing.T) {
 	}
 }
 
+func TestRadianToDegree(t *testing.T) {
+	for _, test := range degreeToRadianTests {
+		if d := RadianToDegree(test.expected); !util.Equal(d, test.degree) {
+			t.Errorf("Output %v not equal to expected %v", d, test.degree)
+		}
+	}
+}
+
 type distanceTest struct {
 	p1, p2   Point
 	radius   float64
@@ -76,6 +86,8 @@ var distanceTests []distanceTest = []distanceTest{
 	distanceTest{Point{DegreeToRadian(-6.257664), DegreeToRadian(53.339428)}, Point{DegreeToRadian(-6.257664), DegreeToRadian(53.339428)}, 6371.009, 0},
 	distanceTest{Point{DegreeToRadian(-7.257664), DegreeToRadian(53.339428)}, Point{DegreeToRadian(-6.257664), DegreeToRadian(53.339428)}, 6371.009, 66.391069412779},
 	distanceTest{Point{DegreeToRadian(28), DegreeToRadian(55)}, Point{DegreeToRadian(70), DegreeToRadian(86)}, 10, 5.60686309},
+	//the cosine of the central angle rounds above 1
+	distanceTest{Point{DegreeToRadian(-6.27), DegreeToRadian(53.33)}, Point{DegreeToRadian(-6.27), DegreeToRadian(53.33)}, 6371.009, 0},
 }
 
 func TestDistance(t *testing.T) {
@@ -86,6 +98,19 @@ func TestDistance(t *testing.T) {
 	}
 }
 
+// The distance between a point and itself is a number, also where the rounding takes the cosine of the central angle
+// above 1 (about one point in ten of the grid). Near 1 the arc cosine leaves a rounding error of a fraction of a metre
+func TestDistanceIdenticalPoints(t *testing.T) {
+	for latitude := -89.0; latitude <= 89; latitude += 0.37 {
+		for longitude := -179.0; longitude <= 179; longitude += 1.13 {
+			p := degreePoint(latitude, longitude)
+			if d := Distance(p, p, Radius); math.IsNaN(d) || d > 1e-3 {
+				t.Fatalf("Output %v for %v, %v, expected 0", d, latitude, longitude)
+			}
+		}
+	}
+}
+
 type validTest struct {
 	longtitude, latitude float64
 	expected             bool
@@ -125,3 +150,357 @@ func TestValid(t *testing.T) {
 		}
 	}
 }
+
+type convertTest struct {
+	distance float64
+	from, to Unit
+	expected float64
+}
+
+var convertTests []convertTest = []convertTest{
+	convertTest{100, Kilometre, Kilometre, 100},
+	convertTest{1, Mile, Kilometre, 1.609344},
+	convertTest{1, NauticalMile, Kilometre, 1.852},
+	convertTest{1500, Metre, Kilometre, 1.5},
+	convertTest{100, Kilometre, Mile, 62.13711922},
+	convertTest{1, NauticalMile, Metre, 1852},
+	convertTest{1, Mile, NauticalMile, 0.86897624},
+}
+
+func TestConvert(t *testing.T) {
+	for _, test := range convertTests {
+		if d := Convert(test.distance, test.from, test.to); math.Abs(d-test.expected) > 1e-6 {
+			t.Errorf("Output %v not equal to expected %v for %v %v to %v", d, test.expected, test.distance, test.from, test.to)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	for _, s := range []string{"km", "mi", "nmi", "m"} {
+		if u, err := ParseUnit(s); err != nil || string(u) != s {
+			t.Errorf("Output %v %v for %v", u, err, s)
+		}
+	}
+	if u, err := ParseUnit(""); err != nil || u != Kilometre {
+		t.Errorf("Output %v %v, expected the default unit km", u, err)
+	}
+	if _, err := ParseUnit("miles"); err == nil {
+		t.Errorf("Expected an error for an unknown unit")
+	}
+}
+
+func TestParseRadius(t *testing.T) {
+	for s, expected := range map[string]float64{"": Radius, "mean": Radius, "equatorial": 6378.137, "authalic": 6371.0072} {
+		if r, err := ParseRadius(s); err != nil || r != expected {
+			t.Errorf("Output %v %v for %v, expected %v", r, err, s, expected)
+		}
+	}
+	if _, err := ParseRadius("polar"); err == nil {
+		t.Errorf("Expected an error for an unknown radius")
+	}
+}
+
+type parseCoordinateTest struct {
+	input     string
+	latitude  bool
+	expected  float64
+	errString string
+}
+
+var parseCoordinateTests []parseCoordinateTest = []parseCoordinateTest{
+	//decimal degrees
+	parseCoordinateTest{"53.339428", true, 53.339428, ""},
+	parseCoordinateTest{"-6.257664", false, -6.257664, ""},
+	parseCoordinateTest{"53.339428N", true, 53.339428, ""},
+	parseCoordinateTest{"6.257664 W", false, -6.257664, ""},
+	parseCoordinateTest{"S 33.9", true, -33.9, ""},
+	parseCoordinateTest{"e151.2", false, 151.2, ""},
+	parseCoordinateTest{"53.5 s", true, -53.5, ""},
+	parseCoordinateTest{"53.5s", true, -53.5, ""},
+	parseCoordinateTest{".5", true, 0.5, ""},
+	//degrees minutes seconds
+	parseCoordinateTest{"53°20'22\"N", true, 53.339444444, ""},
+	parseCoordinateTest{"6°15'28\"W", false, -6.257777778, ""},
+	parseCoordinateTest{"53°20′22.5″ N", true, 53.339583333, ""},
+	parseCoordinateTest{"6°15'28''W", false, -6.257777778, ""},
+	parseCoordinateTest{"53 20 22 S", true, -53.339444444, ""},
+	parseCoordinateTest{"53d20m22s", true, 53.339444444, ""},
+	parseCoordinateTest{"53 20 22s", true, 53.339444444, ""},
+	parseCoordinateTest{"53d20m22s s", true, -53.339444444, ""},
+	parseCoordinateTest{"53 20 22 s", true, -53.339444444, ""},
+	parseCoordinateTest{"53°20'22\"s", true, -53.339444444, ""},
+	parseCoordinateTest{"-6°15'28\"", false, -6.257777778, ""},
+	//degrees decimal minutes
+	parseCoordinateTest{"53°20.367'N", true, 53.33945, ""},
+	parseCoordinateTest{"6 15.466 W", false, -6.257766667, ""},
+	parseCoordinateTest{"53°", true, 53, ""},
+	//errors
+	parseCoordinateTest{"u", true, 0, "invalid syntax"},
+	parseCoordinateTest{"", false, 0, "invalid syntax"},
+	parseCoordinateTest{"53.3E", true, 0, "expected the hemisphere N or S"},
+	parseCoordinateTest{"6.2N", false, 0, "expected the hemisphere E or W"},
+	parseCoordinateTest{"-53.3N", true, 0, "a sign cannot be combined with a hemisphere"},
+	parseCoordinateTest{"53°60'N", true, 0, "minutes must be < 60"},
+	parseCoordinateTest{"53°20'60\"N", true, 0, "seconds must be < 60"},
+	parseCoordinateTest{"53.5°20'N", true, 0, "only the last number can have decimals"},
+	parseCoordinateTest{"53'20°N", true, 0, "unexpected '"},
+	parseCoordinateTest{"53 20 22 1", true, 0, "invalid syntax"},
+	parseCoordinateTest{"53°20'x", true, 0, "invalid syntax"},
+	//accepted by strconv.ParseFloat only
+	parseCoordinateTest{"NaN", true, 0, "invalid syntax"},
+	parseCoordinateTest{"nan", false, 0, "expected the hemisphere E or W"},
+	parseCoordinateTest{"Inf", true, 0, "invalid syntax"},
+	parseCoordinateTest{"-Infinity", false, 0, "invalid syntax"},
+	parseCoordinateTest{"1e2", true, 0, "invalid syntax"},
+	parseCoordinateTest{"1E-2", false, 0, "invalid syntax"},
+	parseCoordinateTest{"0x1p-2", true, 0, "invalid syntax"},
+}
+
+func TestParseCoordinate(t *testing.T) {
+	for _, test := range parseCoordinateTests {
+		parse := ParseLongitude
+		if test.latitude {
+			parse = ParseLatitude
+		}
+		d, err := parse(test.input)
+		if (err == nil) != (test.errString == "") || (err != nil && !strings.Contains(err.Error(), test.errString)) {
+			t.Errorf("Output error %v for %q, expected %v", err, test.input, test.errString)
+		}
+		if err == nil && math.Abs(d-test.expected) > 1e-9 {
+			t.Errorf("Output %v for %q not equal to expected %v", d, test.input, test.expected)
+		}
+	}
+}
+
+type geocodeTest struct {
+	latitude, longitude float64
+	length              int
+	code                string
+}
+
+var geohashTests []geocodeTest = []geocodeTest{
+	geocodeTest{57.64911, 10.40744, 11, "u4pruydqqvj"},
+	geocodeTest{53.339428, -6.257664, 5, "gc7x3"},
+	geocodeTest{0, 0, 1, "s"},
+	geocodeTest{-90, -180, 3, "000"},
+}
+
+func TestGeohash(t *testing.T) {
+	for _, test := range geohashTests {
+		p := MakePoint(DegreeToRadian(test.longitude), DegreeToRadian(test.latitude))
+		if code := p.Geohash(test.length); code != test.code {
+			t.Errorf("Output %v for %v, %v not equal to expected %v", code, test.latitude, test.longitude, test.code)
+		}
+		//the center of the cell is within half a cell of the point
+		d, err := DecodeGeohash(test.code)
+		if err != nil || d.Geohash(test.length) != test.code {
+			t.Errorf("Output %v %v for %v, expected a point in the cell", d, err, test.code)
+		}
+	}
+	for _, code := range []string{"", "u4pa", "u4pruydqqvjxx"} {
+		if _, err := DecodeGeohash(code); err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+	}
+}
+
+var plusCodeTests []geocodeTest = []geocodeTest{
+	geocodeTest{20.375, 2.775, 6, "7FG49Q00+"},
+	geocodeTest{20.3700625, 2.7821875, 10, "7FG49QCJ+2V"},
+	geocodeTest{20.3701125, 2.782234375, 11, "7FG49QCJ+2VX"},
+	geocodeTest{1.286785, 103.854503, 11, "6PH57VP3+PR6"},
+	geocodeTest{37.4220625, -122.0840625, 10, "849VCWC8+R9"},
+	geocodeTest{90, 1, 4, "CFX30000+"},
+}
+
+func TestPlusCode(t *testing.T) {
+	for _, test := range plusCodeTests {
+		p := MakePoint(DegreeToRadian(test.longitude), DegreeToRadian(test.latitude))
+		if code := p.PlusCode(test.length); code != test.code {
+			t.Errorf("Output %v for %v, %v not equal to expected %v", code, test.latitude, test.longitude, test.code)
+		}
+		d, err := DecodePlusCode(test.code)
+		if err != nil || d.PlusCode(test.length) != test.code {
+			t.Errorf("Output %v %v for %v, expected a point in the cell", d, err, test.code)
+		}
+	}
+	for _, code := range []string{"", "CWC8+R9", "849VCWC8R9", "849VCWC8+R", "849VCWC8+RA", "849V0000+R9", "849VC000+", "X49VCWC8+R9"} {
+		if _, err := DecodePlusCode(code); err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+	}
+}
+
+// Return a point from degrees
+func degreePoint(latitude float64, longitude float64) Point {
+	return MakePoint(DegreeToRadian(longitude), DegreeToRadian(latitude))
+}
+
+type bearingTest struct {
+	p1, p2  Point
+	bearing float64
+	compass string
+}
+
+var bearingTests []bearingTest = []bearingTest{
+	bearingTest{degreePoint(0, 0), degreePoint(1, 0), 0, "N"},
+	bearingTest{degreePoint(0, 0), degreePoint(0, 1), 90, "E"},
+	bearingTest{degreePoint(0, 0), degreePoint(-1, 0), 180, "S"},
+	bearingTest{degreePoint(0, 0), degreePoint(0, -1), 270, "W"},
+	bearingTest{degreePoint(0, 0), degreePoint(1, 1), 44.995636, "NE"},
+	//office to Christina McArdle
+	bearingTest{degreePoint(53.339428, -6.257664), degreePoint(52.986375, -6.043701), 159.946002, "SSE"},
+}
+
+func TestInitialBearing(t *testing.T) {
+	for _, test := range bearingTests {
+		b := InitialBearing(test.p1, test.p2)
+		if math.Abs(b-test.bearing) > 1e-6 {
+			t.Errorf("Output %v for %v to %v not equal to expected %v", b, test.p1, test.p2, test.bearing)
+		}
+		if c := CompassDirection(b, 16); c != test.compass {
+			t.Errorf("Output %v for %v not equal to expected %v", c, b, test.compass)
+		}
+	}
+}
+
+type compassTest struct {
+	bearing  float64
+	points   int
+	expected string
+}
+
+var compassTests []compassTest = []compassTest{
+	compassTest{0, 4, "N"},
+	compassTest{44.9, 4, "N"},
+	compassTest{45, 4, "E"},
+	compassTest{350, 4, "N"},
+	compassTest{200, 8, "S"},
+	compassTest{203, 8, "SW"},
+	compassTest{11.24, 16, "N"},
+	compassTest{11.25, 16, "NNE"},
+	compassTest{348.75, 16, "N"},
+	compassTest{-90, 16, "W"},
+	compassTest{720, 16, "N"},
+	compassTest{100, 0, "E"},
+}
+
+func TestCompassDirection(t *testing.T) {
+	for _, test := range compassTests {
+		if c := CompassDirection(test.bearing, test.points); c != test.expected {
+			t.Errorf("Output %v for %v with %v points not equal to expected %v", c, test.bearing, test.points, test.expected)
+		}
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	for _, test := range []struct{ p1, p2, expected Point }{
+		{degreePoint(0, 0), degreePoint(0, 90), degreePoint(0, 45)},
+		{degreePoint(0, 170), degreePoint(0, -170), degreePoint(0, 180)},
+		{degreePoint(-10, 0), degreePoint(10, 0), degreePoint(0, 0)},
+	} {
+		m := Midpoint(test.p1, test.p2)
+		if math.Abs(Distance(m, test.expected, Radius)) > 1e-6 {
+			t.Errorf("Output %v not equal to expected %v", m, test.expected)
+		}
+		if d1, d2 := Distance(test.p1, m, Radius), Distance(m, test.p2, Radius); math.Abs(d1-d2) > 1e-6 {
+			t.Errorf("Output distances %v and %v, expected the midpoint halfway", d1, d2)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	office := degreePoint(53.339428, -6.257664)
+	for _, bearing := range []float64{0, 45, 159.946002, 270} {
+		d := Destination(office, bearing, 41.768, Radius)
+		if distance := Distance(office, d, Radius); math.Abs(distance-41.768) > 1e-6 {
+			t.Errorf("Output distance %v for %v, expected 41.768", distance, bearing)
+		}
+		if b := InitialBearing(office, d); math.Abs(math.Remainder(b-bearing, 360)) > 1e-6 {
+			t.Errorf("Output bearing %v not equal to expected %v", b, bearing)
+		}
+	}
+	//crossing the antimeridian
+	if d := Destination(degreePoint(0, 179), 90, 2*Radius*math.Pi/360, Radius); math.Abs(RadianToDegree(d.Longitude)+180) > 1e-9 && math.Abs(RadianToDegree(d.Longitude)-180) > 1e-9 {
+		t.Errorf("Output %v, expected the longitude 180", d)
+	}
+}
+
+type validateTest struct {
+	latitude  float64
+	longitude float64
+	field     string
+	errString string
+}
+
+var validateTests []validateTest = []validateTest{
+	validateTest{53.339428, -6.257664, "", ""},
+	validateTest{90, 180, "", ""},
+	validateTest{-90, -180, "", ""},
+	validateTest{91, 0, "latitude", "Invalid latitude 91, expected a value between -90 and 90"},
+	validateTest{0, -180.5, "longitude", "Invalid longitude -180.5, expected a value between -180 and 180"},
+	validateTest{0, 181.7, "longitude", "Invalid longitude 181.7, expected a value between -180 and 180"},
+	//the latitude is reported first
+	validateTest{-95, 200, "latitude", "Invalid latitude -95, expected a value between -90 and 90"},
+	validateTest{math.NaN(), 0, "latitude", "Invalid latitude NaN, expected a value between -90 and 90"},
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range validateTests {
+		//the point reports the degrees it was made from
+		for _, err := range []error{ValidateDegrees(test.latitude, test.longitude), degreePoint(test.latitude, test.longitude).Validate()} {
+			if test.field == "" {
+				if err != nil {
+					t.Errorf("Output %v for %v, %v, expected no error", err, test.latitude, test.longitude)
+				}
+				continue
+			}
+			var coordinateErr *CoordinateError
+			if !errors.As(err, &coordinateErr) || coordinateErr.Field != test.field {
+				t.Errorf("Output %v for %v, %v, expected an error on the %v", err, test.latitude, test.longitude, test.field)
+				continue
+			}
+			if coordinateErr.Value != test.latitude && coordinateErr.Value != test.longitude && !math.IsNaN(coordinateErr.Value) {
+				t.Errorf("Output value %v for %v, %v", coordinateErr.Value, test.latitude, test.longitude)
+			}
+			if errors.Is(err, ErrLatitude) != (test.field == "latitude") || errors.Is(err, ErrLongitude) != (test.field == "longitude") {
+				t.Errorf("Output %v, expected errors.Is to match the %v", err, test.field)
+			}
+			if err.Error() != test.errString {
+				t.Errorf("Output %v, expected %v", err, test.errString)
+			}
+		}
+	}
+}
+
+type longitudePolicyTest struct {
+	policy    LongitudePolicy
+	longitude float64
+	expected  float64
+}
+
+var longitudePolicyTests []longitudePolicyTest = []longitudePolicyTest{
+	longitudePolicyTest{LongitudeReject, 190, 190},
+	longitudePolicyTest{LongitudeWrap, 190, -170},
+	longitudePolicyTest{LongitudeWrap, -190, 170},
+	longitudePolicyTest{LongitudeWrap, 540, -180},
+	longitudePolicyTest{LongitudeWrap, -725, -5},
+	longitudePolicyTest{LongitudeWrap, 180, 180},
+	longitudePolicyTest{LongitudeWrap, -6.257664, -6.257664},
+}
+
+func TestLongitudePolicy(t *testing.T) {
+	for _, test := range longitudePolicyTests {
+		if result := test.policy.Apply(test.longitude); math.Abs(result-test.expected) > 1e-9 {
+			t.Errorf("Output %v for %v %v, expected %v", result, test.policy, test.longitude, test.expected)
+		}
+	}
+	for s, expected := range map[string]LongitudePolicy{"": LongitudeReject, "reject": LongitudeReject, "wrap": LongitudeWrap} {
+		if policy, err := ParseLongitudePolicy(s); err != nil || policy != expected {
+			t.Errorf("Output %v, %v for %q, expected %v", policy, err, s, expected)
+		}
+	}
+	if _, err := ParseLongitudePolicy("clamp"); err == nil {
+		t.Errorf("Expected an error for clamp")
+	}
+}
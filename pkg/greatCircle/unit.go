@@ -0,0 +1,91 @@
+package greatCircle
+
+import (
+	"errors"
+	"strings"
+)
+
+// Unit of a distance
+type Unit string
+
+const (
+	Kilometre    Unit = "km"
+	Mile         Unit = "mi"
+	NauticalMile Unit = "nmi"
+	Metre        Unit = "m"
+)
+
+var units = []Unit{Kilometre, Mile, NauticalMile, Metre}
+
+// Length of every unit in km
+var unitLengths = map[Unit]float64{
+	Kilometre:    1,
+	Mile:         1.609344,
+	NauticalMile: 1.852,
+	Metre:        0.001,
+}
+
+// Convert a unit name into a Unit, "" gives Kilometre
+func ParseUnit(s string) (Unit, error) {
+	if s == "" {
+		return Kilometre, nil
+	}
+	var names []string
+	for _, u := range units {
+		if string(u) == s {
+			return u, nil
+		}
+		names = append(names, string(u))
+	}
+	return Kilometre, errors.New("Invalid distance unit " + s + ", expected one of " + strings.Join(names, ", "))
+}
+
+// Convert a distance in km into the unit
+func (u Unit) FromKm(km float64) float64 {
+	return km / unitLengths[u]
+}
+
+// Convert a distance in the unit into km
+func (u Unit) ToKm(distance float64) float64 {
+	return distance * unitLengths[u]
+}
+
+// Convert a distance from a unit into another
+func Convert(distance float64, from Unit, to Unit) float64 {
+	return to.FromKm(from.ToKm(distance))
+}
+
+// Radii of the earth in km
+const (
+	// Mean radius (2a+b)/3 of the WGS 84 ellipsoid, the historical Radius
+	MeanRadius = Radius
+	// Semi-major axis of the WGS 84 ellipsoid
+	EquatorialRadius = 6378.137
+	// Radius of the sphere with the surface of the WGS 84 ellipsoid
+	AuthalicRadius = 6371.0072
+)
+
+// Names of the radii accepted by ParseRadius
+var radii = []struct {
+	name   string
+	radius float64
+}{
+	{"mean", MeanRadius},
+	{"equatorial", EquatorialRadius},
+	{"authalic", AuthalicRadius},
+}
+
+// Convert a radius name (mean, equatorial or authalic) into a radius in km, "" gives the mean radius
+func ParseRadius(s string) (float64, error) {
+	if s == "" {
+		return MeanRadius, nil
+	}
+	var names []string
+	for _, r := range radii {
+		if r.name == s {
+			return r.radius, nil
+		}
+		names = append(names, r.name)
+	}
+	return MeanRadius, errors.New("Invalid earth radius " + s + ", expected one of " + strings.Join(names, ", "))
+}
@@ -0,0 +1,106 @@
+package greatCircle
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+var (
+	// Matched with errors.Is by the CoordinateError of a latitude
+	ErrLatitude = errors.New("invalid latitude")
+	// Matched with errors.Is by the CoordinateError of a longitude
+	ErrLongitude = errors.New("invalid longitude")
+)
+
+// A latitude or longitude out of its range
+type CoordinateError struct {
+	// "latitude" or "longitude"
+	Field string
+	// The value, in degree
+	Value float64
+	// The range of the field, in degree
+	Min float64
+	Max float64
+}
+
+func (e *CoordinateError) Error() string {
+	return "Invalid " + e.Field + " " + strconv.FormatFloat(e.Value, 'f', -1, 64) + ", expected a value between " +
+		strconv.FormatFloat(e.Min, 'f', -1, 64) + " and " + strconv.FormatFloat(e.Max, 'f', -1, 64)
+}
+
+// Return ErrLatitude or ErrLongitude, so that errors.Is tells which field is wrong
+func (e *CoordinateError) Unwrap() error {
+	if e.Field == "latitude" {
+		return ErrLatitude
+	}
+	return ErrLongitude
+}
+
+// Check a latitude and a longitude in degree, the latitude first. The error is a *CoordinateError naming the field
+// and its value as given. The check is done before the conversion to radian, which would alter the reported value
+func ValidateDegrees(latitude float64, longitude float64) error {
+	if math.IsNaN(latitude) || !util.LargerOrEqual(latitude, -90) || !util.SmallerOrEqual(latitude, 90) {
+		return &CoordinateError{Field: "latitude", Value: latitude, Min: -90, Max: 90}
+	}
+	if math.IsNaN(longitude) || !util.LargerOrEqual(longitude, -180) || !util.SmallerOrEqual(longitude, 180) {
+		return &CoordinateError{Field: "longitude", Value: longitude, Min: -180, Max: 180}
+	}
+	return nil
+}
+
+// Check the point as Valid does, the error is a *CoordinateError naming the field and its value in degree
+func (p Point) Validate() error {
+	if !validLatitude(p.Latitude) {
+		return &CoordinateError{Field: "latitude", Value: originalDegree(p.Latitude), Min: -90, Max: 90}
+	}
+	if !validLongtitude(p.Longitude) {
+		return &CoordinateError{Field: "longitude", Value: originalDegree(p.Longitude), Min: -180, Max: 180}
+	}
+	return nil
+}
+
+// Convert back to degree a value converted from degree, rounded to 9 decimals (a tenth of a millimetre) to undo the
+// error of the round trip, so that 181.7 is reported as 181.7 and not 181.69999999999996
+func originalDegree(radian float64) float64 {
+	degree := RadianToDegree(radian)
+	if math.IsNaN(degree) || math.IsInf(degree, 0) {
+		return degree
+	}
+	return math.Round(degree*1e9) / 1e9
+}
+
+// What is done with a longitude outside -180..180
+type LongitudePolicy string
+
+const (
+	// The longitude is invalid
+	LongitudeReject LongitudePolicy = "reject"
+	// The longitude is wrapped around the antimeridian, 190 becomes -170
+	LongitudeWrap LongitudePolicy = "wrap"
+)
+
+// Convert a policy name into a LongitudePolicy, "" gives LongitudeReject
+func ParseLongitudePolicy(s string) (LongitudePolicy, error) {
+	switch policy := LongitudePolicy(s); policy {
+	case "":
+		return LongitudeReject, nil
+	case LongitudeReject, LongitudeWrap:
+		return policy, nil
+	}
+	return "", errors.New("Invalid longitude policy " + s + ", expected reject or wrap")
+}
+
+// Apply the policy to a longitude in degree. The longitudes within -180..180 are kept as they are
+func (policy LongitudePolicy) Apply(longitude float64) float64 {
+	if policy != LongitudeWrap || math.IsInf(longitude, 0) || (longitude >= -180 && longitude <= 180) {
+		return longitude
+	}
+	wrapped := math.Mod(longitude+180, 360)
+	if wrapped < 0 {
+		wrapped += 360
+	}
+	return wrapped - 180
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Version and commit of the binary, set at build time with
+// go build -ldflags "-X git.codesubmit.io/sfox/party-invite-ruiegv/pkg/health.Version=1.2.3 -X git.codesubmit.io/sfox/party-invite-ruiegv/pkg/health.Commit=abc123"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Information about the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// Return the build information, falling back to the version control information embedded by the go tool
+// when the commit was not set at build time
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// Return a handler reporting the build information
+func BuildInfoHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := util.WriteJSON(w, http.StatusOK, GetBuildInfo()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package health provides liveness, readiness and build information endpoints
+package health
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// A named readiness check, which returns nil when the dependency is ready
+type Check struct {
+	Name string
+	Func func() error
+}
+
+// The outcome of one check
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// The outcome of all checks, Status is ok only if every check passed
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// A set of readiness checks
+type Checker struct {
+	mu     sync.Mutex
+	checks []Check
+}
+
+// Create a checker without any checks
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// The checker used by the service
+var Default = NewChecker()
+
+// Add a check, checks are run in the order they are registered
+func (c *Checker) Register(name string, f func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, Check{name, f})
+}
+
+// Run every check and report the results
+func (c *Checker) Run() Report {
+	c.mu.Lock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: []Result{}}
+	for _, check := range checks {
+		start := time.Now()
+		err := check.Func()
+		result := Result{Name: check.Name, Status: StatusOK, Duration: time.Since(start).String()}
+		if err != nil {
+			result.Status = StatusFail
+			result.Error = err.Error()
+			report.Status = StatusFail
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// Return a handler running the checks, answering 200 when all pass and 503 otherwise
+func (c *Checker) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run()
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		if err := util.WriteJSON(w, status, report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Return a handler answering 200 as long as the process is able to serve requests
+func LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := util.WriteJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: []Result{}}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
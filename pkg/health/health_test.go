@@ -0,0 +1,75 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type checkerTest struct {
+	checks   []Check
+	status   int
+	expected Report
+}
+
+var checkerTests []checkerTest = []checkerTest{
+	checkerTest{nil, http.StatusOK, Report{StatusOK, []Result{}}},
+	checkerTest{[]Check{Check{"a", func() error { return nil }}}, http.StatusOK,
+		Report{StatusOK, []Result{Result{Name: "a", Status: StatusOK}}}},
+	checkerTest{[]Check{Check{"a", func() error { return nil }}, Check{"b", func() error { return errors.New("down") }}}, http.StatusServiceUnavailable,
+		Report{StatusFail, []Result{Result{Name: "a", Status: StatusOK}, Result{Name: "b", Status: StatusFail, Error: "down"}}}},
+}
+
+func TestChecker(t *testing.T) {
+	for _, test := range checkerTests {
+		c := NewChecker()
+		for _, check := range test.checks {
+			c.Register(check.Name, check.Func)
+		}
+		writer := httptest.NewRecorder()
+		c.Handler()(writer, httptest.NewRequest("GET", "/readyz", nil))
+
+		if writer.Code != test.status {
+			t.Errorf("Output status %v not equal to expected %v", writer.Code, test.status)
+		}
+		var report Report
+		if err := json.Unmarshal(writer.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != test.expected.Status || len(report.Checks) != len(test.expected.Checks) {
+			t.Fatalf("Output report %v not equal to expected %v", report, test.expected)
+		}
+		for i, result := range report.Checks {
+			result.Duration = ""
+			if result != test.expected.Checks[i] {
+				t.Errorf("Output result %v not equal to expected %v", result, test.expected.Checks[i])
+			}
+		}
+	}
+}
+
+func TestLiveHandler(t *testing.T) {
+	writer := httptest.NewRecorder()
+	LiveHandler()(writer, httptest.NewRequest("GET", "/healthz", nil))
+	if writer.Code != http.StatusOK {
+		t.Errorf("Output status %v not equal to expected %v", writer.Code, http.StatusOK)
+	}
+}
+
+func TestBuildInfoHandler(t *testing.T) {
+	Version = "1.2.3"
+	Commit = "abc"
+	defer func() { Version, Commit = "dev", "" }()
+
+	writer := httptest.NewRecorder()
+	BuildInfoHandler()(writer, httptest.NewRequest("GET", "/buildinfo", nil))
+	var info BuildInfo
+	if err := json.Unmarshal(writer.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.2.3" || info.Commit != "abc" || info.GoVersion == "" {
+		t.Errorf("Output build info %v not equal to expected version 1.2.3 and commit abc", info)
+	}
+}
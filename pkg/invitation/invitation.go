@@ -0,0 +1,197 @@
+// Package invitation renders personalised invitations from templates and packs them as .eml or .html files in a zip,
+// as an mbox or sends them over SMTP
+package invitation
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// The office the customers are invited to
+type Office struct {
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Data given to the templates for one invited customer
+type Data struct {
+	User_id int
+	Name    string
+	// Great circle distance from the office in Unit
+	Distance float64
+	// Unit of Distance, km when empty
+	Unit   string
+	Office Office
+	// Links to answer the invitation, empty when the invitations are not tracked
+	RSVP RSVP
+}
+
+// Links given to a customer to accept or decline an invitation
+type RSVP struct {
+	Token   string
+	Accept  string
+	Decline string
+}
+
+// Sources of the templates. To renders the recipient address, Subject the subject, Text the plain text body
+// (text/template) and HTML the HTML body (html/template, so the customer data is escaped)
+type Templates struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Templates used when none is configured
+var DefaultTemplates = Templates{
+	To:      `customer-{{.User_id}}@example.invalid`,
+	Subject: `You are invited to our party, {{.Name}}!`,
+	Text: `Dear {{.Name}},
+
+You are invited to our customer party at {{.Office.Name}}, only {{printf "%.1f" .Distance}} {{.Unit}} away from you.
+{{if .RSVP.Token}}
+Please let us know if you can come:
+  accept: {{.RSVP.Accept}}
+  decline: {{.RSVP.Decline}}
+{{end}}
+We look forward to seeing you there!
+`,
+	HTML: `<!DOCTYPE html>
+<html>
+<body>
+<p>Dear {{.Name}},</p>
+<p>You are invited to our customer party at {{.Office.Name}}, only {{printf "%.1f" .Distance}} {{.Unit}} away from you.</p>
+{{if .RSVP.Token}}<p>Please let us know if you can come: <a href="{{.RSVP.Accept}}">accept</a> or <a href="{{.RSVP.Decline}}">decline</a>.</p>
+{{end}}<p>We look forward to seeing you there!</p>
+</body>
+</html>
+`,
+}
+
+// Template files read by LoadTemplates
+const (
+	ToFile      = "to.txt"
+	SubjectFile = "subject.txt"
+	TextFile    = "body.txt"
+	HTMLFile    = "body.html"
+)
+
+// Read the templates from dir, the missing files keep their default template
+func LoadTemplates(dir string) (Templates, error) {
+	t := DefaultTemplates
+	for name, field := range map[string]*string{ToFile: &t.To, SubjectFile: &t.Subject, TextFile: &t.Text, HTMLFile: &t.HTML} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Templates{}, err
+		}
+		*field = string(b)
+	}
+	return t, nil
+}
+
+// Settings of a Renderer
+type Config struct {
+	// Sender address
+	From string
+	// Name of the office in the invitations
+	OfficeName string
+	Templates  Templates
+}
+
+// Configuration used when none is given
+var DefaultConfig = Config{From: "Party Invitations <invitations@example.com>", OfficeName: "our office", Templates: DefaultTemplates}
+
+// A rendered invitation
+type Message struct {
+	User_id int
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Renders the invitations with parsed templates
+type Renderer struct {
+	from       string
+	officeName string
+	to         *texttemplate.Template
+	subject    *texttemplate.Template
+	text       *texttemplate.Template
+	html       *htmltemplate.Template
+}
+
+// Parse the templates of config
+func NewRenderer(config Config) (*Renderer, error) {
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, errors.New("Invalid sender address " + config.From + " : " + err.Error())
+	}
+	r := &Renderer{from: config.From, officeName: config.OfficeName}
+	var err error
+	for _, t := range []struct {
+		name   string
+		source string
+		dest   **texttemplate.Template
+	}{
+		{ToFile, config.Templates.To, &r.to},
+		{SubjectFile, config.Templates.Subject, &r.subject},
+		{TextFile, config.Templates.Text, &r.text},
+	} {
+		if *t.dest, err = texttemplate.New(t.name).Option("missingkey=error").Parse(t.source); err != nil {
+			return nil, errors.New("Invalid template " + t.name + " : " + err.Error())
+		}
+	}
+	if r.html, err = htmltemplate.New(HTMLFile).Option("missingkey=error").Parse(config.Templates.HTML); err != nil {
+		return nil, errors.New("Invalid template " + HTMLFile + " : " + err.Error())
+	}
+	return r, nil
+}
+
+// Render the invitation of one customer. The office name is filled in if d.Office has none
+func (r *Renderer) Render(d Data) (Message, error) {
+	if d.Office.Name == "" {
+		d.Office.Name = r.officeName
+	}
+	if d.Unit == "" {
+		d.Unit = "km"
+	}
+	m := Message{User_id: d.User_id, From: r.from}
+	var err error
+	if m.To, err = execute(r.to, d); err != nil {
+		return Message{}, err
+	}
+	if _, err := mail.ParseAddress(m.To); err != nil {
+		return Message{}, errors.New("Invalid recipient address " + m.To + " : " + err.Error())
+	}
+	if m.Subject, err = execute(r.subject, d); err != nil {
+		return Message{}, err
+	}
+	var text, html bytes.Buffer
+	if err := r.text.Execute(&text, d); err != nil {
+		return Message{}, err
+	}
+	if err := r.html.Execute(&html, d); err != nil {
+		return Message{}, err
+	}
+	m.Text, m.HTML = text.String(), html.String()
+	return m, nil
+}
+
+// Execute a header template, newlines are removed so the result is safe in a mail header
+func execute(t *texttemplate.Template, d Data) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
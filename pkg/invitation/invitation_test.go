@@ -0,0 +1,233 @@
+package invitation
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDate = time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+var testData = Data{User_id: 12, Name: "Christina <McArdle>", Distance: 41.768, Office: Office{Latitude: 53.339428, Longitude: -6.257664}}
+
+func newTestRenderer(t *testing.T) *Renderer {
+	r, err := NewRenderer(DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRender(t *testing.T) {
+	m, err := newTestRenderer(t).Render(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Message{
+		User_id: 12,
+		From:    DefaultConfig.From,
+		To:      "customer-12@example.invalid",
+		Subject: "You are invited to our party, Christina <McArdle>!",
+		Text:    "Dear Christina <McArdle>,\n\nYou are invited to our customer party at our office, only 41.8 km away from you.\n\nWe look forward to seeing you there!\n",
+	}
+	m.HTML, expected.HTML = "", ""
+	if m != expected {
+		t.Errorf("Output %v not equal to expected %v", m, expected)
+	}
+	m, _ = newTestRenderer(t).Render(testData)
+	if !strings.Contains(m.HTML, "<p>Dear Christina &lt;McArdle&gt;,</p>") {
+		t.Errorf("Output HTML %v, expected the name to be escaped", m.HTML)
+	}
+}
+
+func TestRenderRSVP(t *testing.T) {
+	d := testData
+	d.RSVP = RSVP{Token: "abc", Accept: "http://x/rsvp/abc?response=accept", Decline: "http://x/rsvp/abc?response=decline"}
+	m, err := newTestRenderer(t).Render(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.Text, "  accept: http://x/rsvp/abc?response=accept\n") || !strings.Contains(m.Text, "  decline: http://x/rsvp/abc?response=decline\n") {
+		t.Errorf("Output text %v, expected the RSVP links", m.Text)
+	}
+	if !strings.Contains(m.HTML, `<a href="http://x/rsvp/abc?response=accept">accept</a>`) {
+		t.Errorf("Output HTML %v, expected the RSVP links", m.HTML)
+	}
+}
+
+type templateTest struct {
+	templates Templates
+	valid     bool
+}
+
+var templateTests []templateTest = []templateTest{
+	templateTest{Templates{To: "{{.Name", Subject: "", Text: "", HTML: ""}, false},
+	templateTest{Templates{To: "a@b.c", Subject: "{{.Missing}}", Text: "", HTML: ""}, false},
+	templateTest{Templates{To: "not an address", Subject: "", Text: "", HTML: ""}, false},
+	//a header template cannot inject headers
+	templateTest{Templates{To: "a@b.c", Subject: "Hi\nBcc: x@y.z", Text: "", HTML: ""}, true},
+}
+
+func TestRenderTemplates(t *testing.T) {
+	for _, test := range templateTests {
+		r, err := NewRenderer(Config{From: "a@b.c", Templates: test.templates})
+		if err == nil {
+			var m Message
+			m, err = r.Render(testData)
+			if strings.Contains(m.Subject, "\n") {
+				t.Errorf("Output subject %q, expected a single line", m.Subject)
+			}
+		}
+		if (err == nil) != test.valid {
+			t.Errorf("Output error %v for %v, expected valid %v", err, test.templates, test.valid)
+		}
+	}
+	if _, err := NewRenderer(Config{From: "nobody", Templates: DefaultTemplates}); err == nil {
+		t.Errorf("Expected an error for an invalid sender address")
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, SubjectFile), []byte("Party at {{.Office.Name}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if templates.Subject != "Party at {{.Office.Name}}" || templates.Text != DefaultTemplates.Text {
+		t.Errorf("Output %v, expected the subject from the file and the other default templates", templates)
+	}
+}
+
+// Parse an .eml file and return its headers and the plain text part
+func parseEML(t *testing.T, b []byte) (mail.Header, string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(part)
+	return msg.Header, string(text)
+}
+
+func TestEML(t *testing.T) {
+	m, _ := newTestRenderer(t).Render(testData)
+	header, text := parseEML(t, m.EML(testDate))
+	subject, _ := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if subject != m.Subject || header.Get("To") != "<customer-12@example.invalid>" || header.Get("Date") != "Tue, 02 Jan 2024 15:04:05 +0000" {
+		t.Errorf("Output headers %v not equal to the message %v", header, m)
+	}
+	if text != strings.ReplaceAll(m.Text, "\n", "\r\n") {
+		t.Errorf("Output text %q not equal to expected %q", text, m.Text)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	r := newTestRenderer(t)
+	var messages []Message
+	for i := 1; i <= 2; i++ {
+		m, _ := r.Render(Data{User_id: i, Name: "user"})
+		messages = append(messages, m)
+	}
+	messages[1].Text = "From here\n"
+
+	for _, format := range []string{FormatEML, FormatHTML} {
+		var b bytes.Buffer
+		if err := WriteArchive(&b, format, messages, testDate); err != nil {
+			t.Fatal(err)
+		}
+		z, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(z.File) != 2 || z.File[0].Name != "invite-1."+format || z.File[1].Name != "invite-2."+format {
+			t.Errorf("Output %v files, expected invite-1.%v and invite-2.%v", len(z.File), format, format)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := WriteArchive(&b, FormatMbox, messages, testDate); err != nil {
+		t.Fatal(err)
+	}
+	mbox := b.String()
+	if strings.Count(mbox, "From invitations@example.com Tue Jan  2 15:04:05 2024\n") != 2 {
+		t.Errorf("Output mbox %v, expected 2 messages", mbox)
+	}
+	if !strings.Contains(mbox, "\n>From here\n") {
+		t.Errorf("Output mbox %v, expected the body line starting with From to be escaped", mbox)
+	}
+	if err := WriteArchive(&b, "pdf", messages, testDate); err == nil {
+		t.Errorf("Expected an error for an invalid format")
+	}
+}
+
+func TestSendToSink(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := ListenSink("127.0.0.1:0", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	r := newTestRenderer(t)
+	var messages []Message
+	for i := 1; i <= 2; i++ {
+		m, _ := r.Render(Data{User_id: i, Name: "user"})
+		messages = append(messages, m)
+	}
+	//a line starting with a dot is escaped by the client and restored by the sink
+	messages[1].Text = ".hidden\n"
+	if sent, err := Send(sink.Addr(), messages, testDate); err != nil || sent != 2 {
+		t.Fatalf("Output %v sent (%v), expected 2", sent, err)
+	}
+
+	received := sink.Messages()
+	if len(received) != 2 || received[0].From != "invitations@example.com" || len(received[0].To) != 1 || received[0].To[0] != "customer-1@example.invalid" {
+		t.Fatalf("Output messages %v, expected the 2 invitations", received)
+	}
+	if _, text := parseEML(t, received[1].Data); text != ".hidden\r\n" {
+		t.Errorf("Output text %q not equal to expected %q", text, ".hidden\r\n")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.eml")); len(files) != 2 {
+		t.Errorf("Output %v files, expected 2 in the sink directory", len(files))
+	}
+	if sent, err := Send("127.0.0.1:1", messages, testDate); err == nil || sent != 0 {
+		t.Errorf("Output %v sent (%v), expected a connection error", sent, err)
+	}
+}
+
+func TestSinkRetention(t *testing.T) {
+	dir := t.TempDir()
+	s := &Sink{dir: dir}
+	for i := 1; i <= maxSinkMessages+5; i++ {
+		if err := s.store(SinkMessage{From: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	received := s.Messages()
+	if len(received) != maxSinkMessages || received[0].From != "6" || received[maxSinkMessages-1].From != strconv.Itoa(maxSinkMessages+5) {
+		t.Errorf("Output %v messages from %v, expected the last %v", len(received), received[0].From, maxSinkMessages)
+	}
+	//the directory keeps every message
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.eml")); len(files) != maxSinkMessages+5 {
+		t.Errorf("Output %v files, expected %v in the sink directory", len(files), maxSinkMessages+5)
+	}
+}
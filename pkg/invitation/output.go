@@ -0,0 +1,121 @@
+package invitation
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats of WriteArchive
+const (
+	FormatEML  = "eml"
+	FormatHTML = "html"
+	FormatMbox = "mbox"
+)
+
+// Return the invitation as an RFC 5322 message with a plain text and an HTML alternative, lines ending with "\r\n"
+func (m Message) EML(date time.Time) []byte {
+	boundary := randomHex(12)
+	var b bytes.Buffer
+	header := func(name string, value string) {
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", encodeAddress(m.From))
+	header("To", encodeAddress(m.To))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<invite-"+strconv.Itoa(m.User_id)+"-"+randomHex(8)+"@party-invite>")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary=\""+boundary+"\"")
+	b.WriteString("\r\n")
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		b.WriteString("--" + boundary + "\r\n")
+		header("Content-Type", part.contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		qp := quotedprintable.NewWriter(&b)
+		qp.Write([]byte(strings.ReplaceAll(part.body, "\n", "\r\n")))
+		qp.Close()
+		b.WriteString("\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return b.Bytes()
+}
+
+// Encode the display name of an address, as it may hold non ASCII characters
+func encodeAddress(address string) string {
+	a, err := mail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return a.String()
+}
+
+// Write the invitations to w: a zip of one .eml or .html file per invitation, or an mbox
+func WriteArchive(w io.Writer, format string, messages []Message, date time.Time) error {
+	switch format {
+	case FormatEML, FormatHTML:
+		z := zip.NewWriter(w)
+		for _, m := range messages {
+			f, err := z.CreateHeader(&zip.FileHeader{Name: "invite-" + strconv.Itoa(m.User_id) + "." + format, Method: zip.Deflate, Modified: date})
+			if err != nil {
+				return err
+			}
+			content := []byte(m.HTML)
+			if format == FormatEML {
+				content = m.EML(date)
+			}
+			if _, err := f.Write(content); err != nil {
+				return err
+			}
+		}
+		return z.Close()
+	case FormatMbox:
+		return writeMbox(w, messages, date)
+	}
+	return errors.New("Invalid invitation format " + format + ", expected eml, html or mbox")
+}
+
+// Write the invitations as an mboxrd file: every message starts with a "From " line and the body lines starting with
+// ">*From " get one more ">"
+func writeMbox(w io.Writer, messages []Message, date time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range messages {
+		sender := m.From
+		if a, err := mail.ParseAddress(m.From); err == nil {
+			sender = a.Address
+		}
+		bw.WriteString("From " + sender + " " + date.UTC().Format(time.ANSIC) + "\n")
+		for _, line := range strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(m.EML(date)), "\r\n", "\n"), "\n"), "\n") {
+			if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+				line = ">" + line
+			}
+			bw.WriteString(line + "\n")
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// Return n random bytes in hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
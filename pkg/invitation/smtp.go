@@ -0,0 +1,207 @@
+package invitation
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum size of a message accepted by the Sink
+const maxSinkMessageSize = 10 << 20
+
+// Number of the latest messages kept in memory by the Sink, the older ones are only in its directory
+const maxSinkMessages = 100
+
+// Send the invitations one by one to the SMTP server at addr, without authentication. Returns the number of
+// invitations sent before the first failure
+func Send(addr string, messages []Message, date time.Time) (int, error) {
+	for i, m := range messages {
+		from, err := mail.ParseAddress(m.From)
+		if err != nil {
+			return i, err
+		}
+		to, err := mail.ParseAddress(m.To)
+		if err != nil {
+			return i, err
+		}
+		if err := smtp.SendMail(addr, nil, from.Address, []string{to.Address}, m.EML(date)); err != nil {
+			return i, errors.New("Cannot send the invitation of customer " + strconv.Itoa(m.User_id) + " : " + err.Error())
+		}
+	}
+	return len(messages), nil
+}
+
+// A message received by the Sink
+type SinkMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// A minimal SMTP server accepting every message, to test the delivery of the invitations without sending mail.
+// The latest messages are kept in memory and, if a directory is given, every message is written to it as an .eml file
+type Sink struct {
+	listener net.Listener
+	dir      string
+	mu       sync.Mutex
+	messages []SinkMessage
+	// Number of messages received
+	received int
+}
+
+// Start a sink listening on addr (e.g. "127.0.0.1:2525", or "127.0.0.1:0" for any free port)
+func ListenSink(addr string, dir string) (*Sink, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{listener: l, dir: dir}
+	go s.serve()
+	return s, nil
+}
+
+// Return the address the sink listens on
+func (s *Sink) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Return the latest messages received, at most maxSinkMessages, oldest first
+func (s *Sink) Messages() []SinkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SinkMessage{}, s.messages...)
+}
+
+// Stop listening
+func (s *Sink) Close() error {
+	return s.listener.Close()
+}
+
+// Accept connections until the listener is closed
+func (s *Sink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// Run the SMTP dialogue of one connection
+func (s *Sink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+	if !reply("220 party-invite SMTP sink") {
+		return
+	}
+	var m SinkMessage
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(verb, "EHLO"), strings.HasPrefix(verb, "HELO"):
+			reply("250 party-invite")
+		case strings.HasPrefix(verb, "MAIL FROM:"):
+			m = SinkMessage{From: trimPath(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(verb, "RCPT TO:"):
+			m.To = append(m.To, trimPath(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case verb == "DATA":
+			if m.From == "" || len(m.To) == 0 {
+				reply("503 Need MAIL and RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				reply("552 " + err.Error())
+				return
+			}
+			m.Data = data
+			if err := s.store(m); err != nil {
+				reply("451 " + err.Error())
+				continue
+			}
+			reply("250 OK")
+			m = SinkMessage{}
+		case verb == "RSET":
+			m = SinkMessage{}
+			reply("250 OK")
+		case verb == "NOOP":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// Read the content of a DATA command up to the "." line, undoing the dot stuffing
+func readData(r *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return data, nil
+		}
+		data = append(data, strings.TrimPrefix(line, ".")...)
+		if len(data) > maxSinkMessageSize {
+			return nil, errors.New("Message too large")
+		}
+	}
+}
+
+// Keep a received message, dropping the oldest one past maxSinkMessages, and write it to the sink directory
+func (s *Sink) store(m SinkMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received++
+	s.messages = append(s.messages, m)
+	if len(s.messages) > maxSinkMessages {
+		s.messages = s.messages[len(s.messages)-maxSinkMessages:]
+	}
+	if s.dir == "" {
+		return nil
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + strconv.Itoa(s.received) + ".eml"
+	return os.WriteFile(filepath.Join(s.dir, name), m.Data, 0644)
+}
+
+// Return the address of a MAIL FROM or RCPT TO path such as " <a@b.c> SIZE=10"
+func trimPath(path string) string {
+	path = strings.TrimSpace(path)
+	if i := strings.Index(path, ">"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.TrimPrefix(path, "<")
+}
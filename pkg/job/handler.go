@@ -0,0 +1,70 @@
+package job
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Read the input of a job from a submit request
+type InputReader func(r *http.Request) ([]byte, error)
+
+// Return a handler for POST requests creating a job from the input read by read. It answers 202 with the job and its
+// location
+func (m *Manager) SubmitHandler(read InputReader) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if http.MethodPost != r.Method {
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a POST request")
+		}
+		fileBytes, err := read(r)
+		if err != nil {
+			return err
+		}
+		j, err := m.Submit(fileBytes)
+		if errors.Is(err, ErrQueueFull) {
+			return &util.HTTPError{Status: http.StatusServiceUnavailable, Err: err}
+		}
+		if err != nil {
+			return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+		}
+		w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+j.ID)
+		return util.WriteJSON(w, http.StatusAccepted, j)
+	}
+}
+
+// Return a handler for GET requests on prefix/{id} (state of the job) and prefix/{id}/result (download of the result)
+func (m *Manager) StatusHandler(prefix string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if http.MethodGet != r.Method {
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET request")
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if len(parts) > 2 || !ValidID(parts[0]) || (len(parts) == 2 && parts[1] != "result") {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: ErrNotFound}
+		}
+		id := parts[0]
+
+		if len(parts) == 1 {
+			j, err := m.Get(id)
+			if err != nil {
+				return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+			}
+			return util.WriteJSON(w, http.StatusOK, j)
+		}
+
+		result, err := m.Result(id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+		case errors.Is(err, ErrNotDone):
+			return &util.HTTPError{Status: http.StatusConflict, Err: err}
+		case err != nil:
+			return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename=\"invite-"+id+".json\"")
+		util.WriteRawJSON(w, http.StatusOK, result)
+		return nil
+	}
+}
@@ -0,0 +1,295 @@
+// Package job provides a persisted queue of asynchronous jobs processed by a pool of workers
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// State of a job
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Maximum number of jobs waiting for a worker
+const QueueSize = 1024
+
+var (
+	ErrNotFound  = errors.New("Job not found")
+	ErrNotDone   = errors.New("Job has not finished")
+	ErrQueueFull = errors.New("Job queue is full, please retry later")
+)
+
+// Job IDs are hex strings, which also keeps them safe to use as file names
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Public state of a job, persisted as <id>.json in the job directory
+type Job struct {
+	ID             string    `json:"id"`
+	Status         Status    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	LinesTotal     int       `json:"lines_total"`
+	LinesProcessed int       `json:"lines_processed"`
+	Errors         []string  `json:"errors"`
+}
+
+// Process the input of a job and return the result to store. progress may be called at any time to report
+// the number of lines processed so far
+type Processor func(ctx context.Context, input []byte, progress func(processed int, total int)) ([]byte, error)
+
+// Stores jobs in a directory and runs them on a pool of workers. Only the most recently finished jobs are kept
+type Manager struct {
+	dir       string
+	workers   int
+	retention int
+	process   Processor
+	queue     chan string
+	mu        sync.Mutex
+	jobs      map[string]*Job
+}
+
+// Create a manager storing its jobs in dir and keeping the retention most recently finished ones. Jobs persisted by
+// a previous run are loaded, and the ones which had not finished are queued again
+func NewManager(dir string, workers int, retention int, process Processor) (*Manager, error) {
+	if workers < 1 {
+		return nil, errors.New("Number of job workers must be > 0")
+	}
+	if retention < 1 {
+		return nil, errors.New("Number of kept jobs must be > 0")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	m := &Manager{dir: dir, workers: workers, retention: retention, process: process, queue: make(chan string, QueueSize), jobs: make(map[string]*Job)}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Load the persisted jobs and queue the unfinished ones, oldest first
+func (m *Manager) load() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	var pending []*Job
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var j Job
+		if err := json.Unmarshal(b, &j); err != nil {
+			return errors.New("Cannot load job " + path + " : " + err.Error())
+		}
+		if !idPattern.MatchString(j.ID) {
+			continue
+		}
+		m.jobs[j.ID] = &j
+		if j.Status == StatusQueued || j.Status == StatusRunning {
+			pending = append(pending, &j)
+		}
+	}
+	m.evict()
+	sort.Slice(pending, func(a, b int) bool { return pending[a].CreatedAt.Before(pending[b].CreatedAt) })
+	for _, j := range pending {
+		j.Status = StatusQueued
+		j.LinesProcessed = 0
+		if err := m.save(j); err != nil {
+			return err
+		}
+		select {
+		case m.queue <- j.ID:
+		default:
+			return ErrQueueFull
+		}
+	}
+	if len(pending) > 0 {
+		logger.Info(context.Background(), "Resumed unfinished jobs", "count", len(pending))
+	}
+	return nil
+}
+
+// Start the workers, they stop when ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	for i := 0; i < m.workers; i++ {
+		go m.work(ctx)
+	}
+}
+
+// Take jobs from the queue until ctx is cancelled
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(ctx, id)
+		}
+	}
+}
+
+// Run one job and persist its outcome. The job ID is the request ID of ctx while it runs
+func (m *Manager) run(ctx context.Context, id string) {
+	m.update(id, func(j *Job) { j.Status = StatusRunning })
+	ctx = logger.WithRequestID(ctx, id)
+
+	input, err := os.ReadFile(m.path(id, ".input"))
+	var result []byte
+	if err == nil {
+		result, err = m.process(ctx, input, func(processed int, total int) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			j := m.jobs[id]
+			j.LinesProcessed, j.LinesTotal, j.UpdatedAt = processed, total, time.Now().UTC()
+		})
+	}
+	if err == nil {
+		err = util.WriteFileAtomic(m.path(id, ".result"), result)
+	}
+	if ctx.Err() != nil {
+		//shutting down, the job stays running on disk and is resumed on the next start
+		return
+	}
+
+	m.update(id, func(j *Job) {
+		if err != nil {
+			j.Status = StatusFailed
+			j.Errors = append(j.Errors, err.Error())
+			return
+		}
+		j.Status = StatusDone
+		j.LinesProcessed = j.LinesTotal
+	})
+	os.Remove(m.path(id, ".input"))
+	logger.Info(ctx, "Job finished", "job_id", id, "error", err)
+}
+
+// Queue a new job for input
+func (m *Manager) Submit(input []byte) (Job, error) {
+	id, err := util.NewID()
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	j := &Job{ID: id, Status: StatusQueued, CreatedAt: now, UpdatedAt: now, Errors: []string{}}
+	if err := util.WriteFileAtomic(m.path(id, ".input"), input); err != nil {
+		return Job{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.queue) == cap(m.queue) {
+		os.Remove(m.path(id, ".input"))
+		return Job{}, ErrQueueFull
+	}
+	if err := m.save(j); err != nil {
+		return Job{}, err
+	}
+	m.jobs[id] = j
+	m.queue <- id
+	return *j, nil
+}
+
+// Return the current state of a job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, found := m.jobs[id]
+	if !found {
+		return Job{}, ErrNotFound
+	}
+	c := *j
+	c.Errors = append([]string{}, j.Errors...)
+	return c, nil
+}
+
+// Return the result of a finished job
+func (m *Manager) Result(id string) ([]byte, error) {
+	j, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status != StatusDone {
+		return nil, ErrNotDone
+	}
+	return os.ReadFile(m.path(id, ".result"))
+}
+
+// Readiness check reporting whether the job directory can be written
+func (m *Manager) CheckStore() error {
+	return util.CheckWritable(m.dir)
+}
+
+// Change a job and persist it
+func (m *Manager) update(id string, f func(j *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j := m.jobs[id]
+	f(j)
+	j.UpdatedAt = time.Now().UTC()
+	if err := m.save(j); err != nil {
+		logger.Error(context.Background(), "Cannot persist job", "job_id", id, "error", err)
+	}
+	if j.Status == StatusDone || j.Status == StatusFailed {
+		m.evict()
+	}
+}
+
+// Remove the finished jobs beyond the retention, least recently finished first. Called with m.mu held
+func (m *Manager) evict() {
+	var finished []*Job
+	for _, j := range m.jobs {
+		if j.Status == StatusDone || j.Status == StatusFailed {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= m.retention {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].UpdatedAt.Before(finished[b].UpdatedAt) })
+	for _, j := range finished[:len(finished)-m.retention] {
+		delete(m.jobs, j.ID)
+		for _, ext := range []string{".json", ".result", ".input"} {
+			if err := os.Remove(m.path(j.ID, ext)); err != nil && !os.IsNotExist(err) {
+				logger.Error(context.Background(), "Cannot remove job", "job_id", j.ID, "error", err)
+			}
+		}
+	}
+}
+
+// Persist the state of a job
+func (m *Manager) save(j *Job) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(m.path(j.ID, ".json"), b)
+}
+
+// Return the path of a file belonging to a job
+func (m *Manager) path(id string, ext string) string {
+	return filepath.Join(m.dir, id+ext)
+}
+
+// Check that id has the shape of a job ID
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
@@ -0,0 +1,225 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// A processor returning the input in upper case, or failing on "fail"
+func upperProcessor(ctx context.Context, input []byte, progress func(processed int, total int)) ([]byte, error) {
+	if string(input) == "fail" {
+		return nil, errors.New("Cannot process input")
+	}
+	progress(1, 1)
+	return bytes.ToUpper(input), nil
+}
+
+// Wait until the job is no longer queued or running
+func waitForJob(t *testing.T, m *Manager, id string) Job {
+	for i := 0; i < 200; i++ {
+		j, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status == StatusDone || j.Status == StatusFailed {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %v did not finish", id)
+	return Job{}
+}
+
+type managerTest struct {
+	input     string
+	status    Status
+	result    string
+	errString string
+}
+
+var managerTests []managerTest = []managerTest{
+	managerTest{"abc", StatusDone, "ABC", ""},
+	managerTest{"", StatusDone, "", ""},
+	managerTest{"fail", StatusFailed, "", "Cannot process input"},
+}
+
+func TestManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, err := NewManager(t.TempDir(), 2, 100, upperProcessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start(ctx)
+
+	for _, test := range managerTests {
+		j, err := m.Submit([]byte(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ValidID(j.ID) || j.Status != StatusQueued {
+			t.Errorf("Submitted job %v should be queued with a valid id", j)
+		}
+		j = waitForJob(t, m, j.ID)
+		if j.Status != test.status {
+			t.Errorf("Output status %v not equal to expected %v", j.Status, test.status)
+		}
+		if test.errString != "" && (len(j.Errors) != 1 || j.Errors[0] != test.errString) {
+			t.Errorf("Output errors %v not equal to expected %v", j.Errors, test.errString)
+		}
+		result, err := m.Result(j.ID)
+		if test.status == StatusDone && (err != nil || string(result) != test.result) {
+			t.Errorf("Output result %v (%v) not equal to expected %v", string(result), err, test.result)
+		}
+		if test.status == StatusFailed && err != ErrNotDone {
+			t.Errorf("Output error %v not equal to expected %v", err, ErrNotDone)
+		}
+	}
+
+	if _, err := m.Get(strings.Repeat("0", 32)); err != ErrNotFound {
+		t.Errorf("Output error %v not equal to expected %v", err, ErrNotFound)
+	}
+	if err := m.CheckStore(); err != nil {
+		t.Errorf("Output error %v, expected the job directory to be writable", err)
+	}
+}
+
+func TestManagerResume(t *testing.T) {
+	dir := t.TempDir()
+	//a manager that is never started leaves its jobs queued on disk, as after a crash
+	m, err := NewManager(dir, 1, 100, upperProcessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := m.Submit([]byte("resume"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restarted, err := NewManager(dir, 1, 100, upperProcessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Start(ctx)
+	if j = waitForJob(t, restarted, j.ID); j.Status != StatusDone {
+		t.Errorf("Output status %v not equal to expected %v", j.Status, StatusDone)
+	}
+	if result, _ := restarted.Result(j.ID); string(result) != "RESUME" {
+		t.Errorf("Output result %v not equal to expected %v", string(result), "RESUME")
+	}
+	if _, err := os.Stat(filepath.Join(dir, j.ID+".input")); !os.IsNotExist(err) {
+		t.Errorf("Input of finished job %v should have been removed", j.ID)
+	}
+}
+
+func TestManagerRetention(t *testing.T) {
+	if _, err := NewManager(t.TempDir(), 1, 0, upperProcessor); err == nil {
+		t.Errorf("Output nil, expected an error for a retention of 0")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	m, err := NewManager(dir, 1, 2, upperProcessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start(ctx)
+	var ids []string
+	for _, input := range []string{"a", "b", "c"} {
+		j, err := m.Submit([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitForJob(t, m, j.ID)
+		ids = append(ids, j.ID)
+	}
+	//the oldest finished job and its files are removed, the 2 most recent ones are kept
+	if _, err := m.Get(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Output error %v, expected %v", err, ErrNotFound)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, ids[0]+".*")); len(paths) != 0 {
+		t.Errorf("Output files %v, expected the files of the evicted job to be removed", paths)
+	}
+	for _, id := range ids[1:] {
+		if _, err := m.Result(id); err != nil {
+			t.Errorf("Output error %v for %v, expected its result", err, id)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, err := NewManager(t.TempDir(), 1, 100, upperProcessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start(ctx)
+	submit := util.ErrorHandler(m.SubmitHandler(func(r *http.Request) ([]byte, error) {
+		files, err := util.GetUpload(r, "customerFile")
+		if err != nil {
+			return nil, err
+		}
+		return files[0].Content, nil
+	}))
+	status := util.ErrorHandler(m.StatusHandler("/v2/invite-jobs/"))
+
+	//wrong method
+	writer := httptest.NewRecorder()
+	submit(writer, httptest.NewRequest("GET", "/v2/invite-jobs", nil))
+	if writer.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Output status %v not equal to expected %v", writer.Code, http.StatusMethodNotAllowed)
+	}
+
+	body, contentType, err := util.GetByteBuffer(filepath.Join(t.TempDir(), "jobTest.txt"), "customerFile", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/v2/invite-jobs", body)
+	req.Header.Add("Content-Type", contentType)
+	writer = httptest.NewRecorder()
+	submit(writer, req)
+	if writer.Code != http.StatusAccepted {
+		t.Fatalf("Output status %v not equal to expected %v: %v", writer.Code, http.StatusAccepted, writer.Body.String())
+	}
+	var j Job
+	if err := json.Unmarshal(writer.Body.Bytes(), &j); err != nil {
+		t.Fatal(err)
+	}
+	if location := writer.Header().Get("Location"); location != "/v2/invite-jobs/"+j.ID {
+		t.Errorf("Output location %v not equal to expected %v", location, "/v2/invite-jobs/"+j.ID)
+	}
+	waitForJob(t, m, j.ID)
+
+	type statusTest struct {
+		path   string
+		status int
+		body   string
+	}
+	for _, test := range []statusTest{
+		statusTest{"/v2/invite-jobs/" + j.ID + "/result", http.StatusOK, "ABC"},
+		statusTest{"/v2/invite-jobs/" + j.ID, http.StatusOK, "\"status\":\"done\""},
+		statusTest{"/v2/invite-jobs/" + j.ID + "/other", http.StatusNotFound, "Job not found"},
+		statusTest{"/v2/invite-jobs/../secret", http.StatusNotFound, "Job not found"},
+		statusTest{"/v2/invite-jobs/" + strings.Repeat("0", 32), http.StatusNotFound, "Job not found"},
+	} {
+		writer := httptest.NewRecorder()
+		status(writer, httptest.NewRequest("GET", test.path, nil))
+		if writer.Code != test.status || !strings.Contains(writer.Body.String(), test.body) {
+			t.Errorf("Output %v %v for %v not equal to expected %v %v", writer.Code, writer.Body.String(), test.path, test.status, test.body)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package jsonl provides a tolerant reader for JSON Lines files
+package jsonl
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// The UTF-8 byte order mark some editors put at the start of a file
+var bom = []byte("\xef\xbb\xbf")
+
+// A line holding a JSON value, with its 1 based number in the file and the name of the file (if known)
+type Line struct {
+	Source string
+	Number int
+	Data   []byte
+}
+
+// Prefix for error messages about this line
+func (l Line) Prefix() string {
+	if l.Source != "" {
+		return l.Source + ": Line " + strconv.Itoa(l.Number) + ": "
+	}
+	return "Line " + strconv.Itoa(l.Number) + ": "
+}
+
+// Split the content of a JSON Lines file into lines. A leading UTF-8 BOM is dropped, "\r\n" and "\n" endings
+// are both accepted, and blank lines and comment lines (starting with # or //) are skipped, so the same records
+// are returned however the file was saved. Line numbers still count the skipped lines
+func Split(b []byte) []Line {
+	return SplitFile("", b)
+}
+
+// Split the content of the JSON Lines file named source, see Split
+func SplitFile(source string, b []byte) []Line {
+	b = bytes.TrimPrefix(b, bom)
+	var lines []Line
+	for number := 1; len(b) > 0; number++ {
+		var data []byte
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			data, b = b[:i], b[i+1:]
+		} else {
+			data, b = b, nil
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 || IsComment(data) {
+			continue
+		}
+		lines = append(lines, Line{source, number, data})
+	}
+	return lines
+}
+
+// Check if a (trimmed) line is a comment
+func IsComment(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("#")) || bytes.HasPrefix(line, []byte("//"))
+}
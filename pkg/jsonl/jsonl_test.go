@@ -0,0 +1,53 @@
+package jsonl
+
+import (
+	"reflect"
+	"testing"
+)
+
+type splitTest struct {
+	input    string
+	expected []Line
+}
+
+var splitTests []splitTest = []splitTest{
+	splitTest{"", nil},
+	splitTest{"\n\n", nil},
+	splitTest{"{}", []Line{Line{"", 1, []byte("{}")}}},
+	//trailing newline
+	splitTest{"{}\n", []Line{Line{"", 1, []byte("{}")}}},
+	//blank lines in the middle, including whitespace only lines
+	splitTest{"{\"a\":1}\n\n  \t\n{\"b\":2}", []Line{Line{"", 1, []byte("{\"a\":1}")}, Line{"", 4, []byte("{\"b\":2}")}}},
+	//windows line endings
+	splitTest{"{\"a\":1}\r\n{\"b\":2}\r\n", []Line{Line{"", 1, []byte("{\"a\":1}")}, Line{"", 2, []byte("{\"b\":2}")}}},
+	//byte order mark
+	splitTest{"\xef\xbb\xbf{\"a\":1}\n{\"b\":2}", []Line{Line{"", 1, []byte("{\"a\":1}")}, Line{"", 2, []byte("{\"b\":2}")}}},
+	//comments
+	splitTest{"# exported 2022-01-01\n// second comment\n  # indented\n{}", []Line{Line{"", 4, []byte("{}")}}},
+	//a hash inside a value is not a comment
+	splitTest{"{\"name\": \"#1\"}", []Line{Line{"", 1, []byte("{\"name\": \"#1\"}")}}},
+}
+
+func TestSplit(t *testing.T) {
+	for _, test := range splitTests {
+		if lines := Split([]byte(test.input)); !reflect.DeepEqual(lines, test.expected) {
+			t.Errorf("Output %q not equal to expected %q", lines, test.expected)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	if p := (Line{"", 12, nil}).Prefix(); p != "Line 12: " {
+		t.Errorf("Output %v not equal to expected %v", p, "Line 12: ")
+	}
+	if p := (Line{"a.txt", 12, nil}).Prefix(); p != "a.txt: Line 12: " {
+		t.Errorf("Output %v not equal to expected %v", p, "a.txt: Line 12: ")
+	}
+}
+
+func TestSplitFile(t *testing.T) {
+	expected := []Line{Line{"a.txt", 2, []byte("{}")}}
+	if lines := SplitFile("a.txt", []byte("\n{}")); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Output %q not equal to expected %q", lines, expected)
+	}
+}
@@ -0,0 +1,260 @@
+// Package logger provides a leveled, structured (JSON lines) logger with request ID propagation and PII redaction
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Implement stringer so the level is printed as a readable name
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// Convert a level name (e.g. "info", case insensitive) into a Level
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return LevelInfo, errors.New("Unknown log level: " + s)
+}
+
+// The value written in place of redacted fields
+const Redacted = "[REDACTED]"
+
+// Keys whose values are considered personal data and are redacted when redaction is on
+var RedactedKeys = map[string]bool{
+	"name":      true,
+	"latitude":  true,
+	"longitude": true,
+	"location":  true,
+}
+
+// Matches "key": value pairs of personal data embedded in free text, e.g. a customer JSON line quoted in an error
+var redactPattern = regexp.MustCompile(`"(name|latitude|longitude)"\s*:\s*("(?:[^"\\]|\\.)*"|[-+0-9.eE]+)`)
+
+// Logger writes one JSON object per entry to the underlying writer
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  Level
+	redact bool
+}
+
+// Create a logger writing entries at or above level to out. PII redaction is on by default
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{out: out, level: level, redact: true}
+}
+
+// Turn PII redaction on or off
+func (l *Logger) SetRedaction(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redact = on
+}
+
+// Change the minimum level written by the logger
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// Report whether entries at level would be written
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level >= l.level
+}
+
+// Write an entry. args are alternating key/value pairs, the request ID found in ctx (if any) is added automatically
+func (l *Logger) Log(ctx context.Context, level Level, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	writeField(buf, "time", time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteByte(',')
+	writeField(buf, "level", level.String())
+	buf.WriteByte(',')
+	writeField(buf, "msg", l.scrub(msg))
+	if id := RequestID(ctx); id != "" {
+		buf.WriteByte(',')
+		writeField(buf, "request_id", id)
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		var value interface{} = "!MISSING"
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		buf.WriteByte(',')
+		writeField(buf, key, l.redactValue(key, value))
+	}
+	buf.WriteString("}\n")
+	l.out.Write(buf.Bytes())
+}
+
+// Log at debug level
+func (l *Logger) Debug(ctx context.Context, msg string, args ...interface{}) {
+	l.Log(ctx, LevelDebug, msg, args...)
+}
+
+// Log at info level
+func (l *Logger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.Log(ctx, LevelInfo, msg, args...)
+}
+
+// Log at warn level
+func (l *Logger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.Log(ctx, LevelWarn, msg, args...)
+}
+
+// Log at error level
+func (l *Logger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.Log(ctx, LevelError, msg, args...)
+}
+
+// Replace the value of a personal data key, or scrub personal data out of a string value
+func (l *Logger) redactValue(key string, value interface{}) interface{} {
+	if !l.redact {
+		return value
+	}
+	if RedactedKeys[strings.ToLower(key)] {
+		return Redacted
+	}
+	switch v := value.(type) {
+	case string:
+		return l.scrub(v)
+	case error:
+		return l.scrub(v.Error())
+	case fmt.Stringer:
+		return l.scrub(v.String())
+	}
+	return value
+}
+
+// Remove personal data embedded in free text
+func (l *Logger) scrub(s string) string {
+	if !l.redact {
+		return s
+	}
+	return redactPattern.ReplaceAllString(s, `"$1": "`+Redacted+`"`)
+}
+
+// Write "key":value, falling back to the formatted value if it cannot be marshaled
+func writeField(buf *bytes.Buffer, key string, value interface{}) {
+	k, _ := json.Marshal(key)
+	buf.Write(k)
+	buf.WriteByte(':')
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(v)
+}
+
+// Key type for values stored in a context by this package
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// Return a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// Return the request ID stored in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Generate a random request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+var defaultLogger = New(os.Stderr, LevelInfo)
+
+// Return the logger used by the package level functions
+func Default() *Logger {
+	return defaultLogger
+}
+
+// Replace the logger used by the package level functions
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
+
+// Log at debug level with the default logger
+func Debug(ctx context.Context, msg string, args ...interface{}) {
+	defaultLogger.Debug(ctx, msg, args...)
+}
+
+// Log at info level with the default logger
+func Info(ctx context.Context, msg string, args ...interface{}) {
+	defaultLogger.Info(ctx, msg, args...)
+}
+
+// Log at warn level with the default logger
+func Warn(ctx context.Context, msg string, args ...interface{}) {
+	defaultLogger.Warn(ctx, msg, args...)
+}
+
+// Log at error level with the default logger
+func Error(ctx context.Context, msg string, args ...interface{}) {
+	defaultLogger.Error(ctx, msg, args...)
+}
@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type parseLevelTest struct {
+	input     string
+	expected  Level
+	errString string
+}
+
+var parseLevelTests []parseLevelTest = []parseLevelTest{
+	parseLevelTest{"debug", LevelDebug, ""},
+	parseLevelTest{"INFO", LevelInfo, ""},
+	parseLevelTest{"Warn", LevelWarn, ""},
+	parseLevelTest{"warning", LevelWarn, ""},
+	parseLevelTest{"error", LevelError, ""},
+	parseLevelTest{"verbose", LevelInfo, "Unknown log level"},
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, test := range parseLevelTests {
+		l, err := ParseLevel(test.input)
+		if err != nil && !strings.Contains(err.Error(), test.errString) {
+			t.Errorf("Output error %v is not the same as expected %v", err.Error(), test.errString)
+		}
+		if err == nil && test.errString != "" {
+			t.Errorf("Expected error %v but got none", test.errString)
+		}
+		if l != test.expected {
+			t.Errorf("Output %v not equal to expected %v", l, test.expected)
+		}
+	}
+}
+
+type logTest struct {
+	level     Level
+	requestID string
+	redact    bool
+	msg       string
+	args      []interface{}
+	expected  map[string]interface{}
+}
+
+var logTests []logTest = []logTest{
+	//below the minimum level, nothing is written
+	logTest{LevelDebug, "", true, "hidden", nil, nil},
+	logTest{LevelInfo, "", true, "hello", nil, map[string]interface{}{"level": "INFO", "msg": "hello"}},
+	logTest{LevelError, "abc", true, "failed", []interface{}{"count", 3, "error", errors.New("boom")},
+		map[string]interface{}{"level": "ERROR", "msg": "failed", "request_id": "abc", "count": 3.0, "error": "boom"}},
+	//odd number of args
+	logTest{LevelWarn, "", true, "odd", []interface{}{"key"}, map[string]interface{}{"msg": "odd", "key": "!MISSING"}},
+	//redaction of keys and of personal data embedded in text
+	logTest{LevelInfo, "", true, "customer", []interface{}{"name", "John", "user_id", 1},
+		map[string]interface{}{"name": Redacted, "user_id": 1.0}},
+	logTest{LevelInfo, "", true, "Invalid JSON: {\"name\": \"John\", \"latitude\": 53.1, \"user_id\": 1}", nil,
+		map[string]interface{}{"msg": "Invalid JSON: {\"name\": \"[REDACTED]\", \"latitude\": \"[REDACTED]\", \"user_id\": 1}"}},
+	logTest{LevelInfo, "", false, "customer", []interface{}{"name", "John"}, map[string]interface{}{"name": "John"}},
+}
+
+func TestLog(t *testing.T) {
+	for _, test := range logTests {
+		out := new(bytes.Buffer)
+		l := New(out, LevelInfo)
+		l.SetRedaction(test.redact)
+		ctx := context.Background()
+		if test.requestID != "" {
+			ctx = WithRequestID(ctx, test.requestID)
+		}
+		l.Log(ctx, test.level, test.msg, test.args...)
+
+		if test.expected == nil {
+			if out.Len() != 0 {
+				t.Errorf("Expected no output but got %v", out.String())
+			}
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Fatalf("Output %v is not valid JSON: %v", out.String(), err)
+		}
+		if _, found := entry["time"]; !found {
+			t.Errorf("Output %v has no time", out.String())
+		}
+		for key, value := range test.expected {
+			if entry[key] != value {
+				t.Errorf("Output %v for key %v not equal to expected %v", entry[key], key, value)
+			}
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("Output %v not equal to expected empty id", id)
+	}
+	id := NewRequestID()
+	if len(id) != 16 {
+		t.Errorf("Request id %v should have 16 characters", id)
+	}
+	if r := RequestID(WithRequestID(context.Background(), id)); r != id {
+		t.Errorf("Output %v not equal to expected %v", r, id)
+	}
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	f, err := OpenRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, line := range []string{"12345\n", "12345\n", "12345\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 2 {
+		t.Errorf("Expected 2 rotated files but got %v", matches)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "12345\n" {
+		t.Errorf("Active file content %q not equal to expected %q", b, "12345\n")
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	f, err := OpenRotatingFile(path, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.Write([]byte("old\n"))
+	now = now.Add(2 * time.Hour)
+	f.Write([]byte("new\n"))
+
+	matches, _ := filepath.Glob(path + ".*")
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 rotated file but got %v", matches)
+	}
+	if b, _ := os.ReadFile(matches[0]); string(b) != "old\n" {
+		t.Errorf("Rotated file content %q not equal to expected %q", b, "old\n")
+	}
+}
+
+type existingAgeTest struct {
+	content string
+	rotated bool
+}
+
+var existingAgeTests []existingAgeTest = []existingAgeTest{
+	//created before the restart, even if written a minute ago
+	existingAgeTest{"{\"time\":\"" + time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339Nano) + "\",\"level\":\"INFO\",\"msg\":\"old\"}\n", true},
+	existingAgeTest{"{\"time\":\"" + time.Now().Add(-30*time.Minute).UTC().Format(time.RFC3339Nano) + "\",\"level\":\"INFO\",\"msg\":\"old\"}\n", false},
+	//not an entry, the last write is used
+	existingAgeTest{"old\n", false},
+}
+
+func TestRotatingFileExistingAge(t *testing.T) {
+	for _, test := range existingAgeTests {
+		path := filepath.Join(t.TempDir(), "log.txt")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := OpenRotatingFile(path, 0, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("new\n"))
+		f.Close()
+
+		matches, _ := filepath.Glob(path + ".*")
+		if (len(matches) == 1) != test.rotated {
+			t.Errorf("Output rotated files %v for %q, expected rotated %v", matches, test.content, test.rotated)
+		}
+	}
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	f, err := OpenRotatingFile(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	f.Write([]byte("one\n"))
+
+	//a directory in the way of the rotated file makes the rename fail
+	rotated := path + "." + now.UTC().Format(rotateTimeLayout)
+	if err := os.Mkdir(rotated, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("two\n")); err != nil {
+		t.Errorf("Output error %v, expected the entry to be written to the active file", err)
+	}
+	os.Remove(rotated)
+	//the rotation is not tried again before the delay
+	f.Write([]byte("three\n"))
+	if b, _ := os.ReadFile(path); string(b) != "one\ntwo\nthree\n" {
+		t.Errorf("Active file content %q not equal to expected %q", b, "one\ntwo\nthree\n")
+	}
+	now = now.Add(rotateRetryDelay)
+	rotated = path + "." + now.UTC().Format(rotateTimeLayout)
+	if _, err := f.Write([]byte("four\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(rotated); string(b) != "one\ntwo\nthree\n" {
+		t.Errorf("Rotated file content %q not equal to expected %q", b, "one\ntwo\nthree\n")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "four\n" {
+		t.Errorf("Active file content %q not equal to expected %q", b, "four\n")
+	}
+}
+
+func TestRotatingFileCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenRotatingFile(filepath.Join(dir, "log.txt"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.CheckWritable(); err != nil {
+		t.Errorf("Output error %v, expected the log file to be writable", err)
+	}
+	os.RemoveAll(dir)
+	if err := f.CheckWritable(); err == nil {
+		t.Errorf("Expected an error once the log directory is removed")
+	}
+}
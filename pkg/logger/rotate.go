@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Layout of the timestamp appended to the name of a rotated log file
+const rotateTimeLayout = "20060102T150405.000000000"
+
+// Delay before a failed rotation is tried again, the entries are written to the active file meanwhile
+const rotateRetryDelay = time.Minute
+
+// Number of bytes read to find the time of the first entry of an existing file
+const firstEntrySize = 4096
+
+// An append-only log file that is rotated once it grows past maxSize bytes or has been written to for longer than maxAge.
+// A zero maxSize or maxAge disables the corresponding limit
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxAge   time.Duration
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// No rotation is tried before retryAt, after a failure
+	retryAt time.Time
+	now     func() time.Time
+}
+
+// Open (or create) the log file at path
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Return the path of the active log file
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+// Open the active file and record its current size. An existing file is as old as its first entry, so a restart does
+// not postpone its rotation by maxAge. The last write is used for a file whose first line is not an entry
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.now()
+	if f.size > 0 {
+		f.openedAt = info.ModTime()
+		if first, ok := firstEntryTime(file); ok {
+			f.openedAt = first
+		}
+	}
+	return nil
+}
+
+// Return the time of the first entry of a log file
+func firstEntryTime(file *os.File) (time.Time, bool) {
+	buf := make([]byte, firstEntrySize)
+	n, _ := file.ReadAt(buf, 0)
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	var entry struct {
+		Time string `json:"time"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, entry.Time)
+	return t, err == nil
+}
+
+// Move the active file aside and start a new one. The active file is closed once the new one is open, so that it is
+// still open after a failure
+func (f *RotatingFile) rotate() error {
+	rotated := f.path + "." + f.now().UTC().Format(rotateTimeLayout)
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	old := f.file
+	if err := f.open(); err != nil {
+		//best effort, put the active file back so that the next write tries again
+		os.Rename(rotated, f.path)
+		f.file = old
+		return err
+	}
+	return old.Close()
+}
+
+// Implement io.Writer, rotating the file first if a limit has been reached. When the rotation fails, the error is
+// reported on the standard error and p is still written to the active file, the rotation is tried again after
+// rotateRetryDelay
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	tooBig := f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize
+	tooOld := f.maxAge > 0 && now.Sub(f.openedAt) >= f.maxAge
+	if (tooBig || tooOld) && !now.Before(f.retryAt) {
+		if err := f.rotate(); err != nil {
+			f.retryAt = now.Add(rotateRetryDelay)
+			fmt.Fprintln(os.Stderr, "Cannot rotate log file "+f.path+" : "+err.Error())
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Check that the log file can still be written, for readiness checks
+func (f *RotatingFile) CheckWritable() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Close the active file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = Default.NewCounterVec("http_requests_total",
+		"Number of HTTP requests handled, by route, method and status code.", "route", "method", "status")
+	httpDuration = Default.NewHistogramVec("http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route, method and status code.", DefaultLatencyBuckets, "route", "method", "status")
+)
+
+// A ResponseWriter which remembers the status code written
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// Record the status code before passing it on
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Writing a body without a header implies 200
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Methods used as the method label, any other one is counted as "other" so clients cannot create labels at will
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// Return the method label of a request
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return "other"
+}
+
+// Wrap a handler (typically the one returned by util.ErrorHandler) to count requests and measure their latency
+// under the given route label
+func Instrument(route string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		f(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		status, method := strconv.Itoa(recorder.status), methodLabel(r.Method)
+		httpRequests.Inc(route, method, status)
+		httpDuration.Observe(time.Since(start).Seconds(), route, method, status)
+	}
+}
@@ -0,0 +1,257 @@
+// Package metrics provides counters and histograms exposed in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default histogram buckets for latencies in seconds
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default histogram buckets for sizes in bytes
+var DefaultSizeBuckets = []float64{1 << 10, 16 << 10, 128 << 10, 1 << 20, 4 << 20, 10 << 20}
+
+// Default histogram buckets for record counts
+var DefaultCountBuckets = []float64{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
+
+// Anything that can be written out by a registry
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// A set of metrics exposed together
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+// Create an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// The registry used by the service
+var Default = NewRegistry()
+
+// Add a collector, panicking on duplicate names as this is a programming error
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: duplicate metric name " + name)
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Write all metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Return a handler serving the registry
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.Write(w)
+	}
+}
+
+// Name, help and label names shared by all metric kinds
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// Write the HELP and TYPE lines
+func (d *desc) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer("\\", `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, kind)
+}
+
+// Build the key identifying a label set, panicking on a wrong number of values
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// Format {label="value",...} for a label set, with optional extra label (e.g. le)
+func (d *desc) formatLabels(key string, extraName string, extraValue string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+"=\""+escapeLabel(value)+"\"")
+		}
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+"=\""+escapeLabel(extraValue)+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Escape a label value as required by the exposition format
+func escapeLabel(s string) string {
+	return strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`).Replace(s)
+}
+
+// Format a sample value
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Return the keys of m in a deterministic order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A monotonically increasing value per label set
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Create and register a counter
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	r.register(name, c)
+	return c
+}
+
+// Add v (which must not be negative) to the counter of the label set
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += v
+}
+
+// Add 1 to the counter of the label set
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Return the current value for the label set
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+// Write the counter samples
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(key, "", ""), formatValue(c.values[key]))
+	}
+}
+
+// Bucket counts, sum and count of the observations of one label set
+type histogramValue struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// Observations counted in cumulative buckets per label set
+type HistogramVec struct {
+	desc
+	upperBounds []float64
+	mu          sync.Mutex
+	values      map[string]*histogramValue
+}
+
+// Create and register a histogram with the given (ascending) bucket upper bounds
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	h := &HistogramVec{desc: desc{name, help, labels}, upperBounds: bounds, values: make(map[string]*histogramValue)}
+	r.register(name, h)
+	return h
+}
+
+// Record an observation for the label set
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value, found := h.values[key]
+	if !found {
+		value = &histogramValue{buckets: make([]uint64, len(h.upperBounds))}
+		h.values[key] = value
+	}
+	for i, bound := range h.upperBounds {
+		if v <= bound {
+			value.buckets[i]++
+		}
+	}
+	value.sum += v
+	value.count++
+}
+
+// Return the number of observations for the label set
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if value, found := h.values[key]; found {
+		return value.count
+	}
+	return 0
+}
+
+// Write the histogram samples
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+		for i, bound := range h.upperBounds {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", formatValue(bound)), value.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(key, "", ""), formatValue(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(key, "", ""), value.count)
+	}
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "A test counter.", "route", "status")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+	c.Inc("/b\"", "400")
+
+	out := new(bytes.Buffer)
+	if err := r.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# HELP test_total A test counter.\n" +
+		"# TYPE test_total counter\n" +
+		"test_total{route=\"/a\",status=\"200\"} 3\n" +
+		"test_total{route=\"/b\\\"\",status=\"400\"} 1\n"
+	if out.String() != expected {
+		t.Errorf("Output %q not equal to expected %q", out.String(), expected)
+	}
+	if v := c.Value("/a", "200"); v != 3 {
+		t.Errorf("Output %v not equal to expected %v", v, 3)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_seconds", "A test histogram.", []float64{1, 0.5})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	out := new(bytes.Buffer)
+	r.Write(out)
+	expected := "# HELP test_seconds A test histogram.\n" +
+		"# TYPE test_seconds histogram\n" +
+		"test_seconds_bucket{le=\"0.5\"} 1\n" +
+		"test_seconds_bucket{le=\"1\"} 2\n" +
+		"test_seconds_bucket{le=\"+Inf\"} 3\n" +
+		"test_seconds_sum 3.9\n" +
+		"test_seconds_count 3\n"
+	if out.String() != expected {
+		t.Errorf("Output %q not equal to expected %q", out.String(), expected)
+	}
+}
+
+type labelCountTest struct {
+	labels []string
+	panics bool
+}
+
+var labelCountTests []labelCountTest = []labelCountTest{
+	labelCountTest{[]string{"a"}, false},
+	labelCountTest{[]string{}, true},
+	labelCountTest{[]string{"a", "b"}, true},
+}
+
+func TestLabelCount(t *testing.T) {
+	c := NewRegistry().NewCounterVec("test_total", "", "route")
+	for _, test := range labelCountTests {
+		func() {
+			defer func() {
+				if r := recover(); (r != nil) != test.panics {
+					t.Errorf("Labels %v panic %v not equal to expected %v", test.labels, r != nil, test.panics)
+				}
+			}()
+			c.Inc(test.labels...)
+		}()
+	}
+}
+
+type instrumentTest struct {
+	route    string
+	status   int
+	write    bool
+	expected string
+}
+
+var instrumentTests []instrumentTest = []instrumentTest{
+	instrumentTest{"/test/ok", 0, true, "200"},
+	instrumentTest{"/test/implicit", 0, false, "200"},
+	instrumentTest{"/test/bad", http.StatusBadRequest, true, "400"},
+}
+
+func TestInstrument(t *testing.T) {
+	for _, test := range instrumentTests {
+		f := Instrument(test.route, func(w http.ResponseWriter, r *http.Request) {
+			if test.status != 0 {
+				w.WriteHeader(test.status)
+			}
+			if test.write {
+				w.Write([]byte("body"))
+			}
+		})
+		f(httptest.NewRecorder(), httptest.NewRequest("PUT", test.route, nil))
+
+		if v := httpRequests.Value(test.route, "PUT", test.expected); v != 1 {
+			t.Errorf("Request count %v for route %v not equal to expected 1", v, test.route)
+		}
+		if c := httpDuration.Count(test.route, "PUT", test.expected); c != 1 {
+			t.Errorf("Latency count %v for route %v not equal to expected 1", c, test.route)
+		}
+	}
+
+	//a method outside the standard ones does not create a label of its own
+	Instrument("/test/method", func(w http.ResponseWriter, r *http.Request) {})(httptest.NewRecorder(), httptest.NewRequest("FOO", "/test/method", nil))
+	if v := httpRequests.Value("/test/method", "other", "200"); v != 1 {
+		t.Errorf("Request count %v for method other not equal to expected 1", v)
+	}
+	if v := httpRequests.Value("/test/method", "FOO", "200"); v != 0 {
+		t.Errorf("Request count %v for method FOO not equal to expected 0", v)
+	}
+
+	writer := httptest.NewRecorder()
+	Default.Handler()(writer, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(writer.Body.String(), "http_requests_total{route=\"/test/bad\",method=\"PUT\",status=\"400\"} 1") {
+		t.Errorf("Metrics output %v does not contain the request count", writer.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package road
+
+import (
+	"math"
+	"sort"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// A k-d tree over the nodes of a graph, built once when the graph is loaded. The nodes are indexed by their unit
+// vector, where the nearest node by chord is also the nearest along the great circle, so the search has no special
+// case at the poles or across the antimeridian
+type nodeIndex struct {
+	// Unit vector of every node
+	vectors [][3]float64
+	// The nodes, the node in the middle of every range splits it on the axis of its depth
+	order []int
+}
+
+// Unit vector of a point
+func unitVector(p greatCircle.Point) [3]float64 {
+	return [3]float64{
+		math.Cos(p.Latitude) * math.Cos(p.Longitude),
+		math.Cos(p.Latitude) * math.Sin(p.Longitude),
+		math.Sin(p.Latitude),
+	}
+}
+
+func newNodeIndex(nodes []greatCircle.Point) *nodeIndex {
+	ix := &nodeIndex{vectors: make([][3]float64, len(nodes)), order: make([]int, len(nodes))}
+	for i, p := range nodes {
+		ix.vectors[i] = unitVector(p)
+		ix.order[i] = i
+	}
+	ix.build(0, len(nodes), 0)
+	return ix
+}
+
+// Split the nodes of order[lo:hi] around their median on the axis of depth, then both halves on the next axis
+func (ix *nodeIndex) build(lo int, hi int, depth int) {
+	if hi-lo <= 1 {
+		return
+	}
+	axis := depth % 3
+	nodes := ix.order[lo:hi]
+	sort.Slice(nodes, func(a, b int) bool { return ix.vectors[nodes[a]][axis] < ix.vectors[nodes[b]][axis] })
+	mid := (lo + hi) / 2
+	ix.build(lo, mid, depth+1)
+	ix.build(mid+1, hi, depth+1)
+}
+
+// Return the node nearest to p
+func (ix *nodeIndex) nearest(p greatCircle.Point) int {
+	v := unitVector(p)
+	best, bestDistance := -1, math.Inf(1)
+	var search func(lo int, hi int, depth int)
+	search = func(lo int, hi int, depth int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		node := ix.order[mid]
+		w := ix.vectors[node]
+		if d := (v[0]-w[0])*(v[0]-w[0]) + (v[1]-w[1])*(v[1]-w[1]) + (v[2]-w[2])*(v[2]-w[2]); d < bestDistance {
+			best, bestDistance = node, d
+		}
+		axis := depth % 3
+		diff := v[axis] - w[axis]
+		if diff < 0 {
+			search(lo, mid, depth+1)
+			if diff*diff < bestDistance {
+				search(mid+1, hi, depth+1)
+			}
+		} else {
+			search(mid+1, hi, depth+1)
+			if diff*diff < bestDistance {
+				search(lo, mid, depth+1)
+			}
+		}
+	}
+	search(0, len(ix.order), 0)
+	return best
+}
@@ -0,0 +1,316 @@
+// Package road finds the shortest paths, by distance or by travel time, over a road graph read from a local file
+package road
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// What a shortest path minimizes
+type Metric string
+
+const (
+	MetricDistance Metric = "distance"
+	MetricTime     Metric = "time"
+)
+
+// Speed in km/h of the straight lines between a location and the node it is snapped to, when none is set
+const DefaultAccessSpeed = 20
+
+// A road from a node to another
+type edge struct {
+	to int
+	// Length in km
+	length float64
+	// Speed in km/h
+	speed float64
+}
+
+// An immutable road graph, the nodes are road junctions and the edges the roads between them
+type Graph struct {
+	// Location of the nodes, in radian
+	nodes []greatCircle.Point
+	// Outgoing edges, by node
+	edges [][]edge
+	// Nearest node searches, built once the nodes are read
+	index *nodeIndex
+	// Highest speed of the edges, in km/h
+	maxSpeed float64
+	// Speed in km/h of the straight lines between a location and the node it is snapped to
+	AccessSpeed float64
+}
+
+// Read a graph from a tab separated file of "node" and "edge" lines:
+//
+//	node	<id>	<latitude>	<longitude>
+//	edge	<from id>	<to id>	<speed in km/h>	[<length in km>]	[oneway]
+//
+// The nodes are declared before the edges using them. An edge without length, or with a shorter one, is as long as the
+// great circle between its nodes, and goes both ways unless its last column is "oneway". Empty lines and lines starting
+// with '#' are skipped
+func Load(r io.Reader) (*Graph, error) {
+	g := &Graph{AccessSpeed: DefaultAccessSpeed}
+	ids := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var err error
+		fields := strings.Split(text, "\t")
+		switch fields[0] {
+		case "node":
+			err = g.parseNode(fields, ids)
+		case "edge":
+			err = g.parseEdge(fields, ids)
+		default:
+			err = errors.New("unknown record " + fields[0] + ", expected node or edge")
+		}
+		if err != nil {
+			return nil, errors.New("line " + strconv.Itoa(line) + " : " + err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.nodes) == 0 {
+		return nil, errors.New("No node found")
+	}
+	g.index = newNodeIndex(g.nodes)
+	return g, nil
+}
+
+// Read the graph of the file at path, see Load
+func LoadFile(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := Load(f)
+	if err != nil {
+		return nil, errors.New("Cannot load road graph " + path + " : " + err.Error())
+	}
+	return g, nil
+}
+
+func (g *Graph) parseNode(fields []string, ids map[string]int) error {
+	if len(fields) != 4 {
+		return errors.New("expected node, id, latitude and longitude")
+	}
+	if _, found := ids[fields[1]]; found {
+		return errors.New("duplicate node " + fields[1])
+	}
+	latitude, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return errors.New("invalid latitude " + fields[2])
+	}
+	longitude, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return errors.New("invalid longitude " + fields[3])
+	}
+	p := greatCircle.MakePoint(greatCircle.DegreeToRadian(longitude), greatCircle.DegreeToRadian(latitude))
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	ids[fields[1]] = len(g.nodes)
+	g.nodes = append(g.nodes, p)
+	g.edges = append(g.edges, nil)
+	return nil
+}
+
+func (g *Graph) parseEdge(fields []string, ids map[string]int) error {
+	if len(fields) < 4 || len(fields) > 6 {
+		return errors.New("expected edge, from, to, speed, and optionally length and oneway")
+	}
+	from, found := ids[fields[1]]
+	if !found {
+		return errors.New("unknown node " + fields[1])
+	}
+	to, found := ids[fields[2]]
+	if !found {
+		return errors.New("unknown node " + fields[2])
+	}
+	speed, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil || speed <= 0 {
+		return errors.New("invalid speed " + fields[3] + ", expected km/h > 0")
+	}
+	length := greatCircle.Distance(g.nodes[from], g.nodes[to], greatCircle.Radius)
+	if len(fields) > 4 && fields[4] != "" {
+		straight := length
+		if length, err = strconv.ParseFloat(fields[4], 64); err != nil || length < 0 {
+			return errors.New("invalid length " + fields[4] + ", expected km >= 0")
+		}
+		//the A* heuristic needs the roads to be at least as long as the great circle between their ends, a shorter
+		//length (e.g. rounded in the export) is taken as the great circle
+		length = math.Max(length, straight)
+	}
+	oneway := false
+	if len(fields) > 5 {
+		if fields[5] != "oneway" {
+			return errors.New("invalid direction " + fields[5] + ", expected oneway")
+		}
+		oneway = true
+	}
+	g.edges[from] = append(g.edges[from], edge{to, length, speed})
+	if !oneway {
+		g.edges[to] = append(g.edges[to], edge{from, length, speed})
+	}
+	g.maxSpeed = math.Max(g.maxSpeed, speed)
+	return nil
+}
+
+// Number of nodes
+func (g *Graph) Len() int {
+	return len(g.nodes)
+}
+
+// Return the node nearest to location, found in the index, and the distance to it in km
+func (g *Graph) snap(location greatCircle.Point) (int, float64) {
+	nearest := g.index.nearest(location)
+	return nearest, greatCircle.Distance(location, g.nodes[nearest], greatCircle.Radius)
+}
+
+// Cost of a straight line of length km taken at speed km/h, in the metric
+func cost(metric Metric, length float64, speed float64) float64 {
+	if metric == MetricTime {
+		return length / speed
+	}
+	return length
+}
+
+// A trip between two locations
+type Route struct {
+	// Distance in km
+	Distance float64
+	Time     time.Duration
+}
+
+// Trip along the straight line to the snapped node
+func (g *Graph) access(length float64) Route {
+	return Route{length, hours(length / g.AccessSpeed)}
+}
+
+func (r Route) add(o Route) Route {
+	return Route{r.Distance + o.Distance, r.Time + o.Time}
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// The shortest paths from a location to every node, see Graph.ShortestPaths
+type Tree struct {
+	graph *Graph
+	// Trip from the origin to every node, nil when a node cannot be reached
+	routes []*Route
+}
+
+// Compute with Dijkstra the shortest paths, minimizing metric, from location to every node of the graph. The location
+// is snapped to its nearest node
+func (g *Graph) ShortestPaths(location greatCircle.Point, metric Metric) *Tree {
+	origin, length := g.snap(location)
+	t := &Tree{graph: g, routes: make([]*Route, len(g.nodes))}
+	costs := make([]float64, len(g.nodes))
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	start := g.access(length)
+	costs[origin] = 0
+	t.routes[origin] = &start
+	queue := &nodeQueue{{origin, 0}}
+	for queue.Len() > 0 {
+		n := heap.Pop(queue).(queued)
+		if n.priority > costs[n.node] {
+			continue
+		}
+		for _, e := range g.edges[n.node] {
+			c := costs[n.node] + cost(metric, e.length, e.speed)
+			if c < costs[e.to] {
+				costs[e.to] = c
+				r := t.routes[n.node].add(Route{e.length, hours(e.length / e.speed)})
+				t.routes[e.to] = &r
+				heap.Push(queue, queued{e.to, c})
+			}
+		}
+	}
+	return t
+}
+
+// Return the trip from the origin of the tree to location, snapped to its nearest node. The second value is false
+// when the node cannot be reached
+func (t *Tree) Route(location greatCircle.Point) (Route, bool) {
+	node, length := t.graph.snap(location)
+	if t.routes[node] == nil {
+		return Route{}, false
+	}
+	return t.routes[node].add(t.graph.access(length)), true
+}
+
+// Compute with A* the shortest path, minimizing metric, between two locations snapped to their nearest nodes. The
+// heuristic is the great circle distance to the destination, taken at the highest speed of the graph for the time.
+// Prefer ShortestPaths to route one origin to many locations. The second value is false when the destination cannot
+// be reached
+func (g *Graph) Route(from greatCircle.Point, to greatCircle.Point, metric Metric) (Route, bool) {
+	origin, originLength := g.snap(from)
+	destination, destinationLength := g.snap(to)
+	heuristic := func(node int) float64 {
+		if g.maxSpeed == 0 {
+			return 0
+		}
+		return cost(metric, greatCircle.Distance(g.nodes[node], g.nodes[destination], greatCircle.Radius), g.maxSpeed)
+	}
+	costs := map[int]float64{origin: 0}
+	routes := map[int]Route{origin: {}}
+	queue := &nodeQueue{{origin, heuristic(origin)}}
+	for queue.Len() > 0 {
+		n := heap.Pop(queue).(queued)
+		if n.node == destination {
+			return g.access(originLength).add(routes[destination]).add(g.access(destinationLength)), true
+		}
+		if n.priority > costs[n.node]+heuristic(n.node) {
+			continue
+		}
+		for _, e := range g.edges[n.node] {
+			c := costs[n.node] + cost(metric, e.length, e.speed)
+			if known, found := costs[e.to]; !found || c < known {
+				costs[e.to] = c
+				routes[e.to] = routes[n.node].add(Route{e.length, hours(e.length / e.speed)})
+				heap.Push(queue, queued{e.to, c + heuristic(e.to)})
+			}
+		}
+	}
+	return Route{}, false
+}
+
+// A node in the priority queue of Dijkstra and A*
+type queued struct {
+	node     int
+	priority float64
+}
+
+// Min-heap of the nodes to visit, implementing heap.Interface
+type nodeQueue []queued
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queued)) }
+func (q *nodeQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,171 @@
+package road
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/greatCircle"
+)
+
+// Return a point from degrees
+func degreePoint(latitude float64, longitude float64) greatCircle.Point {
+	return greatCircle.MakePoint(greatCircle.DegreeToRadian(longitude), greatCircle.DegreeToRadian(latitude))
+}
+
+var (
+	howth        = degreePoint(53.3870, -6.0650)
+	dunLaoghaire = degreePoint(53.2940, -6.1339)
+)
+
+func loadSample(t *testing.T) *Graph {
+	f, err := os.Open("../../Data/roads.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// Return the shortest trip between two locations
+func route(g *Graph, from greatCircle.Point, to greatCircle.Point, metric Metric) (Route, bool) {
+	return g.ShortestPaths(from, metric).Route(to)
+}
+
+func TestRoute(t *testing.T) {
+	g := loadSample(t)
+	//Howth and Dun Laoghaire face each other across Dublin Bay, the road goes around it through the city centre
+	straight := greatCircle.Distance(howth, dunLaoghaire, greatCircle.Radius)
+	r, found := route(g, howth, dunLaoghaire, MetricDistance)
+	if !found || r.Distance < 2*straight {
+		t.Errorf("Output %v, %v, expected a road much longer than the %v km straight line", r, found, straight)
+	}
+	if back, found := route(g, dunLaoghaire, howth, MetricDistance); !found || math.Abs(back.Distance-r.Distance) > 1e-9 {
+		t.Errorf("Output %v, expected the same road back %v", back, r)
+	}
+	//A* finds the same trip as Dijkstra, whatever the metric
+	for _, metric := range []Metric{MetricDistance, MetricTime} {
+		tree, _ := route(g, howth, dunLaoghaire, metric)
+		if a, found := g.Route(howth, dunLaoghaire, metric); !found || math.Abs(a.Distance-tree.Distance) > 1e-9 || a.Time != tree.Time {
+			t.Errorf("Output %v by %v, expected the Dijkstra route %v", a, metric, tree)
+		}
+	}
+	//the bypass through c is longer but faster than the direct road from a to b
+	bypass, err := Load(strings.NewReader("node\ta\t53\t-6\nnode\tb\t53.1\t-6\nnode\tc\t53.05\t-5.9\n" +
+		"edge\ta\tb\t10\nedge\ta\tc\t100\nedge\tc\tb\t100\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byDistance, _ := route(bypass, degreePoint(53, -6), degreePoint(53.1, -6), MetricDistance)
+	byTime, _ := route(bypass, degreePoint(53, -6), degreePoint(53.1, -6), MetricTime)
+	if math.Abs(byDistance.Distance-greatCircle.Distance(degreePoint(53, -6), degreePoint(53.1, -6), greatCircle.Radius)) > 1e-9 ||
+		byTime.Distance <= byDistance.Distance || byTime.Time >= byDistance.Time {
+		t.Errorf("Output %v by time and %v by distance, expected the direct road by distance and the bypass by time", byTime, byDistance)
+	}
+	//a location is snapped to its nearest node, the straight line to it is taken at AccessSpeed
+	r, _ = route(g, howth, degreePoint(53.3870, -6.0560), MetricTime)
+	if expected := time.Duration(r.Distance / DefaultAccessSpeed * float64(time.Hour)); r.Time != expected || r.Distance < 0.5 {
+		t.Errorf("Output %v, expected %v", r, expected)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	g, err := Load(strings.NewReader("node\ta\t53\t-6\nnode\tb\t53.1\t-6\nnode\tc\t54\t-6\nedge\ta\tb\t50\t\toneway\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := route(g, degreePoint(53, -6), degreePoint(53.1, -6), MetricTime); !found {
+		t.Errorf("Expected b to be reached from a")
+	}
+	if _, found := route(g, degreePoint(53.1, -6), degreePoint(53, -6), MetricTime); found {
+		t.Errorf("Expected a not to be reached from b on a oneway road")
+	}
+	if _, found := g.Route(degreePoint(53.1, -6), degreePoint(53, -6), MetricTime); found {
+		t.Errorf("Expected A* not to reach a from b on a oneway road")
+	}
+	if _, found := g.ShortestPaths(degreePoint(53, -6), MetricDistance).Route(degreePoint(54, -6)); found {
+		t.Errorf("Expected c not to be reached")
+	}
+}
+
+type loadTest struct {
+	content string
+	err     string
+}
+
+var loadTests []loadTest = []loadTest{
+	loadTest{"", "No node found"},
+	loadTest{"way\t1\n", "line 1 : unknown record way"},
+	loadTest{"node\ta\t53\n", "line 1 : expected node, id, latitude and longitude"},
+	loadTest{"node\ta\t53\t-6\nnode\ta\t53\t-6\n", "line 2 : duplicate node a"},
+	loadTest{"node\ta\t93\t-6\n", "line 1 : Invalid latitude 93, expected a value between -90 and 90"},
+	loadTest{"node\ta\t53\t-6\nedge\ta\tb\t50\n", "line 2 : unknown node b"},
+	loadTest{"node\ta\t53\t-6\nnode\tb\t53.1\t-6\nedge\ta\tb\t0\n", "line 3 : invalid speed 0"},
+	loadTest{"node\ta\t53\t-6\nnode\tb\t53.1\t-6\nedge\ta\tb\t50\t-1\n", "line 3 : invalid length -1"},
+	loadTest{"node\ta\t53\t-6\nnode\tb\t53.1\t-6\nedge\ta\tb\t50\t12\tboth\n", "line 3 : invalid direction both"},
+}
+
+func TestLoad(t *testing.T) {
+	for _, test := range loadTests {
+		if _, err := Load(strings.NewReader(test.content)); err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("Output %v for %q, expected %v", err, test.content, test.err)
+		}
+	}
+	if g := loadSample(t); g.Len() != 13 {
+		t.Errorf("Output %v nodes, expected 13", g.Len())
+	}
+	//a length shorter than the great circle between the nodes is taken as the great circle
+	g, err := Load(strings.NewReader("node\ta\t53\t-6\nnode\tb\t53.1\t-6\nedge\ta\tb\t50\t1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	straight := greatCircle.Distance(degreePoint(53, -6), degreePoint(53.1, -6), greatCircle.Radius)
+	if r, found := g.Route(degreePoint(53, -6), degreePoint(53.1, -6), MetricDistance); !found || math.Abs(r.Distance-straight) > 1e-9 {
+		t.Errorf("Output %v, expected the great circle %v", r, straight)
+	}
+}
+
+func TestSnap(t *testing.T) {
+	//nodes every degree of latitude and 0.5 degree of longitude, across the antimeridian and up to the pole, checked
+	//against a linear scan
+	var content strings.Builder
+	for latitude := 60; latitude < 90; latitude++ {
+		for longitude := -20; longitude <= 20; longitude++ {
+			degrees := 180 + float64(longitude)/2
+			if degrees > 180 {
+				degrees -= 360
+			}
+			content.WriteString("node\t" + strconv.Itoa(latitude) + "_" + strconv.Itoa(longitude) + "\t" + strconv.Itoa(latitude) +
+				"\t" + strconv.FormatFloat(degrees, 'f', -1, 64) + "\n")
+		}
+	}
+	content.WriteString("node\tpole\t90\t0\n")
+	g, err := Load(strings.NewReader(content.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rand.Seed(1)
+	for i := 0; i < 1000; i++ {
+		p := degreePoint(rand.Float64()*180-90, rand.Float64()*360-180)
+		if i%2 == 0 {
+			//close to the nodes
+			p = degreePoint(55+rand.Float64()*35, 165+rand.Float64()*30)
+		}
+		nearest, distance := g.snap(p)
+		expected := math.Inf(1)
+		for _, node := range g.nodes {
+			expected = math.Min(expected, greatCircle.Distance(p, node, greatCircle.Radius))
+		}
+		if math.Abs(distance-expected) > 1e-9 || math.Abs(greatCircle.Distance(p, g.nodes[nearest], greatCircle.Radius)-expected) > 1e-9 {
+			t.Fatalf("Output node %v at %v km for %v, expected %v km", nearest, distance, p, expected)
+		}
+	}
+}
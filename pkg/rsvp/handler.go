@@ -0,0 +1,61 @@
+package rsvp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Return a handler for GET requests on prefix/{token}, which records the answer given in the "response" query
+// parameter (accept or decline) and answers the invite. Without a response the invite is answered unchanged
+func (s *Store) RespondHandler(prefix string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if http.MethodGet != r.Method {
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET request")
+		}
+		token := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if !ValidToken(token) {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: ErrNotFound}
+		}
+		var invite Invite
+		var err error
+		if answer := r.URL.Query().Get("response"); answer == "" {
+			invite, err = s.Get(token)
+		} else {
+			invite, err = s.Respond(token, answer)
+		}
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+		case errors.Is(err, ErrInvalidResponse):
+			return &util.HTTPError{Status: http.StatusBadRequest, Err: err}
+		case err != nil:
+			return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+		}
+		return util.WriteJSON(w, http.StatusOK, invite)
+	}
+}
+
+// Return a handler for GET requests on prefix (answer counts of every event) and prefix/{event} (answers of the
+// event with its invites)
+func (s *Store) AdminHandler(prefix string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if http.MethodGet != r.Method {
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET request")
+		}
+		event := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if event == "" {
+			return util.WriteJSON(w, http.StatusOK, s.Events())
+		}
+		if !ValidEvent(event) {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: ErrNotFound}
+		}
+		summary, err := s.Event(event)
+		if err != nil {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+		}
+		return util.WriteJSON(w, http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,261 @@
+// Package rsvp issues a token per invited customer and event and records the answers given with it
+package rsvp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Answer of an invited customer
+type Response string
+
+const (
+	ResponsePending  Response = "pending"
+	ResponseAccepted Response = "accepted"
+	ResponseDeclined Response = "declined"
+)
+
+var (
+	ErrNotFound        = errors.New("RSVP not found")
+	ErrInvalidEvent    = errors.New("Invalid event name, expected letters, digits, '_', '.' or '-'")
+	ErrInvalidResponse = errors.New("Invalid response, expected accept or decline")
+)
+
+// Tokens are hex strings, long enough not to be guessed
+var tokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Event names are used in query parameters and URLs
+var eventPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// The invitation of a customer to an event and its answer
+type Invite struct {
+	Token       string     `json:"token"`
+	Event       string     `json:"event"`
+	User_id     int        `json:"user_id"`
+	Name        string     `json:"name"`
+	Response    Response   `json:"response"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// The answers of an event
+type Summary struct {
+	Event   string           `json:"event"`
+	Counts  map[Response]int `json:"counts"`
+	Invites []Invite         `json:"invites,omitempty"`
+}
+
+// Keeps the invites in memory and persists all of them in a JSON file after every change
+type Store struct {
+	path    string
+	mu      sync.Mutex
+	invites map[string]*Invite
+	// token of the invite of a user to an event, by event then user id
+	byUser map[string]map[int]string
+}
+
+// Open the store persisted at path, which is created on the first change
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, invites: make(map[string]*Invite), byUser: make(map[string]map[int]string)}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var invites []*Invite
+	if err := json.Unmarshal(b, &invites); err != nil {
+		return nil, errors.New("Cannot load RSVPs " + path + " : " + err.Error())
+	}
+	for _, invite := range invites {
+		s.add(invite)
+	}
+	return s, nil
+}
+
+// Index an invite
+func (s *Store) add(invite *Invite) {
+	s.invites[invite.Token] = invite
+	if s.byUser[invite.Event] == nil {
+		s.byUser[invite.Event] = make(map[int]string)
+	}
+	s.byUser[invite.Event][invite.User_id] = invite.Token
+}
+
+// A customer to invite to an event
+type Invitee struct {
+	User_id int
+	Name    string
+}
+
+// Return the invite of a customer to an event, issuing a new token the first time. Inviting the same customer to the
+// same event again returns the same token, so an answer already given is kept
+func (s *Store) Issue(event string, userID int, name string) (Invite, error) {
+	invites, err := s.IssueAll(event, []Invitee{{userID, name}})
+	if err != nil {
+		return Invite{}, err
+	}
+	return invites[0], nil
+}
+
+// Return the invites of customers to an event, in the order of the customers, as Issue does for each of them. The
+// store is saved once for all of them, and none of the new tokens is kept if saving fails
+func (s *Store) IssueAll(event string, invitees []Invitee) ([]Invite, error) {
+	if !ValidEvent(event) {
+		return nil, ErrInvalidEvent
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invites := make([]Invite, 0, len(invitees))
+	var issued []*Invite
+	rollback := func() {
+		for _, invite := range issued {
+			delete(s.invites, invite.Token)
+			delete(s.byUser[event], invite.User_id)
+		}
+		if len(s.byUser[event]) == 0 {
+			delete(s.byUser, event)
+		}
+	}
+	for _, invitee := range invitees {
+		if token, found := s.byUser[event][invitee.User_id]; found {
+			invites = append(invites, *s.invites[token])
+			continue
+		}
+		token, err := util.NewID()
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		invite := &Invite{Token: token, Event: event, User_id: invitee.User_id, Name: invitee.Name, Response: ResponsePending, CreatedAt: time.Now().UTC()}
+		s.add(invite)
+		issued = append(issued, invite)
+		invites = append(invites, *invite)
+	}
+	if len(issued) == 0 {
+		return invites, nil
+	}
+	if err := s.save(); err != nil {
+		rollback()
+		return nil, err
+	}
+	return invites, nil
+}
+
+// Record the answer ("accept" or "decline") given with a token. A customer can change their answer
+func (s *Store) Respond(token string, answer string) (Invite, error) {
+	var response Response
+	switch answer {
+	case "accept":
+		response = ResponseAccepted
+	case "decline":
+		response = ResponseDeclined
+	default:
+		return Invite{}, ErrInvalidResponse
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invite, found := s.invites[token]
+	if !found {
+		return Invite{}, ErrNotFound
+	}
+	previous := *invite
+	now := time.Now().UTC()
+	invite.Response, invite.RespondedAt = response, &now
+	if err := s.save(); err != nil {
+		*invite = previous
+		return Invite{}, err
+	}
+	logger.Info(context.Background(), "RSVP recorded", "event", invite.Event, "user_id", invite.User_id, "response", response)
+	return *invite, nil
+}
+
+// Return an invite
+func (s *Store) Get(token string) (Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invite, found := s.invites[token]
+	if !found {
+		return Invite{}, ErrNotFound
+	}
+	return *invite, nil
+}
+
+// Return the answers of an event with its invites sorted by user id
+func (s *Store) Event(event string) (Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, found := s.byUser[event]
+	if !found {
+		return Summary{}, ErrNotFound
+	}
+	summary := newSummary(event)
+	for _, token := range users {
+		invite := s.invites[token]
+		summary.Counts[invite.Response]++
+		summary.Invites = append(summary.Invites, *invite)
+	}
+	sort.Slice(summary.Invites, func(a, b int) bool { return summary.Invites[a].User_id < summary.Invites[b].User_id })
+	return summary, nil
+}
+
+// Return the counts of the answers of every event, sorted by event name
+func (s *Store) Events() []Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summaries := []Summary{}
+	for event, users := range s.byUser {
+		summary := newSummary(event)
+		for _, token := range users {
+			summary.Counts[s.invites[token].Response]++
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(a, b int) bool { return summaries[a].Event < summaries[b].Event })
+	return summaries
+}
+
+// Readiness check reporting whether the store file can be written
+func (s *Store) CheckStore() error {
+	return util.CheckWritable(filepath.Dir(s.path))
+}
+
+// Create a summary with all the counts at 0
+func newSummary(event string) Summary {
+	return Summary{Event: event, Counts: map[Response]int{ResponsePending: 0, ResponseAccepted: 0, ResponseDeclined: 0}}
+}
+
+// Persist every invite through a temporary file, so a crash never leaves a partial file behind. Called with s.mu held
+func (s *Store) save() error {
+	invites := make([]*Invite, 0, len(s.invites))
+	for _, invite := range s.invites {
+		invites = append(invites, invite)
+	}
+	sort.Slice(invites, func(a, b int) bool { return invites[a].Token < invites[b].Token })
+	b, err := json.Marshal(invites)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(s.path, b)
+}
+
+// Check that token has the shape of a token
+func ValidToken(token string) bool {
+	return tokenPattern.MatchString(token)
+}
+
+// Check that event is a valid event name
+func ValidEvent(event string) bool {
+	return eventPattern.MatchString(event)
+}
@@ -0,0 +1,188 @@
+package rsvp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestIssue(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "rsvps.json"))
+	first, err := s.Issue("party", 1, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ValidToken(first.Token) || first.Response != ResponsePending || first.User_id != 1 {
+		t.Errorf("Output %v, expected a pending invite of user 1", first)
+	}
+	//inviting the same customer again keeps the token
+	if again, _ := s.Issue("party", 1, "user1"); again.Token != first.Token {
+		t.Errorf("Output token %v, expected %v", again.Token, first.Token)
+	}
+	//another event gets another token
+	if other, _ := s.Issue("dinner", 1, "user1"); other.Token == first.Token {
+		t.Errorf("Output token %v, expected a new token for another event", other.Token)
+	}
+	if _, err := s.Issue("a party", 1, "user1"); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("Output error %v, expected %v", err, ErrInvalidEvent)
+	}
+}
+
+func TestIssueAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rsvps.json")
+	s := openTestStore(t, path)
+	first, err := s.Issue("party", 2, "user2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invites, err := s.IssueAll("party", []Invitee{{1, "user1"}, {2, "user2"}, {3, "user3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 3 || invites[0].User_id != 1 || invites[1].Token != first.Token || invites[2].User_id != 3 {
+		t.Errorf("Output %v, expected the invites of users 1, 2 (kept) and 3", invites)
+	}
+	//the batch is persisted
+	if summary, err := openTestStore(t, path).Event("party"); err != nil || summary.Counts[ResponsePending] != 3 {
+		t.Errorf("Output %v, %v, expected 3 pending invites", summary, err)
+	}
+
+	//none of the batch is kept when the store cannot be saved
+	broken := openTestStore(t, filepath.Join(t.TempDir(), "missing", "rsvps.json"))
+	if _, err := broken.IssueAll("party", []Invitee{{1, "user1"}, {2, "user2"}}); err == nil {
+		t.Fatal("Expected an error saving in a missing directory")
+	}
+	if summaries := broken.Events(); len(summaries) != 0 {
+		t.Errorf("Output %v, expected no invite", summaries)
+	}
+	if _, err := broken.IssueAll("a party", nil); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("Output error %v, expected %v", err, ErrInvalidEvent)
+	}
+}
+
+type respondTest struct {
+	answer   string
+	response Response
+	err      error
+}
+
+var respondTests []respondTest = []respondTest{
+	respondTest{"accept", ResponseAccepted, nil},
+	respondTest{"decline", ResponseDeclined, nil},
+	respondTest{"maybe", ResponseDeclined, ErrInvalidResponse},
+	respondTest{"accept", ResponseAccepted, nil},
+}
+
+func TestRespond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rsvps.json")
+	s := openTestStore(t, path)
+	invite, _ := s.Issue("party", 1, "user1")
+	for _, test := range respondTests {
+		_, err := s.Respond(invite.Token, test.answer)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Output error %v for %v, expected %v", err, test.answer, test.err)
+		}
+		if got, _ := s.Get(invite.Token); got.Response != test.response || got.RespondedAt == nil {
+			t.Errorf("Output %v for %v, expected %v", got.Response, test.answer, test.response)
+		}
+	}
+	if _, err := s.Respond("0123456789abcdef0123456789abcdef", "accept"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Output error %v, expected %v", err, ErrNotFound)
+	}
+
+	//the answers survive a restart
+	reopened := openTestStore(t, path)
+	if got, err := reopened.Get(invite.Token); err != nil || got.Response != ResponseAccepted {
+		t.Errorf("Output %v %v after reopening, expected %v", got.Response, err, ResponseAccepted)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "rsvps.json"))
+	for id := 3; id > 0; id-- {
+		invite, _ := s.Issue("party", id, "user")
+		if id == 2 {
+			s.Respond(invite.Token, "decline")
+		}
+	}
+	s.Issue("dinner", 1, "user")
+
+	summary, err := s.Event("party")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Invites) != 3 || summary.Invites[0].User_id != 1 || summary.Counts[ResponsePending] != 2 || summary.Counts[ResponseDeclined] != 1 {
+		t.Errorf("Output %v, expected the 3 invites of party sorted by user id", summary)
+	}
+	if _, err := s.Event("lunch"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Output error %v, expected %v", err, ErrNotFound)
+	}
+	events := s.Events()
+	if len(events) != 2 || events[0].Event != "dinner" || events[1].Counts[ResponseAccepted] != 0 || events[1].Invites != nil {
+		t.Errorf("Output %v, expected the counts of dinner and party", events)
+	}
+}
+
+type handlerTest struct {
+	method string
+	target string
+	status int
+}
+
+func TestHandlers(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "rsvps.json"))
+	invite, _ := s.Issue("party", 1, "user1")
+	respond := util.ErrorHandler(s.RespondHandler("/rsvp/"))
+	admin := util.ErrorHandler(s.AdminHandler("/v2/rsvps"))
+
+	for _, test := range []handlerTest{
+		handlerTest{"GET", "/rsvp/" + invite.Token, http.StatusOK},
+		handlerTest{"GET", "/rsvp/" + invite.Token + "?response=accept", http.StatusOK},
+		handlerTest{"GET", "/rsvp/" + invite.Token + "?response=maybe", http.StatusBadRequest},
+		handlerTest{"GET", "/rsvp/0123456789abcdef0123456789abcdef?response=accept", http.StatusNotFound},
+		handlerTest{"GET", "/rsvp/../v2/rsvps", http.StatusNotFound},
+		handlerTest{"POST", "/rsvp/" + invite.Token, http.StatusMethodNotAllowed},
+	} {
+		writer := httptest.NewRecorder()
+		respond(writer, httptest.NewRequest(test.method, test.target, nil))
+		if writer.Code != test.status {
+			t.Errorf("Output %v for %v %v, expected %v", writer.Code, test.method, test.target, test.status)
+		}
+	}
+
+	for _, test := range []handlerTest{
+		handlerTest{"GET", "/v2/rsvps", http.StatusOK},
+		handlerTest{"GET", "/v2/rsvps/party", http.StatusOK},
+		handlerTest{"GET", "/v2/rsvps/lunch", http.StatusNotFound},
+		handlerTest{"DELETE", "/v2/rsvps/party", http.StatusMethodNotAllowed},
+	} {
+		writer := httptest.NewRecorder()
+		admin(writer, httptest.NewRequest(test.method, test.target, nil))
+		if writer.Code != test.status {
+			t.Errorf("Output %v for %v %v, expected %v", writer.Code, test.method, test.target, test.status)
+		}
+	}
+
+	writer := httptest.NewRecorder()
+	admin(writer, httptest.NewRequest("GET", "/v2/rsvps/party", nil))
+	var summary Summary
+	if err := json.Unmarshal(writer.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Invites) != 1 || summary.Invites[0].Response != ResponseAccepted || summary.Counts[ResponseAccepted] != 1 {
+		t.Errorf("Output %v, expected the accepted invite of user 1", summary)
+	}
+}
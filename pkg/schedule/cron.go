@@ -0,0 +1,167 @@
+package schedule
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shortcuts for common expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Range and names of a field of an expression
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var cronFields = []cronField{
+	cronField{"minute", 0, 59, nil},
+	cronField{"hour", 0, 23, nil},
+	cronField{"day of month", 1, 31, nil},
+	cronField{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	cronField{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// A parsed cron expression: "minute hour day-of-month month day-of-week", each field being *, a value, a range
+// (1-5), a step (*/15 or 1-30/10) or a list of those (1,15). Months and days of week accept their English
+// three letter names. As with cron, when both day fields are restricted a day matching either of them matches
+type Cron struct {
+	expr    string
+	fields  [5]uint64
+	domStar bool
+	dowStar bool
+}
+
+// Parse a cron expression, or one of @yearly, @monthly, @weekly, @daily and @hourly
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, found := cronMacros[strings.ToLower(spec)]; found {
+		spec = macro
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, errors.New("Invalid cron expression " + expr + ", expected 5 fields (minute hour day-of-month month day-of-week)")
+	}
+	c := &Cron{expr: expr, domStar: parts[2] == "*", dowStar: parts[4] == "*"}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, errors.New("Invalid cron expression " + expr + " : " + err.Error())
+		}
+		c.fields[i] = bits
+	}
+	//Sunday is both 0 and 7
+	if c.fields[4]&(1<<7) != 0 {
+		c.fields[4] |= 1
+	}
+	return c, nil
+}
+
+// Return the expression the Cron was parsed from
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Parse one field into the set of its values
+func parseCronField(s string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangePart = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, errors.New("invalid step " + item + " in the " + field.name + " field")
+			}
+			step = n
+		}
+
+		low, high := field.min, field.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseCronValue(bounds[1], field); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				//"5/15" means from 5 to the end by 15
+				high = field.max
+			}
+			if low > high {
+				return 0, errors.New("invalid range " + rangePart + " in the " + field.name + " field")
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Parse a value (number or name) of a field and check its range
+func parseCronValue(s string, field cronField) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(s, name) {
+			return i + field.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < field.min || v > field.max {
+		return 0, errors.New("invalid value " + s + " in the " + field.name + " field, expected " +
+			strconv.Itoa(field.min) + "-" + strconv.Itoa(field.max))
+	}
+	return v, nil
+}
+
+// Check that the value v is in the field i
+func (c *Cron) has(i int, v int) bool {
+	return c.fields[i]&(1<<uint(v)) != 0
+}
+
+// Check that the day of t matches the day of month and day of week fields
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom, dow := c.has(2, t.Day()), c.has(4, int(t.Weekday()))
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Return the first time strictly after t matching the expression, in the location of t. Returns the zero time
+// if there is none in the next 5 years (e.g. "0 0 30 2 *")
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.has(3, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.has(1, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.has(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
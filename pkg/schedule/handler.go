@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Return a handler for GET requests on prefix (summaries of the runs, optionally of the schedule given in the
+// "schedule" query parameter), prefix/{id} (run with its invitees) and prefix/diff?from={id}&to={id} (invitees
+// added and removed between two runs, from defaults to the previous successful run of the same schedule)
+func (s *Scheduler) Handler(prefix string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if http.MethodGet != r.Method {
+			return util.NewHTTPError(http.StatusMethodNotAllowed, "HTTP request is not a GET request")
+		}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		switch {
+		case path == "":
+			return util.WriteJSON(w, http.StatusOK, s.Runs(r.URL.Query().Get("schedule")))
+		case path == "diff":
+			return s.serveDiff(w, r)
+		case !ValidID(path):
+			return &util.HTTPError{Status: http.StatusNotFound, Err: ErrNotFound}
+		}
+		run, err := s.Get(path)
+		if errors.Is(err, ErrNotFound) {
+			return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+		}
+		if err != nil {
+			return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+		}
+		return util.WriteJSON(w, http.StatusOK, run)
+	}
+}
+
+// Answer the diff between the runs given in the "from" and "to" query parameters
+func (s *Scheduler) serveDiff(w http.ResponseWriter, r *http.Request) error {
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if !ValidID(to) || (from != "" && !ValidID(from)) {
+		return util.NewHTTPError(http.StatusBadRequest, "Expected the run IDs in the \"to\" and optionally \"from\" query parameters")
+	}
+	var err error
+	if from == "" {
+		from, err = s.Previous(to)
+	}
+	var d Diff
+	if err == nil {
+		d, err = s.Diff(from, to)
+	}
+	switch {
+	case errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoPreviousRun):
+		return &util.HTTPError{Status: http.StatusNotFound, Err: err}
+	case errors.Is(err, ErrNotDone):
+		return &util.HTTPError{Status: http.StatusConflict, Err: err}
+	case err != nil:
+		return &util.HTTPError{Status: http.StatusInternalServerError, Err: err}
+	}
+	return util.WriteJSON(w, http.StatusOK, d)
+}
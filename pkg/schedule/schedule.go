@@ -0,0 +1,358 @@
+// Package schedule runs the invite calculation on cron schedules and keeps a snapshot of every run so runs can be compared
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// State of a run
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+var (
+	ErrNotFound         = errors.New("Run not found")
+	ErrNotDone          = errors.New("Run has not succeeded")
+	ErrScheduleNotFound = errors.New("Schedule not found")
+	ErrNoPreviousRun    = errors.New("No previous successful run of the schedule")
+)
+
+// Run IDs are hex strings, which also keeps them safe to use as file names
+var idPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Schedule names are used in query parameters and logs
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// A schedule as written in the configuration file
+type Config struct {
+	// Unique name of the schedule
+	Name string `json:"name"`
+	// Cron expression, in the local time of the server
+	Cron string `json:"cron"`
+	// file:// or http(s):// URL of the customer file
+	Source string `json:"source"`
+	// Duplicate policy of the runs, the default policy if empty
+	Duplicates string `json:"duplicates,omitempty"`
+	// Range of the runs (maximum distance in unit, unit and earth radius), the default range if empty
+	MaxDistance *float64 `json:"max_distance,omitempty"`
+	Unit        string   `json:"unit,omitempty"`
+	EarthRadius string   `json:"earth_radius,omitempty"`
+}
+
+// A customer invited by a run
+type Invitee struct {
+	User_id int    `json:"User_id"`
+	Name    string `json:"Name"`
+}
+
+// A run of a schedule, persisted with its invitees as <id>.json in the run directory
+type Run struct {
+	ID           string    `json:"id"`
+	Schedule     string    `json:"schedule"`
+	Source       string    `json:"source"`
+	Status       Status    `json:"status"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Error        string    `json:"error,omitempty"`
+	InviteeCount int       `json:"invitee_count"`
+	Invitees     []Invitee `json:"invitees,omitempty"`
+}
+
+// The invitees added and removed between two runs
+type Diff struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Added     []Invitee `json:"added"`
+	Removed   []Invitee `json:"removed"`
+	Unchanged int       `json:"unchanged"`
+}
+
+// Compute the invitees of a schedule
+type Runner func(ctx context.Context, config Config) ([]Invitee, error)
+
+// A configured schedule and its parsed expression
+type entry struct {
+	config Config
+	cron   *Cron
+}
+
+// Runs the schedules and stores their runs in a directory. Only the run summaries are kept in memory, the
+// invitees are read from disk when needed
+type Scheduler struct {
+	dir       string
+	schedules []entry
+	run       Runner
+	now       func() time.Time
+	after     func(d time.Duration) <-chan time.Time
+	mu        sync.Mutex
+	runs      map[string]*Run
+}
+
+// Validate the schedules and create a scheduler storing its runs in dir. Runs persisted by a previous start are
+// loaded, the ones interrupted by a stop are marked failed
+func NewScheduler(dir string, configs []Config, run Runner) (*Scheduler, error) {
+	s := &Scheduler{dir: dir, run: run, now: time.Now, after: time.After, runs: make(map[string]*Run)}
+	names := make(map[string]bool)
+	for _, config := range configs {
+		if !namePattern.MatchString(config.Name) {
+			return nil, errors.New("Invalid schedule name \"" + config.Name + "\", expected letters, digits, '_', '.' or '-'")
+		}
+		if names[config.Name] {
+			return nil, errors.New("Duplicate schedule name " + config.Name)
+		}
+		names[config.Name] = true
+		if config.Source == "" {
+			return nil, errors.New("Missing source in schedule " + config.Name)
+		}
+		cron, err := ParseCron(config.Cron)
+		if err != nil {
+			return nil, errors.New("Schedule " + config.Name + " : " + err.Error())
+		}
+		s.schedules = append(s.schedules, entry{config, cron})
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load the summaries of the persisted runs
+func (s *Scheduler) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var r Run
+		if err := json.Unmarshal(b, &r); err != nil {
+			return errors.New("Cannot load run " + path + " : " + err.Error())
+		}
+		if !idPattern.MatchString(r.ID) {
+			continue
+		}
+		if r.Status == StatusRunning {
+			r.Status, r.Error = StatusFailed, "Interrupted by a restart"
+			if err := s.save(&r); err != nil {
+				return err
+			}
+		}
+		r.Invitees = nil
+		s.runs[r.ID] = &r
+	}
+	return nil
+}
+
+// Start one goroutine per schedule, running it at every time matching its expression until ctx is cancelled.
+// A run which lasts past the next matching time delays the following run rather than overlapping with it
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, e := range s.schedules {
+		go s.loop(ctx, e)
+	}
+}
+
+// Wait for the next time matching the schedule and run it, until ctx is cancelled
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	for {
+		now := s.now()
+		next := e.cron.Next(now)
+		if next.IsZero() {
+			logger.Warn(ctx, "Schedule never matches", "schedule", e.config.Name, "cron", e.config.Cron)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.after(next.Sub(now)):
+			s.RunNow(ctx, e.config.Name)
+		}
+	}
+}
+
+// Run a schedule now and return its run once finished
+func (s *Scheduler) RunNow(ctx context.Context, name string) (Run, error) {
+	var config *Config
+	for i := range s.schedules {
+		if s.schedules[i].config.Name == name {
+			config = &s.schedules[i].config
+		}
+	}
+	if config == nil {
+		return Run{}, ErrScheduleNotFound
+	}
+	id, err := util.NewID()
+	if err != nil {
+		return Run{}, err
+	}
+	r := &Run{ID: id, Schedule: name, Source: config.Source, Status: StatusRunning, StartedAt: s.now().UTC()}
+	ctx = logger.WithRequestID(ctx, id)
+	if err := s.store(r); err != nil {
+		return Run{}, err
+	}
+
+	invitees, err := s.run(ctx, *config)
+	r = &Run{ID: r.ID, Schedule: r.Schedule, Source: r.Source, StartedAt: r.StartedAt, FinishedAt: s.now().UTC()}
+	if err != nil {
+		r.Status, r.Error = StatusFailed, err.Error()
+	} else {
+		r.Status, r.InviteeCount, r.Invitees = StatusDone, len(invitees), invitees
+		sort.Slice(r.Invitees, func(a, b int) bool { return r.Invitees[a].User_id < r.Invitees[b].User_id })
+	}
+	if err := s.store(r); err != nil {
+		logger.Error(ctx, "Cannot persist run", "run_id", id, "error", err)
+		return Run{}, err
+	}
+	logger.Info(ctx, "Scheduled run finished", "schedule", name, "run_id", id, "invited", len(invitees), "error", err)
+	return *r, nil
+}
+
+// Persist a run and keep its summary
+func (s *Scheduler) store(r *Run) error {
+	if err := s.save(r); err != nil {
+		return err
+	}
+	summary := *r
+	summary.Invitees = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[r.ID] = &summary
+	return nil
+}
+
+// Return the summaries of the runs, oldest first, restricted to a schedule unless name is empty
+func (s *Scheduler) Runs(name string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := []Run{}
+	for _, r := range s.runs {
+		if name == "" || r.Schedule == name {
+			runs = append(runs, *r)
+		}
+	}
+	sort.Slice(runs, func(a, b int) bool { return runs[a].StartedAt.Before(runs[b].StartedAt) })
+	return runs
+}
+
+// Return a run with its invitees
+func (s *Scheduler) Get(id string) (Run, error) {
+	s.mu.Lock()
+	_, found := s.runs[id]
+	s.mu.Unlock()
+	if !found {
+		return Run{}, ErrNotFound
+	}
+	b, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return Run{}, err
+	}
+	var r Run
+	if err := json.Unmarshal(b, &r); err != nil {
+		return Run{}, err
+	}
+	if r.Invitees == nil {
+		r.Invitees = []Invitee{}
+	}
+	return r, nil
+}
+
+// Return the last successful run of the same schedule started before the run id
+func (s *Scheduler) Previous(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, found := s.runs[id]
+	if !found {
+		return "", ErrNotFound
+	}
+	var previous *Run
+	for _, other := range s.runs {
+		if other.Schedule == r.Schedule && other.Status == StatusDone && other.StartedAt.Before(r.StartedAt) &&
+			(previous == nil || other.StartedAt.After(previous.StartedAt)) {
+			previous = other
+		}
+	}
+	if previous == nil {
+		return "", ErrNoPreviousRun
+	}
+	return previous.ID, nil
+}
+
+// Return the invitees added and removed from the run from to the run to. Both runs must have succeeded
+func (s *Scheduler) Diff(from string, to string) (Diff, error) {
+	fromRun, err := s.Get(from)
+	if err != nil {
+		return Diff{}, err
+	}
+	toRun, err := s.Get(to)
+	if err != nil {
+		return Diff{}, err
+	}
+	if fromRun.Status != StatusDone || toRun.Status != StatusDone {
+		return Diff{}, ErrNotDone
+	}
+
+	d := Diff{From: from, To: to, Added: []Invitee{}, Removed: []Invitee{}}
+	before := make(map[int]bool, len(fromRun.Invitees))
+	for _, invitee := range fromRun.Invitees {
+		before[invitee.User_id] = true
+	}
+	after := make(map[int]bool, len(toRun.Invitees))
+	for _, invitee := range toRun.Invitees {
+		after[invitee.User_id] = true
+		if before[invitee.User_id] {
+			d.Unchanged++
+		} else {
+			d.Added = append(d.Added, invitee)
+		}
+	}
+	for _, invitee := range fromRun.Invitees {
+		if !after[invitee.User_id] {
+			d.Removed = append(d.Removed, invitee)
+		}
+	}
+	return d, nil
+}
+
+// Readiness check reporting whether the run directory can be written
+func (s *Scheduler) CheckStore() error {
+	return util.CheckWritable(s.dir)
+}
+
+// Persist a run through a temporary file, so a crash never leaves a partial file behind
+func (s *Scheduler) save(r *Run) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(s.path(r.ID), b)
+}
+
+// Return the path of the file of a run
+func (s *Scheduler) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Check that id has the shape of a run ID
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
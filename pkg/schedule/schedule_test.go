@@ -0,0 +1,256 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+type cronTest struct {
+	expr     string
+	from     string
+	expected string
+}
+
+var cronTests []cronTest = []cronTest{
+	cronTest{"* * * * *", "2024-01-01T10:00:30Z", "2024-01-01T10:01:00Z"},
+	cronTest{"0 9 * * 1", "2024-01-01T09:00:00Z", "2024-01-08T09:00:00Z"},
+	cronTest{"0 9 * * mon", "2024-01-01T08:59:00Z", "2024-01-01T09:00:00Z"},
+	cronTest{"*/15 * * * *", "2024-01-01T10:16:00Z", "2024-01-01T10:30:00Z"},
+	cronTest{"30 8-10/2 * * *", "2024-01-01T09:00:00Z", "2024-01-01T10:30:00Z"},
+	cronTest{"0 0 1,15 * *", "2024-01-02T00:00:00Z", "2024-01-15T00:00:00Z"},
+	cronTest{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+	cronTest{"0 0 31 * *", "2024-04-01T00:00:00Z", "2024-05-31T00:00:00Z"},
+	cronTest{"0 12 * dec 7", "2024-01-01T00:00:00Z", "2024-12-01T12:00:00Z"},
+	//both day fields restricted: the 13th or a Friday
+	cronTest{"0 0 13 * 5", "2024-09-01T00:00:00Z", "2024-09-06T00:00:00Z"},
+	cronTest{"@daily", "2024-12-31T23:59:00Z", "2025-01-01T00:00:00Z"},
+	cronTest{"@weekly", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},
+	cronTest{"0 0 30 2 *", "2024-01-01T00:00:00Z", "0001-01-01T00:00:00Z"},
+}
+
+func TestCronNext(t *testing.T) {
+	for _, test := range cronTests {
+		cron, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, _ := time.Parse(time.RFC3339, test.from)
+		expected, _ := time.Parse(time.RFC3339, test.expected)
+		if next := cron.Next(from); !next.Equal(expected) {
+			t.Errorf("Output %v for %v from %v not equal to expected %v", next, test.expr, test.from, expected)
+		}
+	}
+}
+
+var invalidCronTests []string = []string{
+	"",
+	"* * * *",
+	"* * * * * *",
+	"60 * * * *",
+	"* 24 * * *",
+	"* * 0 * *",
+	"* * * 13 *",
+	"* * * * 8",
+	"*/0 * * * *",
+	"10-5 * * * *",
+	"a * * * *",
+	"@sometimes",
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range invalidCronTests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected an error for the cron expression %v", expr)
+		}
+	}
+}
+
+// A runner returning the invitees set by the test, or failing on the "broken" source
+type fakeRunner struct {
+	invitees []Invitee
+}
+
+func (f *fakeRunner) run(ctx context.Context, config Config) ([]Invitee, error) {
+	if config.Source == "broken" {
+		return nil, errors.New("Cannot fetch broken")
+	}
+	return append([]Invitee{}, f.invitees...), nil
+}
+
+func TestScheduler(t *testing.T) {
+	dir := t.TempDir()
+	runner := &fakeRunner{}
+	configs := []Config{Config{Name: "weekly", Cron: "0 9 * * 1", Source: "file:///data/customers.txt", Duplicates: ""}, Config{Name: "broken", Cron: "@daily", Source: "broken", Duplicates: ""}}
+	s, err := NewScheduler(dir, configs, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner.invitees = []Invitee{Invitee{3, "c"}, Invitee{1, "a"}, Invitee{2, "b"}}
+	first, err := s.RunNow(context.Background(), "weekly")
+	if err != nil || first.Status != StatusDone || first.InviteeCount != 3 {
+		t.Fatalf("Output run %v (%v), expected a successful run with 3 invitees", first, err)
+	}
+	runner.invitees = []Invitee{Invitee{2, "b"}, Invitee{4, "d"}, Invitee{3, "c"}}
+	second, _ := s.RunNow(context.Background(), "weekly")
+	failed, _ := s.RunNow(context.Background(), "broken")
+	if failed.Status != StatusFailed || failed.Error != "Cannot fetch broken" {
+		t.Errorf("Output run %v, expected a failed run", failed)
+	}
+	if _, err := s.RunNow(context.Background(), "missing"); err != ErrScheduleNotFound {
+		t.Errorf("Output error %v not equal to expected %v", err, ErrScheduleNotFound)
+	}
+
+	expected := Diff{first.ID, second.ID, []Invitee{Invitee{4, "d"}}, []Invitee{Invitee{1, "a"}}, 2}
+	if d, err := s.Diff(first.ID, second.ID); err != nil || !reflect.DeepEqual(d, expected) {
+		t.Errorf("Output diff %v (%v) not equal to expected %v", d, err, expected)
+	}
+	if previous, err := s.Previous(second.ID); err != nil || previous != first.ID {
+		t.Errorf("Output previous run %v (%v) not equal to expected %v", previous, err, first.ID)
+	}
+	if _, err := s.Previous(first.ID); err != ErrNoPreviousRun {
+		t.Errorf("Output error %v not equal to expected %v", err, ErrNoPreviousRun)
+	}
+	if _, err := s.Diff(first.ID, failed.ID); err != ErrNotDone {
+		t.Errorf("Output error %v not equal to expected %v", err, ErrNotDone)
+	}
+
+	//the runs survive a restart, the interrupted ones are marked failed
+	interrupted := Run{ID: strings.Repeat("0", 32), Schedule: "weekly", Status: StatusRunning}
+	if err := s.save(&interrupted); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := NewScheduler(dir, configs, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := restarted.Runs("weekly"); len(runs) != 3 || runs[0].ID != interrupted.ID || runs[0].Status != StatusFailed || runs[2].ID != second.ID {
+		t.Errorf("Output runs %v, expected the interrupted run and the 2 weekly runs", runs)
+	}
+	if r, err := restarted.Get(first.ID); err != nil || len(r.Invitees) != 3 || r.Invitees[0].User_id != 1 {
+		t.Errorf("Output run %v (%v), expected the 3 invitees sorted by user id", r, err)
+	}
+	if err := restarted.CheckStore(); err != nil {
+		t.Errorf("Output error %v, expected the run directory to be writable", err)
+	}
+}
+
+type newSchedulerTest struct {
+	config Config
+	valid  bool
+}
+
+var newSchedulerTests []newSchedulerTest = []newSchedulerTest{
+	newSchedulerTest{Config{Name: "weekly", Cron: "0 9 * * 1", Source: "file:///data/customers.txt", Duplicates: "keep-last"}, true},
+	newSchedulerTest{Config{Name: "", Cron: "0 9 * * 1", Source: "file:///data/customers.txt", Duplicates: ""}, false},
+	newSchedulerTest{Config{Name: "a/b", Cron: "0 9 * * 1", Source: "file:///data/customers.txt", Duplicates: ""}, false},
+	newSchedulerTest{Config{Name: "weekly", Cron: "0 9 * *", Source: "file:///data/customers.txt", Duplicates: ""}, false},
+	newSchedulerTest{Config{Name: "weekly", Cron: "0 9 * * 1", Source: "", Duplicates: ""}, false},
+}
+
+func TestNewScheduler(t *testing.T) {
+	for _, test := range newSchedulerTests {
+		if _, err := NewScheduler(t.TempDir(), []Config{test.config}, (&fakeRunner{}).run); (err == nil) != test.valid {
+			t.Errorf("Output error %v for %v, expected valid %v", err, test.config, test.valid)
+		}
+	}
+	config := newSchedulerTests[0].config
+	if _, err := NewScheduler(t.TempDir(), []Config{config, config}, (&fakeRunner{}).run); err == nil {
+		t.Errorf("Expected an error for duplicate schedule names")
+	}
+}
+
+func TestSchedulerStart(t *testing.T) {
+	s, err := NewScheduler(t.TempDir(), []Config{Config{Name: "minutely", Cron: "* * * * *", Source: "file:///data/customers.txt", Duplicates: ""}}, (&fakeRunner{}).run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a clock where the first wait is over at once and the next ones never end
+	waits := make(chan time.Duration, 1)
+	var once sync.Once
+	s.after = func(d time.Duration) <-chan time.Time {
+		var c chan time.Time
+		once.Do(func() {
+			waits <- d
+			c = make(chan time.Time, 1)
+			c <- time.Now()
+		})
+		return c
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	if d := <-waits; d <= 0 || d > time.Minute {
+		t.Errorf("Output wait %v, expected a wait until the next minute", d)
+	}
+	finished := func() bool {
+		runs := s.Runs("minutely")
+		return len(runs) == 1 && runs[0].Status == StatusDone
+	}
+	for i := 0; i < 200 && !finished(); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !finished() {
+		t.Errorf("Output runs %v, expected the schedule to have run once", s.Runs("minutely"))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	runner := &fakeRunner{[]Invitee{Invitee{1, "a"}}}
+	s, err := NewScheduler(t.TempDir(), []Config{Config{Name: "weekly", Cron: "0 9 * * 1", Source: "file:///data/customers.txt", Duplicates: ""}}, runner.run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.RunNow(context.Background(), "weekly")
+	runner.invitees = []Invitee{Invitee{2, "b"}}
+	second, _ := s.RunNow(context.Background(), "weekly")
+	handler := util.ErrorHandler(s.Handler("/v2/runs"))
+
+	type handlerTest struct {
+		method string
+		path   string
+		status int
+		body   string
+	}
+	for _, test := range []handlerTest{
+		handlerTest{"GET", "/v2/runs", http.StatusOK, "\"id\":\"" + first.ID + "\""},
+		handlerTest{"GET", "/v2/runs?schedule=other", http.StatusOK, "[]"},
+		handlerTest{"GET", "/v2/runs/" + second.ID, http.StatusOK, "\"invitees\":[{\"User_id\":2,\"Name\":\"b\"}]"},
+		handlerTest{"GET", "/v2/runs/diff?to=" + second.ID, http.StatusOK, "\"added\":[{\"User_id\":2,\"Name\":\"b\"}],\"removed\":[{\"User_id\":1,\"Name\":\"a\"}]"},
+		handlerTest{"GET", "/v2/runs/diff?from=" + second.ID + "&to=" + first.ID, http.StatusOK, "\"added\":[{\"User_id\":1,\"Name\":\"a\"}]"},
+		handlerTest{"GET", "/v2/runs/diff?to=" + first.ID, http.StatusNotFound, "No previous successful run"},
+		handlerTest{"GET", "/v2/runs/diff", http.StatusBadRequest, "Expected the run IDs"},
+		handlerTest{"GET", "/v2/runs/" + strings.Repeat("0", 32), http.StatusNotFound, "Run not found"},
+		handlerTest{"GET", "/v2/runs/../secret", http.StatusNotFound, "Run not found"},
+		handlerTest{"POST", "/v2/runs", http.StatusMethodNotAllowed, "not a GET request"},
+	} {
+		writer := httptest.NewRecorder()
+		handler(writer, httptest.NewRequest(test.method, test.path, nil))
+		if writer.Code != test.status || !strings.Contains(writer.Body.String(), test.body) {
+			t.Errorf("Output %v %v for %v not equal to expected %v %v", writer.Code, writer.Body.String(), test.path, test.status, test.body)
+		}
+	}
+
+	//the run files hold the full snapshot
+	b, err := os.ReadFile(filepath.Join(s.dir, first.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Run
+	if err := json.Unmarshal(b, &r); err != nil || len(r.Invitees) != 1 {
+		t.Errorf("Output snapshot %v (%v), expected 1 invitee", string(b), err)
+	}
+}
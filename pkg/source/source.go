@@ -0,0 +1,264 @@
+// Package source fetches customer files referenced by a file:// or http(s):// URL, within allowlisted directories and hosts
+package source
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Maximum number of http responses kept for ETag revalidation, and their maximum total size in bytes. A response
+// larger than the total size is not kept
+const (
+	maxCacheEntries = 64
+	maxCacheBytes   = 64 << 20
+)
+
+var (
+	ErrNotAllowed = errors.New("Source is not allowed")
+	ErrTooLarge   = errors.New("Source is too large")
+)
+
+// Limits applied to the fetched sources
+type Config struct {
+	// Directories (and their subdirectories) file:// URLs may point to
+	AllowedDirs []string
+	// Hosts (e.g. "example.com" or "example.com:8080") http(s):// URLs may point to
+	AllowedHosts []string
+	// Maximum size of a source in bytes
+	MaxSize int64
+	// Maximum time taken to fetch an http(s) source
+	Timeout time.Duration
+}
+
+// A cached http response
+type cacheEntry struct {
+	etag    string
+	content []byte
+}
+
+// Fetches sources according to a Config, revalidating http(s) sources with their ETag
+type Fetcher struct {
+	config Config
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]cacheEntry
+	// Total size of the cached contents, and its limit
+	cacheBytes int64
+	cacheLimit int64
+}
+
+// Create a fetcher. The allowed directories are resolved to absolute paths without symlinks
+func NewFetcher(config Config) (*Fetcher, error) {
+	if config.MaxSize <= 0 {
+		return nil, errors.New("Maximum source size must be > 0")
+	}
+	if config.Timeout <= 0 {
+		return nil, errors.New("Source timeout must be > 0")
+	}
+	var dirs []string
+	for _, dir := range config.AllowedDirs {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return nil, errors.New("Invalid allowed directory " + dir + " : " + err.Error())
+		}
+		dirs = append(dirs, resolved)
+	}
+	config.AllowedDirs = dirs
+
+	f := &Fetcher{config: config, cache: make(map[string]cacheEntry), cacheLimit: maxCacheBytes}
+	f.client = &http.Client{
+		Timeout: config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("Too many redirects")
+			}
+			if !f.hostAllowed(req.URL) {
+				return ErrNotAllowed
+			}
+			return nil
+		},
+	}
+	return f, nil
+}
+
+// Fetch the source referenced by rawURL
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (util.UploadedFile, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return util.UploadedFile{}, util.NewHTTPError(http.StatusBadRequest, "Invalid source "+rawURL+" : "+err.Error())
+	}
+	var content []byte
+	switch u.Scheme {
+	case "file":
+		content, err = f.fetchFile(u)
+	case "http", "https":
+		content, err = f.fetchHTTP(ctx, u)
+	default:
+		err = util.NewHTTPError(http.StatusBadRequest, "Unsupported source scheme "+u.Scheme+", expected file, http or https")
+	}
+	if err != nil {
+		return util.UploadedFile{}, err
+	}
+	return util.UploadedFile{Name: rawURL, Content: content}, nil
+}
+
+// Readiness check reporting whether the allowed directories can still be read
+func (f *Fetcher) Check() error {
+	for _, dir := range f.config.AllowedDirs {
+		if _, err := os.ReadDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a file:// source, which must be inside an allowed directory
+func (f *Fetcher) fetchFile(u *url.URL) ([]byte, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, &util.HTTPError{Status: http.StatusForbidden, Err: ErrNotAllowed}
+	}
+	path, err := resolvePath(u.Path)
+	if err != nil {
+		return nil, util.NewHTTPError(http.StatusBadRequest, "Cannot read source "+u.Path+" : "+err.Error())
+	}
+	if !f.dirAllowed(path) {
+		return nil, &util.HTTPError{Status: http.StatusForbidden, Err: ErrNotAllowed}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, util.NewHTTPError(http.StatusBadRequest, "Cannot read source "+u.Path+" : "+err.Error())
+	}
+	defer file.Close()
+	return f.readLimited(file)
+}
+
+// Fetch an http(s) source from an allowed host, reusing the cached content when the server answers 304 Not Modified
+func (f *Fetcher) fetchHTTP(ctx context.Context, u *url.URL) ([]byte, error) {
+	if !f.hostAllowed(u) {
+		return nil, &util.HTTPError{Status: http.StatusForbidden, Err: ErrNotAllowed}
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	key := u.String()
+	f.mu.Lock()
+	cached, found := f.cache[key]
+	f.mu.Unlock()
+	if found {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotAllowed) {
+			return nil, &util.HTTPError{Status: http.StatusForbidden, Err: ErrNotAllowed}
+		}
+		return nil, &util.HTTPError{Status: http.StatusBadGateway, Err: errors.New("Cannot fetch source " + key + " : " + err.Error())}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && found:
+		return cached.content, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, util.NewHTTPError(http.StatusBadGateway, "Cannot fetch source "+key+" : status "+strconv.Itoa(resp.StatusCode))
+	case resp.ContentLength > f.config.MaxSize:
+		return nil, &util.HTTPError{Status: http.StatusRequestEntityTooLarge, Err: ErrTooLarge}
+	}
+	content, err := f.readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		f.store(key, cacheEntry{etag, content})
+	}
+	return content, nil
+}
+
+// Keep a response for revalidation, evicting entries until it fits in the cache. A response larger than the whole
+// cache is not kept
+func (f *Fetcher) store(key string, entry cacheEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.evict(key)
+	size := int64(len(entry.content))
+	if size > f.cacheLimit {
+		return
+	}
+	for k := range f.cache {
+		if len(f.cache) < maxCacheEntries && f.cacheBytes+size <= f.cacheLimit {
+			break
+		}
+		f.evict(k)
+	}
+	f.cache[key] = entry
+	f.cacheBytes += size
+}
+
+// Remove an entry from the cache, if present. Called with f.mu held
+func (f *Fetcher) evict(key string) {
+	if entry, found := f.cache[key]; found {
+		f.cacheBytes -= int64(len(entry.content))
+		delete(f.cache, key)
+	}
+}
+
+// Read r, failing if it is larger than the maximum size
+func (f *Fetcher) readLimited(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, f.config.MaxSize+1))
+	if err != nil {
+		return nil, &util.HTTPError{Status: http.StatusBadGateway, Err: err}
+	}
+	if int64(len(content)) > f.config.MaxSize {
+		return nil, &util.HTTPError{Status: http.StatusRequestEntityTooLarge, Err: ErrTooLarge}
+	}
+	return content, nil
+}
+
+// Check that a resolved path is inside one of the allowed directories
+func (f *Fetcher) dirAllowed(path string) bool {
+	for _, dir := range f.config.AllowedDirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that the host (with its port if the allowlist entry has one) of u is allowed
+func (f *Fetcher) hostAllowed(u *url.URL) bool {
+	for _, allowed := range f.config.AllowedHosts {
+		if _, _, err := net.SplitHostPort(allowed); err == nil {
+			if strings.EqualFold(u.Host, allowed) {
+				return true
+			}
+		} else if strings.EqualFold(u.Hostname(), allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the absolute path of p with symlinks resolved, so ".." and links cannot escape an allowed directory
+func resolvePath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
@@ -0,0 +1,190 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+const customerLine = "{\"latitude\": \"0\", \"user_id\": 1, \"name\": \"user1\", \"longitude\": \"0\"}\n"
+
+type fetchTest struct {
+	source  string
+	content string
+	status  int
+}
+
+// Status of the HTTPError returned by Fetch, 0 when there is no error
+func errorStatus(err error) int {
+	var httpErr *util.HTTPError
+	if err == nil {
+		return 0
+	}
+	if errors.As(err, &httpErr) {
+		return httpErr.Status
+	}
+	return -1
+}
+
+func TestFetchFile(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "data")
+	if err := os.Mkdir(allowed, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(allowed, "customers.txt"): customerLine,
+		filepath.Join(allowed, "large.txt"):     strings.Repeat(customerLine, 10),
+		filepath.Join(dir, "secret.txt"):        "secret",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(allowed, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFetcher(Config{AllowedDirs: []string{allowed}, MaxSize: int64(2 * len(customerLine)), Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetchTests []fetchTest = []fetchTest{
+		fetchTest{"file://" + allowed + "/customers.txt", customerLine, 0},
+		fetchTest{"file://localhost" + allowed + "/customers.txt", customerLine, 0},
+		fetchTest{"file://" + allowed + "/large.txt", "", http.StatusRequestEntityTooLarge},
+		fetchTest{"file://" + allowed + "/../secret.txt", "", http.StatusForbidden},
+		fetchTest{"file://" + allowed + "/link.txt", "", http.StatusForbidden},
+		fetchTest{"file://" + dir + "/secret.txt", "", http.StatusForbidden},
+		fetchTest{"file://otherhost" + allowed + "/customers.txt", "", http.StatusForbidden},
+		fetchTest{"file://" + allowed + "/missing.txt", "", http.StatusBadRequest},
+		fetchTest{"ftp://example.com/customers.txt", "", http.StatusBadRequest},
+		fetchTest{"https://example.com/customers.txt", "", http.StatusForbidden},
+	}
+	for _, test := range fetchTests {
+		file, err := f.Fetch(context.Background(), test.source)
+		if status := errorStatus(err); status != test.status {
+			t.Errorf("Output status %v (%v) for %v not equal to expected %v", status, err, test.source, test.status)
+		}
+		if string(file.Content) != test.content {
+			t.Errorf("Output content %v for %v not equal to expected %v", string(file.Content), test.source, test.content)
+		}
+	}
+	if err := f.Check(); err != nil {
+		t.Errorf("Output error %v, expected the allowed directory to be readable", err)
+	}
+}
+
+func TestFetchHTTP(t *testing.T) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/customers.txt":
+			if r.Header.Get("If-None-Match") == "\"v1\"" {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", "\"v1\"")
+			w.Write([]byte(customerLine))
+		case "/large.txt":
+			w.Write([]byte(strings.Repeat(customerLine, 10)))
+		case "/slow.txt":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(customerLine))
+		case "/redirect.txt":
+			http.Redirect(w, r, "http://example.com/customers.txt", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+
+	f, err := NewFetcher(Config{AllowedHosts: []string{host.Host}, MaxSize: int64(2 * len(customerLine)), Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetchTests []fetchTest = []fetchTest{
+		fetchTest{server.URL + "/customers.txt", customerLine, 0},
+		//served from the cache after revalidation
+		fetchTest{server.URL + "/customers.txt", customerLine, 0},
+		fetchTest{server.URL + "/large.txt", "", http.StatusRequestEntityTooLarge},
+		fetchTest{server.URL + "/slow.txt", "", http.StatusBadGateway},
+		fetchTest{server.URL + "/missing.txt", "", http.StatusBadGateway},
+		fetchTest{server.URL + "/redirect.txt", "", http.StatusForbidden},
+		fetchTest{"http://" + host.Hostname() + ":1/customers.txt", "", http.StatusForbidden},
+	}
+	for _, test := range fetchTests {
+		file, err := f.Fetch(context.Background(), test.source)
+		if status := errorStatus(err); status != test.status {
+			t.Errorf("Output status %v (%v) for %v not equal to expected %v", status, err, test.source, test.status)
+		}
+		if string(file.Content) != test.content {
+			t.Errorf("Output content %v for %v not equal to expected %v", string(file.Content), test.source, test.content)
+		}
+	}
+	if n := atomic.LoadInt32(&notModified); n != 1 {
+		t.Errorf("Output %v revalidated requests not equal to expected %v", n, 1)
+	}
+
+	//an allowlist entry without port allows every port of the host
+	f, err = NewFetcher(Config{AllowedHosts: []string{host.Hostname()}, MaxSize: 1 << 10, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fetch(context.Background(), server.URL+"/customers.txt"); err != nil {
+		t.Errorf("Output error %v, expected the host to be allowed", err)
+	}
+}
+
+func TestNewFetcher(t *testing.T) {
+	type configTest struct {
+		config Config
+		valid  bool
+	}
+	for _, test := range []configTest{
+		configTest{Config{MaxSize: 1, Timeout: time.Second}, true},
+		configTest{Config{MaxSize: 0, Timeout: time.Second}, false},
+		configTest{Config{MaxSize: 1, Timeout: 0}, false},
+		configTest{Config{AllowedDirs: []string{filepath.Join(t.TempDir(), "missing")}, MaxSize: 1, Timeout: time.Second}, false},
+	} {
+		if _, err := NewFetcher(test.config); (err == nil) != test.valid {
+			t.Errorf("Output error %v for %v, expected valid %v", err, test.config, test.valid)
+		}
+	}
+}
+
+func TestCacheLimit(t *testing.T) {
+	f := &Fetcher{cache: make(map[string]cacheEntry), cacheLimit: 10}
+	for _, key := range []string{"a", "b", "c"} {
+		f.store(key, cacheEntry{"\"v1\"", []byte("1234")})
+	}
+	if len(f.cache) != 2 || f.cacheBytes != 8 {
+		t.Errorf("Output %v entries of %v bytes, expected 2 entries of 8 bytes", len(f.cache), f.cacheBytes)
+	}
+	if _, found := f.cache["c"]; !found {
+		t.Errorf("Output %v, expected the last response to be kept", f.cache)
+	}
+	//replacing an entry does not count it twice
+	f.store("c", cacheEntry{"\"v2\"", []byte("12")})
+	if f.cacheBytes != 6 {
+		t.Errorf("Output %v bytes, expected 6", f.cacheBytes)
+	}
+	//a response larger than the cache is not kept, and drops its stale entry
+	f.store("c", cacheEntry{"\"v3\"", []byte("12345678901")})
+	if _, found := f.cache["c"]; found || f.cacheBytes != 4 {
+		t.Errorf("Output %v entries of %v bytes, expected the large response not to be kept", len(f.cache), f.cacheBytes)
+	}
+}
@@ -0,0 +1,193 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Maximum total size of the files of one request once decompressed
+var MaxUploadSize int64 = 100 << 20
+
+// Maximum number of files in one request, archive entries included
+const MaxUploadFiles = 1000
+
+var ErrUploadTooLarge error = &HTTPError{http.StatusRequestEntityTooLarge, errors.New("Upload is too large once decompressed")}
+
+// A customer file of an upload, Name tells where it came from (e.g. "archive.zip/customers.txt")
+type UploadedFile struct {
+	Name    string
+	Content []byte
+}
+
+// Magic numbers of the compressed formats
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// Content types accepted as a raw JSON Lines body
+var RawBodyContentTypes = []string{"application/x-ndjson", "application/jsonl", "text/plain"}
+
+// Name given to the file read from a raw body
+const RawBodyName = "body"
+
+// Describe the accepted input shapes, for error messages. extraInputs describes the inputs handled by the caller
+func AcceptedInputs(fieldName string, extraInputs ...string) string {
+	accepted := "Accepted inputs: a multipart/form-data form with the file(s) in the field \"" + fieldName +
+		"\", or a raw body of type " + strings.Join(RawBodyContentTypes, ", ") + " (optionally with Content-Encoding: gzip)"
+	for _, input := range extraInputs {
+		accepted += ", or " + input
+	}
+	return accepted
+}
+
+// Read the customer files of a request, either from a multipart form (see GetFiles) or from a raw JSON Lines body.
+// Unsupported content types give a 415 HTTPError and a missing form field a 400 HTTPError, both listing the accepted inputs
+// (and extraInputs, see AcceptedInputs)
+func GetUpload(request *http.Request, fieldName string, extraInputs ...string) ([]UploadedFile, error) {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, NewHTTPError(http.StatusUnsupportedMediaType, "Missing or invalid Content-Type. "+AcceptedInputs(fieldName, extraInputs...))
+	}
+	if mediaType == "multipart/form-data" {
+		files, err := GetFiles(request, fieldName)
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, NewHTTPError(http.StatusBadRequest, "No customer file found in the form field \""+fieldName+"\" ("+err.Error()+"). "+AcceptedInputs(fieldName, extraInputs...))
+		}
+		return files, err
+	}
+	for _, contentType := range RawBodyContentTypes {
+		if mediaType == contentType {
+			return getRawBody(request)
+		}
+	}
+	return nil, NewHTTPError(http.StatusUnsupportedMediaType, "Unsupported Content-Type "+mediaType+". "+AcceptedInputs(fieldName, extraInputs...))
+}
+
+// Read a raw body as a single file, decompressing it if needed
+func getRawBody(request *http.Request) ([]UploadedFile, error) {
+	var body io.Reader = request.Body
+	if strings.EqualFold(request.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	budget := MaxUploadSize
+	content, err := readLimited(body, &budget)
+	if err != nil {
+		return nil, err
+	}
+	return expandFile(RawBodyName, content, &budget, 0)
+}
+
+// Read every file uploaded under fieldName in a multipart form. The request body may be gzip compressed
+// (Content-Encoding: gzip), gzip compressed parts are decompressed and zip archives are expanded into their files
+func GetFiles(request *http.Request, fieldName string) ([]UploadedFile, error) {
+	if strings.EqualFold(request.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		request.Body = io.NopCloser(io.LimitReader(gz, MaxUploadSize))
+		request.Header.Del("Content-Encoding")
+	}
+	if err := request.ParseMultipartForm(10 << 20); err != nil {
+		return nil, err
+	}
+	headers := request.MultipartForm.File[fieldName]
+	if len(headers) == 0 {
+		return nil, http.ErrMissingFile
+	}
+
+	budget := MaxUploadSize
+	var files []UploadedFile
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := readLimited(file, &budget)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		expanded, err := expandFile(header.Filename, content, &budget, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, expanded...)
+		if len(files) > MaxUploadFiles {
+			return nil, errors.New("Too many files in the upload")
+		}
+	}
+	return files, nil
+}
+
+// Read r, failing once more than the remaining budget has been read
+func readLimited(r io.Reader, budget *int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > *budget {
+		return nil, ErrUploadTooLarge
+	}
+	*budget -= int64(len(content))
+	return content, nil
+}
+
+// Decompress gzip content and expand zip archives. depth stops archives nested in archives
+func expandFile(name string, content []byte, budget *int64, depth int) ([]UploadedFile, error) {
+	switch {
+	case depth < 2 && bytes.HasPrefix(content, gzipMagic):
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, errors.New("Cannot decompress " + name + " : " + err.Error())
+		}
+		defer gz.Close()
+		decompressed, err := readLimited(gz, budget)
+		if err != nil {
+			return nil, err
+		}
+		return expandFile(strings.TrimSuffix(name, ".gz"), decompressed, budget, depth+1)
+
+	case depth < 2 && bytes.HasPrefix(content, zipMagic):
+		archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, errors.New("Cannot open archive " + name + " : " + err.Error())
+		}
+		var files []UploadedFile
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(path.Base(entry.Name), ".") {
+				continue
+			}
+			r, err := entry.Open()
+			if err != nil {
+				return nil, errors.New("Cannot read " + name + "/" + entry.Name + " : " + err.Error())
+			}
+			entryContent, err := readLimited(r, budget)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			expanded, err := expandFile(name+"/"+entry.Name, entryContent, budget, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, expanded...)
+		}
+		return files, nil
+	}
+	return []UploadedFile{UploadedFile{name, content}}, nil
+}
@@ -2,14 +2,19 @@ package util
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"math"
 	"mime/multipart"
 	"net/http"
 	"os"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
 )
 
 // This is for floating point comparision.
@@ -55,16 +60,116 @@ func GetFileInBytes(request *http.Request) ([]byte, error) {
 	return fileBytes, nil
 }
 
-// A generic handler for http request
+// An error answered with a specific HTTP status code by ErrorHandler
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+// Implement the error interface
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+// Return the wrapped error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Create an error answered with the given HTTP status code
+func NewHTTPError(status int, message string) error {
+	return &HTTPError{status, errors.New(message)}
+}
+
+// A generic handler for http request. Errors are answered with 400, unless they wrap an HTTPError
 func ErrorHandler(f func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := f(w, r)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			status := http.StatusBadRequest
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) {
+				status = httpErr.Status
+			}
+			w.WriteHeader(status)
 			fmt.Fprintf(w, err.Error())
-			log.Println(err.Error())
+			logger.Warn(r.Context(), "Request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+		}
+	}
+}
+
+// Write v as the JSON body of the response, with the given status
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	WriteRawJSON(w, status, resp)
+	return nil
+}
+
+// Write JSON which is already encoded as the body of the response, with the given status
+func WriteRawJSON(w http.ResponseWriter, status int, resp []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// Generate a random ID of 32 hex characters
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Write a file through a temporary file and a rename, so a crash never leaves a partial file behind
+func WriteFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Readiness check reporting whether files can be created in dir
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// The header used to receive and return the request ID
+const RequestIDHeader = "X-Request-ID"
+
+// Assign an ID to every request (reusing a sane one sent by the client), propagate it through the
+// request context and return it in the X-Request-ID header
+func RequestIDHandler(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logger.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		f(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	}
+}
+
+// A client provided request ID is only trusted if it is short and made of printable ascii
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
 		}
 	}
+	return true
 }
 
 // A helper function for unit testing to generate byte buffer
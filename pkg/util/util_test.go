@@ -1,9 +1,23 @@
 package util
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
 )
 
 type equalTest struct {
@@ -143,3 +157,214 @@ func TestGetFileInBytes(t *testing.T) {
 
 	}
 }
+
+type requestIDHandlerTest struct {
+	incoming string
+	reused   bool
+}
+
+var requestIDHandlerTests []requestIDHandlerTest = []requestIDHandlerTest{
+	requestIDHandlerTest{"", false},
+	requestIDHandlerTest{"abc-123", true},
+	requestIDHandlerTest{"has space", false},
+	requestIDHandlerTest{strings.Repeat("a", 65), false},
+}
+
+func TestRequestIDHandler(t *testing.T) {
+	for _, test := range requestIDHandlerTests {
+		var seen string
+		h := RequestIDHandler(func(w http.ResponseWriter, r *http.Request) {
+			seen = logger.RequestID(r.Context())
+		})
+		req := httptest.NewRequest("GET", "/test", nil)
+		if test.incoming != "" {
+			req.Header.Set(RequestIDHeader, test.incoming)
+		}
+		writer := httptest.NewRecorder()
+		h(writer, req)
+
+		returned := writer.Header().Get(RequestIDHeader)
+		if returned == "" || returned != seen {
+			t.Errorf("Returned request id %v not equal to the one in the context %v", returned, seen)
+		}
+		if (returned == test.incoming) != test.reused {
+			t.Errorf("Request id %v reuse of incoming %v should be %v", returned, test.incoming, test.reused)
+		}
+	}
+}
+
+type errorHandlerTest struct {
+	err      error
+	status   int
+	expected string
+}
+
+var errorHandlerTests []errorHandlerTest = []errorHandlerTest{
+	errorHandlerTest{nil, http.StatusOK, "ok"},
+	errorHandlerTest{errors.New("bad input"), http.StatusBadRequest, "bad input"},
+	errorHandlerTest{NewHTTPError(http.StatusNotFound, "not here"), http.StatusNotFound, "not here"},
+	errorHandlerTest{fmt.Errorf("wrapped: %w", NewHTTPError(http.StatusConflict, "busy")), http.StatusConflict, "wrapped: busy"},
+}
+
+func TestErrorHandler(t *testing.T) {
+	for _, test := range errorHandlerTests {
+		h := ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			if test.err == nil {
+				w.Write([]byte("ok"))
+			}
+			return test.err
+		})
+		writer := httptest.NewRecorder()
+		h(writer, httptest.NewRequest("GET", "/test", nil))
+		if writer.Code != test.status {
+			t.Errorf("Output status %v not equal to expected %v", writer.Code, test.status)
+		}
+		if body := writer.Body.String(); body != test.expected {
+			t.Errorf("Output body %v not equal to expected %v", body, test.expected)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	writer := httptest.NewRecorder()
+	if err := WriteJSON(writer, http.StatusCreated, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if writer.Code != http.StatusCreated || writer.Header().Get("Content-Type") != "application/json" || writer.Body.String() != "{\"a\":1}" {
+		t.Errorf("Output %v %v %v, expected %v application/json {\"a\":1}", writer.Code, writer.Header().Get("Content-Type"), writer.Body.String(), http.StatusCreated)
+	}
+	//nothing is written when v cannot be marshalled, so that the error can be answered
+	writer = httptest.NewRecorder()
+	if err := WriteJSON(writer, http.StatusOK, math.Inf(1)); err == nil || writer.Body.Len() != 0 {
+		t.Errorf("Output %v %v, expected an error and no body", err, writer.Body.String())
+	}
+}
+
+func TestNewID(t *testing.T) {
+	id, err := NewID()
+	if err != nil || len(id) != 32 {
+		t.Fatalf("Output %v %v, expected 32 hex characters", id, err)
+	}
+	if other, _ := NewID(); other == id {
+		t.Errorf("Output %v twice, expected different IDs", id)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.json")
+	if err := WriteFileAtomic(path, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "new" {
+		t.Errorf("Output %v %v, expected new", string(b), err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Output %v, expected no temporary file left", err)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckWritable(dir); err != nil {
+		t.Errorf("Output %v, expected nil", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Output %v, expected the check file to be removed", entries)
+	}
+	if err := CheckWritable(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Output nil, expected an error for a missing directory")
+	}
+}
+
+// Build a multipart body with one part per file under fieldName
+func multipartBody(t *testing.T, fieldName string, files []UploadedFile) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for _, file := range files {
+		w, err := mw.CreateFormFile(fieldName, file.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(file.Content)
+	}
+	mw.Close()
+	return body, mw.FormDataContentType()
+}
+
+// Compress content with gzip
+func gzipBytes(content []byte) []byte {
+	b := new(bytes.Buffer)
+	gz := gzip.NewWriter(b)
+	gz.Write(content)
+	gz.Close()
+	return b.Bytes()
+}
+
+// Build a zip archive of the files
+func zipBytes(files []UploadedFile) []byte {
+	b := new(bytes.Buffer)
+	zw := zip.NewWriter(b)
+	for _, file := range files {
+		w, _ := zw.Create(file.Name)
+		w.Write(file.Content)
+	}
+	zw.Close()
+	return b.Bytes()
+}
+
+type getFilesTest struct {
+	fieldName string
+	files     []UploadedFile
+	gzipBody  bool
+	expected  []UploadedFile
+	errString string
+}
+
+var getFilesTests []getFilesTest = []getFilesTest{
+	getFilesTest{"other", []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, false, nil, "no such file"},
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, false, []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, ""},
+	//several parts, kept in order
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"a.txt", []byte("a")}, UploadedFile{"b.txt", []byte("b")}}, false,
+		[]UploadedFile{UploadedFile{"a.txt", []byte("a")}, UploadedFile{"b.txt", []byte("b")}}, ""},
+	//gzip compressed body
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, true, []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, ""},
+	//gzip compressed part
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"a.txt.gz", gzipBytes([]byte("a"))}}, false, []UploadedFile{UploadedFile{"a.txt", []byte("a")}}, ""},
+	//zip archive, directories and hidden files are skipped
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"c.zip", zipBytes([]UploadedFile{UploadedFile{"dir/", nil}, UploadedFile{"dir/a.txt", []byte("a")}, UploadedFile{".DS_Store", []byte("x")}, UploadedFile{"b.txt.gz", gzipBytes([]byte("b"))}})}}, false,
+		[]UploadedFile{UploadedFile{"c.zip/dir/a.txt", []byte("a")}, UploadedFile{"c.zip/b.txt", []byte("b")}}, ""},
+	//corrupted gzip part
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"a.gz", []byte{0x1f, 0x8b, 0}}}, false, nil, "Cannot decompress a.gz"},
+	//too large once decompressed
+	getFilesTest{"customerFile", []UploadedFile{UploadedFile{"big.gz", gzipBytes(make([]byte, 2048))}}, false, nil, ErrUploadTooLarge.Error()},
+}
+
+func TestGetFiles(t *testing.T) {
+	defer func(size int64) { MaxUploadSize = size }(MaxUploadSize)
+	MaxUploadSize = 1024
+
+	for _, test := range getFilesTests {
+		body, contentType := multipartBody(t, test.fieldName, test.files)
+		var reader io.Reader = body
+		if test.gzipBody {
+			reader = bytes.NewReader(gzipBytes(body.Bytes()))
+		}
+		req := httptest.NewRequest("PUT", "/test", reader)
+		req.Header.Add("Content-Type", contentType)
+		if test.gzipBody {
+			req.Header.Add("Content-Encoding", "gzip")
+		}
+
+		files, err := GetFiles(req, "customerFile")
+		if (err == nil && test.errString != "") || (err != nil && (test.errString == "" || !strings.Contains(err.Error(), test.errString))) {
+			t.Errorf("Output error %v is not the same as expected %v", err, test.errString)
+		}
+		if !reflect.DeepEqual(files, test.expected) {
+			t.Errorf("Output files %q are not the same as expected %q", files, test.expected)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package webhook pushes the results of the invite runs to the configured URLs, signed with HMAC-SHA256 and retried
+// with an exponential backoff. Deliveries which still fail are written to a dead-letter log
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/logger"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/metrics"
+	"git.codesubmit.io/sfox/party-invite-ruiegv/pkg/util"
+)
+
+// Headers of the deliveries
+const (
+	SignatureHeader = "X-Webhook-Signature-256"
+	TimestampHeader = "X-Webhook-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	AttemptHeader   = "X-Webhook-Attempt"
+)
+
+// Type of the events sent when an invite run completes
+const EventInviteCompleted = "invite.completed"
+
+// Kinds of invite runs
+const (
+	KindRequest = "request"
+	KindJob     = "job"
+	KindRun     = "run"
+)
+
+// Maximum number of deliveries waiting for a worker
+const QueueSize = 1024
+
+// Defaults of the optional settings of a webhook
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+var deliveries = metrics.Default.NewCounterVec("webhook_deliveries_total",
+	"Number of webhook delivery attempts by outcome (delivered, retried, dead).", "outcome")
+
+// A duration written as a string such as "1s" or "500ms" in the configuration file
+type Duration time.Duration
+
+// Implement UnmarshalJSON to read the duration from a string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("Invalid duration " + string(b) + ", expected a string such as \"1s\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Implement MarshalJSON to write the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// A webhook as written in the configuration file
+type Config struct {
+	// http(s) URL the events are POSTed to
+	URL string `json:"url"`
+	// Key of the HMAC-SHA256 signature, or the name of the environment variable holding it with SecretEnv
+	Secret    string `json:"secret,omitempty"`
+	SecretEnv string `json:"secret_env,omitempty"`
+	// Kinds of runs (request, job, run) sent to the webhook, all of them if empty
+	Kinds []string `json:"kinds,omitempty"`
+	// Number of attempts before the delivery goes to the dead-letter log
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Wait before the first retry, doubled after every attempt up to MaxBackoff
+	Backoff    Duration `json:"backoff,omitempty"`
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+	// Maximum time taken by one attempt
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// Check the configuration, resolve the secret and fill in the defaults
+func (c *Config) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Invalid webhook url \"" + c.URL + "\", expected an http(s) URL")
+	}
+	if c.SecretEnv != "" {
+		c.Secret = os.Getenv(c.SecretEnv)
+	}
+	if c.Secret == "" {
+		return errors.New("Missing secret for the webhook " + c.URL)
+	}
+	for _, kind := range c.Kinds {
+		if kind != KindRequest && kind != KindJob && kind != KindRun {
+			return errors.New("Invalid kind " + kind + " for the webhook " + c.URL + ", expected request, job or run")
+		}
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.Backoff == 0 {
+		c.Backoff = Duration(DefaultBackoff)
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = Duration(DefaultMaxBackoff)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = Duration(DefaultTimeout)
+	}
+	if c.MaxAttempts < 0 || c.Backoff < 0 || c.MaxBackoff < c.Backoff || c.Timeout < 0 {
+		return errors.New("Invalid retry settings for the webhook " + c.URL)
+	}
+	return nil
+}
+
+// Check whether the webhook receives the runs of this kind
+func (c *Config) accepts(kind string) bool {
+	if len(c.Kinds) == 0 {
+		return true
+	}
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// The body of a delivery
+type Event struct {
+	Type string `json:"type"`
+	// Kind of the run (request, job or run) and its ID (request ID, job ID or run ID)
+	Kind string    `json:"kind"`
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Result of the run, as returned by /v1/customer
+	Result json.RawMessage `json:"result"`
+}
+
+// An event to send to one webhook
+type delivery struct {
+	id      string
+	hook    *Config
+	payload []byte
+}
+
+// An entry of the dead-letter log
+type deadLetter struct {
+	Time     time.Time       `json:"time"`
+	Delivery string          `json:"delivery"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Sends the events to the webhooks on a pool of workers
+type Notifier struct {
+	hooks   []Config
+	workers int
+	client  *http.Client
+	queue   chan delivery
+	sleep   func(ctx context.Context, d time.Duration) error
+	mu      sync.Mutex
+	dead    io.Writer
+}
+
+// Validate the webhooks and create a notifier delivering them with workers goroutines. The deliveries which
+// fail after all their attempts are written as JSON lines to deadLetter
+func NewNotifier(hooks []Config, workers int, deadLetter io.Writer) (*Notifier, error) {
+	if workers < 1 {
+		return nil, errors.New("Number of webhook workers must be > 0")
+	}
+	n := &Notifier{workers: workers, client: &http.Client{}, queue: make(chan delivery, QueueSize), sleep: sleep, dead: deadLetter}
+	for _, hook := range hooks {
+		if err := hook.validate(); err != nil {
+			return nil, err
+		}
+		n.hooks = append(n.hooks, hook)
+	}
+	return n, nil
+}
+
+// Start the workers, they stop when ctx is cancelled
+func (n *Notifier) Start(ctx context.Context) {
+	for i := 0; i < n.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-n.queue:
+					n.deliver(ctx, d)
+				}
+			}
+		}()
+	}
+}
+
+// Queue the event for every webhook receiving its kind. Never blocks: when the queue is full the delivery goes
+// straight to the dead-letter log
+func (n *Notifier) Notify(ctx context.Context, event Event) {
+	if event.Type == "" {
+		event.Type = EventInviteCompleted
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		logger.Error(ctx, "Cannot encode webhook event", "error", err)
+		return
+	}
+	for i := range n.hooks {
+		hook := &n.hooks[i]
+		if !hook.accepts(event.Kind) {
+			continue
+		}
+		id, err := util.NewID()
+		if err != nil {
+			logger.Error(ctx, "Cannot generate a webhook delivery ID", "error", err)
+			return
+		}
+		d := delivery{id, hook, payload}
+		select {
+		case n.queue <- d:
+		default:
+			n.deadLetter(d, 0, errors.New("Webhook queue is full"))
+		}
+	}
+}
+
+// Send a delivery, retrying with an exponential backoff until it succeeds, fails permanently or runs out of attempts
+func (n *Notifier) deliver(ctx context.Context, d delivery) {
+	backoff := time.Duration(d.hook.Backoff)
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		var retry bool
+		retry, err = n.send(ctx, d, attempt)
+		if err == nil {
+			deliveries.Inc("delivered")
+			logger.Debug(ctx, "Webhook delivered", "delivery", d.id, "url", d.hook.URL, "attempt", attempt)
+			return
+		}
+		if !retry || attempt >= d.hook.MaxAttempts {
+			break
+		}
+		deliveries.Inc("retried")
+		logger.Warn(ctx, "Webhook delivery failed, retrying", "delivery", d.id, "url", d.hook.URL, "attempt", attempt, "backoff", backoff.String(), "error", err)
+		if err = n.sleep(ctx, backoff); err != nil {
+			break
+		}
+		if backoff *= 2; backoff > time.Duration(d.hook.MaxBackoff) {
+			backoff = time.Duration(d.hook.MaxBackoff)
+		}
+	}
+	n.deadLetter(d, attempt, err)
+}
+
+// Make one attempt of a delivery. Returns whether a failure is worth retrying: network errors, 429 and 5xx are
+func (n *Notifier) send(ctx context.Context, d delivery, attempt int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(d.hook.Timeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.hook.URL, bytes.NewReader(d.payload))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign([]byte(d.hook.Secret), timestamp, d.payload))
+	req.Header.Set(DeliveryHeader, d.id)
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, errors.New("Webhook answered " + resp.Status)
+}
+
+// Write a delivery which could not be made to the dead-letter log
+func (n *Notifier) deadLetter(d delivery, attempts int, err error) {
+	deliveries.Inc("dead")
+	logger.Error(context.Background(), "Webhook delivery abandoned", "delivery", d.id, "url", d.hook.URL, "attempts", attempts, "error", err)
+	b, _ := json.Marshal(&deadLetter{time.Now().UTC(), d.id, d.hook.URL, attempts, err.Error(), d.payload})
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.dead == nil {
+		return
+	}
+	if _, werr := n.dead.Write(append(b, '\n')); werr != nil {
+		logger.Error(context.Background(), "Cannot write the webhook dead-letter log", "error", werr)
+	}
+}
+
+// Return the signature of a delivery: "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Check the signature of a delivery, for receivers
+func Verify(secret []byte, timestamp string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
+
+// Wait for d unless ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
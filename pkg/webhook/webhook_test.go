@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const secret = "s3cret"
+
+// A dead-letter log safe to read while the workers write to it
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// A receiver answering the statuses in order (then 200), recording the verified payloads
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	attempts int
+	payloads []Event
+	invalid  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.attempts++
+	if !Verify([]byte(secret), r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+		rc.invalid++
+	}
+	if len(rc.statuses) > 0 {
+		status := rc.statuses[0]
+		rc.statuses = rc.statuses[1:]
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+	}
+	var e Event
+	json.Unmarshal(body, &e)
+	rc.payloads = append(rc.payloads, e)
+}
+
+func (rc *receiver) count() (int, int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.attempts, len(rc.payloads)
+}
+
+type deliveryTest struct {
+	statuses    []int
+	maxAttempts int
+	attempts    int
+	delivered   int
+	dead        bool
+	backoffs    []time.Duration
+}
+
+var deliveryTests []deliveryTest = []deliveryTest{
+	deliveryTest{nil, 3, 1, 1, false, nil},
+	deliveryTest{[]int{500, 503}, 3, 3, 1, false, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}},
+	deliveryTest{[]int{429, 500, 500, 500}, 4, 4, 0, true, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}},
+	//client errors are not retried
+	deliveryTest{[]int{400}, 3, 1, 0, true, nil},
+}
+
+func TestNotifier(t *testing.T) {
+	for _, test := range deliveryTests {
+		rc := &receiver{statuses: test.statuses}
+		server := httptest.NewServer(rc)
+		dead := &safeBuffer{}
+		n, err := NewNotifier([]Config{Config{URL: server.URL, Secret: secret, MaxAttempts: test.maxAttempts,
+			Backoff: Duration(10 * time.Millisecond), MaxBackoff: Duration(25 * time.Millisecond)}}, 1, dead)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mu sync.Mutex
+		var backoffs []time.Duration
+		n.sleep = func(ctx context.Context, d time.Duration) error {
+			mu.Lock()
+			defer mu.Unlock()
+			backoffs = append(backoffs, d)
+			return nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		n.Start(ctx)
+		n.Notify(ctx, Event{Kind: KindRequest, ID: "abc", Result: json.RawMessage(`[{"User_id":1,"Name":"user1"}]`)})
+
+		done := func() bool {
+			attempts, _ := rc.count()
+			return attempts == test.attempts && (!test.dead || dead.String() != "")
+		}
+		for i := 0; i < 200 && !done(); i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		server.Close()
+
+		attempts, delivered := rc.count()
+		if attempts != test.attempts || delivered != test.delivered {
+			t.Errorf("Output %v attempts %v delivered not equal to expected %v %v", attempts, delivered, test.attempts, test.delivered)
+		}
+		if rc.invalid != 0 {
+			t.Errorf("Output %v deliveries with an invalid signature, expected none", rc.invalid)
+		}
+		if delivered > 0 && (rc.payloads[0].Type != EventInviteCompleted || rc.payloads[0].ID != "abc" || string(rc.payloads[0].Result) != `[{"User_id":1,"Name":"user1"}]`) {
+			t.Errorf("Output payload %v not equal to expected", rc.payloads[0])
+		}
+		mu.Lock()
+		if len(backoffs) != len(test.backoffs) || (len(backoffs) > 0 && backoffs[len(backoffs)-1] != test.backoffs[len(test.backoffs)-1]) {
+			t.Errorf("Output backoffs %v not equal to expected %v", backoffs, test.backoffs)
+		}
+		mu.Unlock()
+		if log := dead.String(); test.dead != (log != "") || (test.dead && !strings.Contains(log, "\"payload\":{\"type\":\"invite.completed\"")) {
+			t.Errorf("Output dead-letter log %v, expected dead %v", log, test.dead)
+		}
+	}
+}
+
+func TestNotifierKinds(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	n, err := NewNotifier([]Config{Config{URL: server.URL, Secret: secret, Kinds: []string{KindRun}}}, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+	n.Notify(ctx, Event{Kind: KindRequest, ID: "request"})
+	n.Notify(ctx, Event{Kind: KindRun, ID: "run"})
+	for i := 0; i < 200; i++ {
+		if _, delivered := rc.count(); delivered == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.payloads) != 1 || rc.payloads[0].ID != "run" {
+		t.Errorf("Output payloads %v, expected only the run", rc.payloads)
+	}
+}
+
+type configTest struct {
+	config Config
+	valid  bool
+}
+
+func TestNewNotifier(t *testing.T) {
+	t.Setenv("WEBHOOK_TEST_SECRET", secret)
+	for _, test := range []configTest{
+		configTest{Config{URL: "https://example.com/hook", Secret: secret}, true},
+		configTest{Config{URL: "https://example.com/hook", SecretEnv: "WEBHOOK_TEST_SECRET"}, true},
+		configTest{Config{URL: "https://example.com/hook", SecretEnv: "WEBHOOK_MISSING_SECRET"}, false},
+		configTest{Config{URL: "https://example.com/hook"}, false},
+		configTest{Config{URL: "ftp://example.com/hook", Secret: secret}, false},
+		configTest{Config{URL: "https://example.com/hook", Secret: secret, Kinds: []string{"other"}}, false},
+		configTest{Config{URL: "https://example.com/hook", Secret: secret, Backoff: Duration(time.Hour)}, false},
+	} {
+		if _, err := NewNotifier([]Config{test.config}, 1, nil); (err == nil) != test.valid {
+			t.Errorf("Output error %v for %v, expected valid %v", err, test.config, test.valid)
+		}
+	}
+
+	var c Config
+	if err := json.Unmarshal([]byte(`{"url": "https://example.com/hook", "backoff": "500ms"}`), &c); err != nil || time.Duration(c.Backoff) != 500*time.Millisecond {
+		t.Errorf("Output backoff %v (%v) not equal to expected %v", time.Duration(c.Backoff), err, 500*time.Millisecond)
+	}
+	if err := json.Unmarshal([]byte(`{"backoff": 5}`), &c); err == nil {
+		t.Errorf("Expected an error for a duration given as a number")
+	}
+}
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"type":"invite.completed"}`)
+	signature := Sign([]byte(secret), "1700000000", payload)
+	if !strings.HasPrefix(signature, "sha256=") || len(signature) != len("sha256=")+64 {
+		t.Errorf("Output signature %v, expected sha256= and 64 hex characters", signature)
+	}
+	if !Verify([]byte(secret), "1700000000", payload, signature) {
+		t.Errorf("Expected the signature to be verified")
+	}
+	if Verify([]byte(secret), "1700000001", payload, signature) || Verify([]byte("other"), "1700000000", payload, signature) {
+		t.Errorf("Expected the signature to be rejected for another timestamp or secret")
+	}
+}